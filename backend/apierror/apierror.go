@@ -0,0 +1,40 @@
+// Package apierror defines the JSON error envelope returned by every API
+// endpoint, so clients can branch on Code instead of matching messages.
+package apierror
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Machine-readable error codes.
+const (
+	CodeBadRequest         = "bad_request"
+	CodeValidation         = "validation_failed"
+	CodeUnauthorized       = "unauthorized"
+	CodeInvalidCredentials = "invalid_credentials"
+	CodeForbidden          = "forbidden"
+	CodeNotFound           = "not_found"
+	CodeMethodNotAllowed   = "method_not_allowed"
+	CodeConflict           = "conflict"
+	CodeConcurrentUpdate   = "concurrent_update"
+	CodeInsufficientPoints = "insufficient_points"
+	CodeUnavailable        = "unavailable"
+	CodeInternal           = "internal_error"
+)
+
+// Response is the error envelope. Fields maps request field names to
+// problems and is only present for validation errors.
+type Response struct {
+	Code    string            `json:"code"`
+	Message string            `json:"message"`
+	Fields  map[string]string `json:"fields,omitempty"`
+}
+
+// Write sends the envelope with the given status code.
+func Write(w http.ResponseWriter, status int, code, message string, fields map[string]string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(Response{Code: code, Message: message, Fields: fields})
+}
@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"ecotracker-backend/apierror"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// Authenticate reads an "Authorization: Bearer" access token and stores the
+// principal in the request context. When required is true, requests without
+// a valid token are rejected with 401; otherwise they continue anonymously,
+// so a stale token never stops anyone from logging in again.
+func Authenticate(signer *TokenSigner, required bool) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			token, hasToken := bearerToken(r)
+			if !hasToken {
+				if !required {
+					next(w, r)
+					return
+				}
+				apierror.Write(w, http.StatusUnauthorized, apierror.CodeUnauthorized, "Authentication required", nil)
+				return
+			}
+
+			claims, err := signer.Verify(token)
+			if err != nil && !errors.Is(err, ErrInvalidToken) {
+				log.Printf("authenticate: %v", err)
+				apierror.Write(w, http.StatusInternalServerError, apierror.CodeInternal, "Internal server error", nil)
+				return
+			}
+			if err != nil {
+				if !required {
+					next(w, r)
+					return
+				}
+				apierror.Write(w, http.StatusUnauthorized, apierror.CodeUnauthorized, err.Error(), nil)
+				return
+			}
+
+			principal := Principal{Kind: claims.Kind, ID: claims.Subject, Role: claims.Role, SessionID: claims.SessionID}
+			next(w, r.WithContext(WithPrincipal(r.Context(), principal)))
+		}
+	}
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return "", false
+	}
+
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+
+	return token, true
+}
@@ -0,0 +1,75 @@
+package auth
+
+const (
+	RoleCustomer   = "customer"
+	RoleShopkeeper = "shopkeeper"
+	RoleAdmin      = "admin"
+)
+
+// The functions below are the authorization policy shared by all handlers.
+// Admins pass every check.
+
+// CanAccessUser reports whether the principal may read or act on the
+// customer account userID.
+func CanAccessUser(p Principal, userID int) bool {
+	return p.IsAdmin() || (p.IsUser() && p.ID == userID)
+}
+
+// CanManageShop reports whether the principal may change the shop's
+// catalogue, issue or delete its receipts and read its sales.
+func CanManageShop(p Principal, shopID int) bool {
+	return p.IsAdmin() || (p.IsShop() && p.ID == shopID)
+}
+
+// CanViewReceipt reports whether the principal may read a receipt, which is
+// open to the customer it was issued to and the shop that issued it.
+func CanViewReceipt(p Principal, userID, shopID int) bool {
+	return CanAccessUser(p, userID) || CanManageShop(p, shopID)
+}
+
+// CanViewEarnings reports whether the principal may learn the points the
+// customer userID earned today, as a quote against the daily cap does. A
+// shop may for its own customers: isCustomer reports whether it has issued
+// the customer a receipt.
+func CanViewEarnings(p Principal, userID int, isCustomer bool) bool {
+	return CanAccessUser(p, userID) || (p.IsShop() && isCustomer)
+}
+
+// CanLookupCustomers reports whether the principal may look customers up by
+// email or phone at checkout.
+func CanLookupCustomers(p Principal) bool {
+	return p.IsAdmin() || p.IsShop()
+}
+
+// CanManageChallenges reports whether the principal may create, change and
+// retire challenges.
+func CanManageChallenges(p Principal) bool {
+	return p.IsAdmin()
+}
+
+// CanManageRewards reports whether the principal may define the rewards of
+// shopID; shopID 0 means rewards valid at every shop, which only admins
+// manage.
+func CanManageRewards(p Principal, shopID int) bool {
+	if shopID == 0 {
+		return p.IsAdmin()
+	}
+	return CanManageShop(p, shopID)
+}
+
+// CanAcceptRedemption reports whether the principal may verify and consume a
+// redemption code for a reward of shopID at checkout.
+func CanAcceptRedemption(p Principal, shopID int) bool {
+	return p.IsAdmin() || (p.IsShop() && (shopID == 0 || p.ID == shopID))
+}
+
+// CanManagePointsRules reports whether the principal may change the rules
+// that decide how many points purchases earn.
+func CanManagePointsRules(p Principal) bool {
+	return p.IsAdmin()
+}
+
+// CanSetPoints reports whether the principal may overwrite a point balance.
+func CanSetPoints(p Principal) bool {
+	return p.IsAdmin()
+}
@@ -0,0 +1,111 @@
+package auth
+
+import "testing"
+
+var (
+	admin     = Principal{Kind: KindUser, ID: 1, Role: RoleAdmin}
+	customer  = Principal{Kind: KindUser, ID: 2, Role: RoleCustomer}
+	stranger  = Principal{Kind: KindUser, ID: 3, Role: RoleCustomer}
+	shop      = Principal{Kind: KindShop, ID: 2, Role: RoleShopkeeper}
+	otherShop = Principal{Kind: KindShop, ID: 3, Role: RoleShopkeeper}
+)
+
+// Customer 2 shopped at shop 2, whose ID is also 2: IDs of users and shops
+// must not be confused.
+func TestPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed func(Principal) bool
+		want    map[Principal]bool
+	}{
+		{
+			name:    "CanAccessUser",
+			allowed: func(p Principal) bool { return CanAccessUser(p, 2) },
+			want:    map[Principal]bool{admin: true, customer: true, stranger: false, shop: false, otherShop: false},
+		},
+		{
+			name:    "CanManageShop",
+			allowed: func(p Principal) bool { return CanManageShop(p, 2) },
+			want:    map[Principal]bool{admin: true, customer: false, stranger: false, shop: true, otherShop: false},
+		},
+		{
+			name:    "CanViewReceipt",
+			allowed: func(p Principal) bool { return CanViewReceipt(p, 2, 2) },
+			want:    map[Principal]bool{admin: true, customer: true, stranger: false, shop: true, otherShop: false},
+		},
+		{
+			name:    "CanViewReceipt of a customer whose ID is the shop's",
+			allowed: func(p Principal) bool { return CanViewReceipt(p, 3, 2) },
+			want:    map[Principal]bool{admin: true, customer: false, stranger: true, shop: true, otherShop: false},
+		},
+		{
+			name:    "CanManageRewards of a shop",
+			allowed: func(p Principal) bool { return CanManageRewards(p, 2) },
+			want:    map[Principal]bool{admin: true, customer: false, stranger: false, shop: true, otherShop: false},
+		},
+		{
+			name:    "CanManageRewards valid at every shop",
+			allowed: func(p Principal) bool { return CanManageRewards(p, 0) },
+			want:    map[Principal]bool{admin: true, customer: false, stranger: false, shop: false, otherShop: false},
+		},
+		{
+			name:    "CanAcceptRedemption of a shop",
+			allowed: func(p Principal) bool { return CanAcceptRedemption(p, 2) },
+			want:    map[Principal]bool{admin: true, customer: false, stranger: false, shop: true, otherShop: false},
+		},
+		{
+			name:    "CanAcceptRedemption valid at every shop",
+			allowed: func(p Principal) bool { return CanAcceptRedemption(p, 0) },
+			want:    map[Principal]bool{admin: true, customer: false, stranger: false, shop: true, otherShop: true},
+		},
+		{
+			name:    "CanViewEarnings of a shop's customer",
+			allowed: func(p Principal) bool { return CanViewEarnings(p, 2, true) },
+			want:    map[Principal]bool{admin: true, customer: true, stranger: false, shop: true, otherShop: true},
+		},
+		{
+			name:    "CanViewEarnings of someone else's customer",
+			allowed: func(p Principal) bool { return CanViewEarnings(p, 2, false) },
+			want:    map[Principal]bool{admin: true, customer: true, stranger: false, shop: false, otherShop: false},
+		},
+		{
+			name:    "CanLookupCustomers",
+			allowed: CanLookupCustomers,
+			want:    map[Principal]bool{admin: true, customer: false, stranger: false, shop: true, otherShop: true},
+		},
+		{
+			name:    "admin only",
+			allowed: func(p Principal) bool { return CanManageChallenges(p) && CanManagePointsRules(p) && CanSetPoints(p) },
+			want:    map[Principal]bool{admin: true, customer: false, stranger: false, shop: false, otherShop: false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for principal, want := range tt.want {
+				if got := tt.allowed(principal); got != want {
+					t.Errorf("%s %d (%s): allowed = %v, want %v", principal.Kind, principal.ID, principal.Role, got, want)
+				}
+			}
+		})
+	}
+}
+
+// A shop's ID never makes it an admin, nor a user with the shop's ID.
+func TestPrincipalKinds(t *testing.T) {
+	tests := []struct {
+		principal             Principal
+		isUser, isShop, admin bool
+	}{
+		{admin, true, false, true},
+		{customer, true, false, false},
+		{shop, false, true, false},
+		{Principal{Kind: KindShop, ID: 1, Role: RoleAdmin}, false, true, false},
+	}
+	for _, tt := range tests {
+		p := tt.principal
+		if p.IsUser() != tt.isUser || p.IsShop() != tt.isShop || p.IsAdmin() != tt.admin {
+			t.Errorf("%+v: user %v, shop %v, admin %v; want %v, %v, %v",
+				p, p.IsUser(), p.IsShop(), p.IsAdmin(), tt.isUser, tt.isShop, tt.admin)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package auth
+
+import "context"
+
+const (
+	KindUser = "user"
+	KindShop = "shop"
+)
+
+// Principal identifies the authenticated caller of a request.
+type Principal struct {
+	Kind      string
+	ID        int
+	Role      string
+	SessionID int
+}
+
+// RoleFor returns the role of a principal whose account row stores role;
+// shops are always shopkeepers.
+func RoleFor(kind, role string) string {
+	if kind == KindShop {
+		return RoleShopkeeper
+	}
+	if role == "" {
+		return RoleCustomer
+	}
+	return role
+}
+
+func (p Principal) IsUser() bool {
+	return p.Kind == KindUser
+}
+
+func (p Principal) IsShop() bool {
+	return p.Kind == KindShop
+}
+
+func (p Principal) IsAdmin() bool {
+	return p.Kind == KindUser && p.Role == RoleAdmin
+}
+
+type contextKey struct{}
+
+func WithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, contextKey{}, principal)
+}
+
+func FromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(contextKey{}).(Principal)
+	return principal, ok
+}
@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+// Claims is the payload carried by an access token.
+type Claims struct {
+	Kind      string `json:"kind"`
+	Subject   int    `json:"sub"`
+	Role      string `json:"role"`
+	SessionID int    `json:"sid"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// TokenSigner issues and verifies HMAC-SHA256 signed access tokens in the
+// compact JWT format, so they can be inspected with standard tooling.
+type TokenSigner struct {
+	secret []byte
+	ttl    time.Duration
+	now    func() time.Time
+	active SessionCheck
+}
+
+// SessionCheck reports whether the session with the given ID is still open.
+type SessionCheck func(sessionID int) (bool, error)
+
+func NewTokenSigner(secret []byte, ttl time.Duration) *TokenSigner {
+	return &TokenSigner{secret: secret, ttl: ttl, now: time.Now}
+}
+
+// TTL returns how long issued access tokens stay valid.
+func (s *TokenSigner) TTL() time.Duration {
+	return s.ttl
+}
+
+// CheckSessions makes Verify reject the tokens of sessions that active
+// reports closed, so logging out ends a session at once rather than when
+// its access token expires.
+func (s *TokenSigner) CheckSessions(active SessionCheck) {
+	s.active = active
+}
+
+var tokenHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+func (s *TokenSigner) Sign(principal Principal, sessionID int) (string, time.Time, error) {
+	now := s.now()
+	expiresAt := now.Add(s.ttl)
+
+	payload, err := json.Marshal(Claims{
+		Kind:      principal.Kind,
+		Subject:   principal.ID,
+		Role:      principal.Role,
+		SessionID: sessionID,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: expiresAt.Unix(),
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	unsigned := tokenHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + s.signature(unsigned), expiresAt, nil
+}
+
+// Verify checks the signature and expiry of token, and that its session is
+// open, and returns its claims. It returns ErrInvalidToken for a token that
+// fails a check, and any other error when the session cannot be looked up.
+func (s *TokenSigner) Verify(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != tokenHeader {
+		return nil, ErrInvalidToken
+	}
+
+	expected := s.signature(parts[0] + "." + parts[1])
+	if !hmac.Equal([]byte(expected), []byte(parts[2])) {
+		return nil, ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	if s.now().Unix() >= claims.ExpiresAt {
+		return nil, ErrInvalidToken
+	}
+
+	if s.active != nil {
+		active, err := s.active(claims.SessionID)
+		if err != nil {
+			return nil, err
+		}
+		if !active {
+			return nil, ErrInvalidToken
+		}
+	}
+
+	return &claims, nil
+}
+
+func (s *TokenSigner) signature(unsigned string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(unsigned))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokenSignVerify(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	signer := NewTokenSigner([]byte("secret"), time.Hour)
+	signer.now = func() time.Time { return now }
+
+	principal := Principal{Kind: KindShop, ID: 7, Role: RoleShopkeeper}
+	token, expiresAt, err := signer.Sign(principal, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := now.Add(time.Hour); !expiresAt.Equal(want) {
+		t.Errorf("expires at %v, want %v", expiresAt, want)
+	}
+
+	claims, err := signer.Verify(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Claims{Kind: KindShop, Subject: 7, Role: RoleShopkeeper, SessionID: 3,
+		IssuedAt: now.Unix(), ExpiresAt: now.Add(time.Hour).Unix()}
+	if *claims != want {
+		t.Errorf("claims = %+v, want %+v", *claims, want)
+	}
+}
+
+func TestTokenVerifyRejects(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	signer := NewTokenSigner([]byte("secret"), time.Hour)
+	signer.now = func() time.Time { return now }
+
+	token, _, err := signer.Sign(Principal{Kind: KindUser, ID: 5, Role: RoleCustomer}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, rest, _ := strings.Cut(token, ".")
+	payload, signature, _ := strings.Cut(rest, ".")
+
+	// The payload of an admin token, signed with the same secret
+	admin, _, err := signer.Sign(Principal{Kind: KindUser, ID: 5, Role: RoleAdmin}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	adminPayload := strings.Split(admin, ".")[1]
+
+	tests := []struct {
+		name   string
+		token  string
+		signer *TokenSigner
+		after  time.Duration
+	}{
+		{"expired", token, signer, time.Hour},
+		{"tampered payload", header + "." + adminPayload + "." + signature, signer, 0},
+		{"tampered signature", header + "." + payload + "." + strings.Repeat("A", len(signature)), signer, 0},
+		{"other secret", token, NewTokenSigner([]byte("other"), time.Hour), 0},
+		{"other header", "e30." + payload + "." + signature, signer, 0},
+		{"not a token", "token", signer, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.signer.now = func() time.Time { return now.Add(tt.after) }
+			if _, err := tt.signer.Verify(tt.token); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("err = %v, want ErrInvalidToken", err)
+			}
+		})
+	}
+}
+
+// The token of a closed session is refused; a failed lookup is not
+// mistaken for one.
+func TestTokenVerifyChecksSession(t *testing.T) {
+	signer := NewTokenSigner([]byte("secret"), time.Hour)
+	lookupErr := errors.New("database is down")
+	open := map[int]bool{1: true}
+	signer.CheckSessions(func(sessionID int) (bool, error) {
+		if sessionID == 3 {
+			return false, lookupErr
+		}
+		return open[sessionID], nil
+	})
+
+	tests := []struct {
+		name      string
+		sessionID int
+		want      error
+	}{
+		{"open", 1, nil},
+		{"closed", 2, ErrInvalidToken},
+		{"lookup fails", 3, lookupErr},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, _, err := signer.Sign(Principal{Kind: KindUser, ID: 5, Role: RoleCustomer}, tt.sessionID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := signer.Verify(token); !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"ecotracker-backend/catalogue"
+	"ecotracker-backend/models"
+	"ecotracker-backend/services"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const catalogueUsage = "usage: main catalogue import [-upsert] [-dry-run] [-format csv|json] <shop-id> <file>\n" +
+	"       main catalogue export [-archived] [-format csv|json] <shop-id> [file]"
+
+// runCatalogue implements "main catalogue import", which adds the items of a
+// CSV or JSON file to a shop's catalogue the way the import endpoint does,
+// and "main catalogue export", which writes the catalogue to a file or to
+// standard output. The format follows the file extension unless -format is
+// given; "-" stands for standard input or output.
+func runCatalogue(shopService *services.ShopService, args []string) error {
+	if len(args) == 0 {
+		return errors.New(catalogueUsage)
+	}
+
+	flags := flag.NewFlagSet("catalogue "+args[0], flag.ContinueOnError)
+	format := flags.String("format", "", "csv or json, by default from the file extension")
+
+	switch args[0] {
+	case "import":
+		upsert := flags.Bool("upsert", false, "replace the items with the same SKU")
+		dryRun := flags.Bool("dry-run", false, "check the file without saving anything")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		if flags.NArg() != 2 {
+			return errors.New(catalogueUsage)
+		}
+		shopID, err := parseShopID(flags.Arg(0))
+		if err != nil {
+			return err
+		}
+		path := flags.Arg(1)
+
+		in := io.Reader(os.Stdin)
+		if path != "-" {
+			file, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			in = file
+		}
+
+		fileFormat, err := catalogueFileFormat(*format, path)
+		if err != nil {
+			return err
+		}
+		items, err := catalogue.Read(in, fileFormat)
+		if err != nil {
+			return importProblems(err)
+		}
+		result, err := shopService.ImportItems(shopID, items, models.ItemImportOptions{Upsert: *upsert, DryRun: *dryRun})
+		if err != nil {
+			return importProblems(err)
+		}
+
+		if result.DryRun {
+			fmt.Printf("Dry run: %d items would be created and %d updated\n", result.Created, result.Updated)
+		} else {
+			fmt.Printf("Created %d items and updated %d\n", result.Created, result.Updated)
+		}
+		return nil
+
+	case "export":
+		archived := flags.Bool("archived", false, "include archived items")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		if flags.NArg() < 1 || flags.NArg() > 2 {
+			return errors.New(catalogueUsage)
+		}
+		shopID, err := parseShopID(flags.Arg(0))
+		if err != nil {
+			return err
+		}
+		path := flags.Arg(1)
+
+		fileFormat, err := catalogueFileFormat(*format, path)
+		if err != nil {
+			return err
+		}
+		if _, err := shopService.GetShop(shopID); err != nil {
+			return err
+		}
+		items, err := shopService.GetItems(shopID, *archived)
+		if err != nil {
+			return err
+		}
+
+		if path == "" || path == "-" {
+			return catalogue.Write(os.Stdout, fileFormat, items)
+		}
+		file, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		if err := catalogue.Write(file, fileFormat, items); err != nil {
+			file.Close()
+			return err
+		}
+		return file.Close()
+	}
+
+	return errors.New(catalogueUsage)
+}
+
+func parseShopID(arg string) (int, error) {
+	id, err := strconv.Atoi(arg)
+	if err != nil || id < 1 {
+		return 0, fmt.Errorf("invalid shop id %q", arg)
+	}
+	return id, nil
+}
+
+// catalogueFileFormat is the -format flag, or CSV for a .csv file and JSON
+// otherwise.
+func catalogueFileFormat(format, path string) (string, error) {
+	if format != "" {
+		if !catalogue.IsFormat(format) {
+			return "", fmt.Errorf("invalid format %q", format)
+		}
+		return format, nil
+	}
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return catalogue.CSV, nil
+	}
+	return catalogue.JSON, nil
+}
+
+// importProblems prints the problems of a rejected import one per line.
+func importProblems(err error) error {
+	var validationErr *services.ValidationError
+	if !errors.As(err, &validationErr) {
+		return err
+	}
+
+	fields := make([]string, 0, len(validationErr.Fields))
+	for field := range validationErr.Fields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		fmt.Printf("%s: %s\n", field, validationErr.Fields[field])
+	}
+	return fmt.Errorf("%d problems found, nothing imported", len(fields))
+}
@@ -0,0 +1,255 @@
+// Package catalogue reads and writes shop catalogues as CSV or JSON, for
+// bulk imports and exports through the API and the command line.
+package catalogue
+
+import (
+	"ecotracker-backend/models"
+	"ecotracker-backend/services"
+	"ecotracker-backend/validation"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Formats of a catalogue file.
+const (
+	CSV  = "csv"
+	JSON = "json"
+)
+
+// IsFormat reports whether format is CSV or JSON.
+func IsFormat(format string) bool {
+	return format == CSV || format == JSON
+}
+
+// Columns is the CSV header written by Write. Read accepts the columns in
+// any order; all but name, price and category may be left out.
+var Columns = []string{"sku", "barcode", "name", "price", "currency", "category", "description", "is_eco_friendly"}
+
+var requiredColumns = []string{"name", "price", "category"}
+
+// MaxItems is the most items one import may hold.
+const MaxItems = 5000
+
+// Read decodes a catalogue and validates every item. Invalid items are
+// reported together in a *services.ValidationError keyed by item index, such
+// as "items[3].price"; in a CSV file items[0] is the row after the header.
+// Each item's Fields are the CSV file's columns or the item's JSON fields.
+func Read(r io.Reader, format string) ([]models.CatalogueItem, error) {
+	var items []models.CatalogueItem
+	var problems map[string]string
+	var err error
+	if format == CSV {
+		items, problems, err = readCSV(r)
+	} else {
+		items, problems, err = readJSON(r)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(items) > MaxItems {
+		return nil, services.NewValidationError("items", fmt.Sprintf("must contain at most %d entries", MaxItems))
+	}
+	if len(items) == 0 && len(problems) == 0 {
+		return nil, services.NewValidationError("items", "must contain at least 1 entries")
+	}
+
+	for i := range items {
+		// Rows that could not be read at all have nothing to validate
+		field := fmt.Sprintf("items[%d]", i)
+		if _, ok := problems[field]; ok {
+			continue
+		}
+
+		var validationErr *services.ValidationError
+		if err := validation.Struct(&items[i].ShopItem); errors.As(err, &validationErr) {
+			for name, problem := range validationErr.Fields {
+				// Keep the more precise problem found while parsing
+				if _, ok := problems[field+"."+name]; !ok {
+					problems[field+"."+name] = problem
+				}
+			}
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, &services.ValidationError{Fields: problems}
+	}
+	return items, nil
+}
+
+func readCSV(r io.Reader) ([]models.CatalogueItem, map[string]string, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil, services.NewValidationError("header", "is required")
+	}
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return nil, nil, services.NewValidationError("header", parseErr.Error())
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Spreadsheets often save CSV with a byte order mark
+	header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	columns := make(map[string]int, len(header))
+	fields := make([]string, 0, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !slices.Contains(Columns, name) {
+			return nil, nil, services.NewValidationError("header", fmt.Sprintf("has unknown column %q", name))
+		}
+		if _, ok := columns[name]; ok {
+			return nil, nil, services.NewValidationError("header", fmt.Sprintf("repeats column %q", name))
+		}
+		columns[name] = i
+		fields = append(fields, name)
+	}
+	for _, name := range requiredColumns {
+		if _, ok := columns[name]; !ok {
+			return nil, nil, services.NewValidationError("header", fmt.Sprintf("is missing column %q", name))
+		}
+	}
+
+	var items []models.CatalogueItem
+	problems := make(map[string]string)
+	for i := 0; ; i++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+
+		field := fmt.Sprintf("items[%d]", i)
+		if errors.As(err, &parseErr) {
+			if errors.Is(parseErr.Err, csv.ErrFieldCount) {
+				problems[field] = fmt.Sprintf("has %d columns, not %d", len(record), len(header))
+				items = append(items, models.CatalogueItem{})
+				continue
+			}
+			// The rest of the file cannot be split into rows reliably
+			problems[field] = parseErr.Error()
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		value := func(name string) string {
+			if i, ok := columns[name]; ok {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		item := models.ShopItem{
+			SKU:         value("sku"),
+			Barcode:     value("barcode"),
+			Name:        value("name"),
+			Currency:    value("currency"),
+			Category:    value("category"),
+			Description: value("description"),
+		}
+		if price := value("price"); price != "" {
+			amount, err := models.ParseDecimal(price)
+			if err != nil {
+				problems[field+".price"] = "must be a decimal amount"
+			}
+			item.Price = models.Money{Amount: amount, Currency: item.Currency}
+		}
+		if eco := value("is_eco_friendly"); eco != "" {
+			isEcoFriendly, err := strconv.ParseBool(eco)
+			if err != nil {
+				problems[field+".is_eco_friendly"] = "must be true or false"
+			}
+			item.IsEcoFriendly = isEcoFriendly
+		}
+		items = append(items, models.CatalogueItem{ShopItem: item, Fields: fields})
+	}
+
+	return items, problems, nil
+}
+
+func readJSON(r io.Reader) ([]models.CatalogueItem, map[string]string, error) {
+	var raw []json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) || err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, nil, services.NewValidationError("items", "must be a JSON array of items")
+		}
+		return nil, nil, err
+	}
+
+	items := make([]models.CatalogueItem, len(raw))
+	problems := make(map[string]string)
+	for i, data := range raw {
+		field := fmt.Sprintf("items[%d]", i)
+		if err := json.Unmarshal(data, &items[i].ShopItem); err != nil {
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &typeErr) && typeErr.Field != "" {
+				problems[field+"."+typeErr.Field] = "must be a " + typeErr.Type.String()
+			} else {
+				problems[field] = "is not a valid item"
+			}
+			continue
+		}
+
+		// Field names match case-insensitively, as they do when decoding
+		var present map[string]json.RawMessage
+		if err := json.Unmarshal(data, &present); err != nil {
+			return nil, nil, err
+		}
+		for name := range present {
+			name = strings.ToLower(name)
+			if slices.Contains(Columns, name) && !slices.Contains(items[i].Fields, name) {
+				items[i].Fields = append(items[i].Fields, name)
+			}
+		}
+	}
+
+	return items, problems, nil
+}
+
+// Write encodes items in format, with the columns and fields Read accepts.
+func Write(w io.Writer, format string, items []models.ShopItem) error {
+	if format == JSON {
+		if items == nil {
+			items = []models.ShopItem{}
+		}
+		return json.NewEncoder(w).Encode(items)
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(Columns); err != nil {
+		return err
+	}
+	for _, item := range items {
+		err := writer.Write([]string{
+			item.SKU,
+			item.Barcode,
+			item.Name,
+			item.Price.Decimal(),
+			item.Currency,
+			item.Category,
+			item.Description,
+			strconv.FormatBool(item.IsEcoFriendly),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,52 @@
+package catalogue
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// Read reports the fields each item was given, so an upsert can leave the
+// rest alone.
+func TestReadFields(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		input  string
+		want   [][]string
+	}{
+		{
+			name:   "csv columns",
+			format: CSV,
+			input:  "SKU,name,price,category\nA1,Soap,2.99,General\nA2,Jam,3.50,Food\n",
+			want:   [][]string{{"sku", "name", "price", "category"}, {"sku", "name", "price", "category"}},
+		},
+		{
+			name:   "json fields per item",
+			format: JSON,
+			input:  `[{"sku":"A1","name":"Soap","price":2.99,"category":"General"},{"SKU":"A2","name":"Jam","price":3.5,"category":"Food","barcode":""}]`,
+			want:   [][]string{{"category", "name", "price", "sku"}, {"barcode", "category", "name", "price", "sku"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items, err := Read(strings.NewReader(tt.input), tt.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(items) != len(tt.want) {
+				t.Fatalf("read %d items, want %d", len(items), len(tt.want))
+			}
+			for i, item := range items {
+				fields := slices.Clone(item.Fields)
+				want := slices.Clone(tt.want[i])
+				slices.Sort(fields)
+				slices.Sort(want)
+				if !slices.Equal(fields, want) {
+					t.Errorf("items[%d] fields = %v, want %v", i, fields, want)
+				}
+			}
+		})
+	}
+}
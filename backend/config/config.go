@@ -0,0 +1,258 @@
+// Package config loads the server settings. Values come from built-in
+// defaults, then an optional JSON file, then environment variables, then
+// command line flags, each layer overriding the previous one.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"ecotracker-backend/database"
+)
+
+type Config struct {
+	Port           string          `json:"port"`
+	CORSOrigins    []string        `json:"cors_origins"`
+	Database       database.Config `json:"database"`
+	Auth           AuthConfig      `json:"auth"`
+	PasswordHasher string          `json:"password_hasher"`
+	AdminEmails    []string        `json:"admin_emails"`
+	// PointsRules is a JSON file with the points rules used until an admin
+	// saves others through the API. Empty means the built-in rules.
+	PointsRules string `json:"points_rules"`
+	// IdempotencyTTL is how long Idempotency-Key headers are remembered.
+	IdempotencyTTL Duration `json:"idempotency_ttl"`
+}
+
+type AuthConfig struct {
+	Secret     string   `json:"secret"`
+	AccessTTL  Duration `json:"access_ttl"`
+	RefreshTTL Duration `json:"refresh_ttl"`
+}
+
+// Duration is a time.Duration written as "15m" or "720h" in the file.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func Default() Config {
+	return Config{
+		Port:        "8000",
+		CORSOrigins: []string{"*"},
+		Database: database.Config{
+			Driver:        database.DriverSQLite,
+			Path:          "./data/ecotracker.db",
+			JournalMode:   "WAL",
+			BusyTimeoutMS: 5000,
+			ForeignKeys:   true,
+		},
+		Auth: AuthConfig{
+			AccessTTL:  Duration(15 * time.Minute),
+			RefreshTTL: Duration(30 * 24 * time.Hour),
+		},
+		PasswordHasher: "bcrypt",
+		IdempotencyTTL: Duration(24 * time.Hour),
+	}
+}
+
+// Load builds the configuration from args (normally os.Args[1:]) and the
+// environment. It returns the arguments left after the flags, such as a
+// subcommand.
+func Load(args []string) (*Config, []string, error) {
+	cfg := Default()
+
+	flags := flag.NewFlagSet("ecotracker", flag.ContinueOnError)
+	configFile := flags.String("config", os.Getenv("CONFIG_FILE"), "path to a JSON config file")
+	port := flags.String("port", "", "HTTP port")
+	dbDriver := flags.String("db-driver", "", "database driver, sqlite or postgres")
+	dbPath := flags.String("db-path", "", "SQLite database file")
+	dbDSN := flags.String("db-dsn", "", "database DSN, overrides -db-path")
+	corsOrigins := flags.String("cors-origins", "", "comma-separated allowed CORS origins")
+	pointsRules := flags.String("points-rules", "", "JSON file with the points rules")
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	if *configFile != "" {
+		if err := cfg.loadFile(*configFile); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := cfg.loadEnv(); err != nil {
+		return nil, nil, err
+	}
+
+	setString(&cfg.Port, *port)
+	setString(&cfg.Database.Driver, *dbDriver)
+	setString(&cfg.Database.Path, *dbPath)
+	setString(&cfg.Database.DSN, *dbDSN)
+	setString(&cfg.PointsRules, *pointsRules)
+	if *corsOrigins != "" {
+		cfg.CORSOrigins = splitList(*corsOrigins)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	return &cfg, flags.Args(), nil
+}
+
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(c); err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func (c *Config) loadEnv() error {
+	setString(&c.Port, os.Getenv("PORT"))
+	setString(&c.Database.Driver, os.Getenv("DB_DRIVER"))
+	setString(&c.Database.Path, os.Getenv("DB_PATH"))
+	setString(&c.Database.DSN, os.Getenv("DB_DSN"))
+	setString(&c.Database.JournalMode, os.Getenv("DB_JOURNAL_MODE"))
+	setString(&c.Auth.Secret, os.Getenv("AUTH_SECRET"))
+	setString(&c.PasswordHasher, os.Getenv("PASSWORD_HASHER"))
+	setString(&c.PointsRules, os.Getenv("POINTS_RULES_FILE"))
+
+	if v := os.Getenv("DB_BUSY_TIMEOUT"); v != "" {
+		timeout, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("DB_BUSY_TIMEOUT: %w", err)
+		}
+		c.Database.BusyTimeoutMS = int(timeout / time.Millisecond)
+	}
+	if v := os.Getenv("DB_FOREIGN_KEYS"); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("DB_FOREIGN_KEYS: %w", err)
+		}
+		c.Database.ForeignKeys = enabled
+	}
+	if v := os.Getenv("IDEMPOTENCY_TTL"); v != "" {
+		ttl, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("IDEMPOTENCY_TTL: %w", err)
+		}
+		c.IdempotencyTTL = Duration(ttl)
+	}
+	if v := os.Getenv("CORS_ORIGINS"); v != "" {
+		c.CORSOrigins = splitList(v)
+	}
+	if v := os.Getenv("ADMIN_EMAILS"); v != "" {
+		c.AdminEmails = splitList(v)
+	}
+	return nil
+}
+
+// Validate reports every invalid setting at once.
+func (c *Config) Validate() error {
+	var problems []string
+
+	if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+		problems = append(problems, fmt.Sprintf("port %q must be a number between 1 and 65535", c.Port))
+	}
+	if err := c.Database.Validate(); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if len(c.CORSOrigins) == 0 {
+		problems = append(problems, "cors_origins must not be empty")
+	}
+	for _, origin := range c.CORSOrigins {
+		if origin == "*" {
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			problems = append(problems, fmt.Sprintf("cors origin %q must be * or an http(s) origin", origin))
+		}
+	}
+	if c.PasswordHasher != "bcrypt" && c.PasswordHasher != "argon2id" {
+		problems = append(problems, fmt.Sprintf("password_hasher %q must be bcrypt or argon2id", c.PasswordHasher))
+	}
+	if c.Auth.AccessTTL <= 0 || c.Auth.RefreshTTL <= 0 {
+		problems = append(problems, "auth token lifetimes must be positive")
+	}
+	if c.IdempotencyTTL <= 0 {
+		problems = append(problems, "idempotency_ttl must be positive")
+	}
+
+	if len(problems) > 0 {
+		return errors.New("invalid configuration: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// SingleCORSOrigin returns the allowed CORS origin for a server that sends
+// it as the Access-Control-Allow-Origin header unchanged, as GoFr does. The
+// header holds one origin or *, so such a server cannot allow a list.
+func (c *Config) SingleCORSOrigin() (string, error) {
+	if len(c.CORSOrigins) != 1 {
+		return "", fmt.Errorf("invalid configuration: cors_origins must be one origin or * for this server, not %d origins", len(c.CORSOrigins))
+	}
+	return c.CORSOrigins[0], nil
+}
+
+// String renders the effective configuration for the startup log with
+// secrets masked.
+func (c Config) String() string {
+	secret := "(random)"
+	if c.Auth.Secret != "" {
+		secret = "(set)"
+	}
+
+	pointsRules := c.PointsRules
+	if pointsRules == "" {
+		pointsRules = "(default)"
+	}
+
+	return fmt.Sprintf("port=%s cors_origins=%s database=[%s] auth=[secret=%s access_ttl=%s refresh_ttl=%s] password_hasher=%s admin_emails=%d points_rules=%s idempotency_ttl=%s",
+		c.Port, strings.Join(c.CORSOrigins, ","), c.Database, secret,
+		c.Auth.AccessTTL, c.Auth.RefreshTTL, c.PasswordHasher, len(c.AdminEmails), pointsRules, c.IdempotencyTTL)
+}
+
+func setString(target *string, value string) {
+	if value != "" {
+		*target = value
+	}
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
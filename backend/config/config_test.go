@@ -0,0 +1,303 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"ecotracker-backend/database"
+)
+
+func TestSingleCORSOrigin(t *testing.T) {
+	tests := []struct {
+		name    string
+		origins []string
+		want    string
+		wantErr bool
+	}{
+		{"any origin", []string{"*"}, "*", false},
+		{"one origin", []string{"https://app.example.com"}, "https://app.example.com", false},
+		{"several origins", []string{"https://a.example.com", "https://b.example.com"}, "", true},
+		{"no origin", nil, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			cfg.CORSOrigins = tt.origins
+
+			got, err := cfg.SingleCORSOrigin()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("origin = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// clearEnv unsets the variables Load reads, so the tests see only what they
+// set themselves.
+func clearEnv(t *testing.T) {
+	t.Helper()
+
+	for _, name := range []string{
+		"CONFIG_FILE", "PORT", "DB_DRIVER", "DB_PATH", "DB_DSN", "DB_JOURNAL_MODE",
+		"AUTH_SECRET", "PASSWORD_HASHER", "POINTS_RULES_FILE", "DB_BUSY_TIMEOUT",
+		"DB_FOREIGN_KEYS", "IDEMPOTENCY_TTL", "CORS_ORIGINS", "ADMIN_EMAILS",
+	} {
+		t.Setenv(name, "")
+	}
+}
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadDefaults(t *testing.T) {
+	clearEnv(t)
+
+	cfg, rest, err := Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*cfg, Default()) {
+		t.Errorf("config = %+v, want the defaults %+v", *cfg, Default())
+	}
+	if len(rest) != 0 {
+		t.Errorf("remaining arguments = %q, want none", rest)
+	}
+}
+
+// The file overrides the defaults, the environment the file, and flags the
+// environment.
+func TestLoadPrecedence(t *testing.T) {
+	file := writeFile(t, `{"port": "8001", "database": {"path": "file.db"}, "cors_origins": ["https://file.example.com"], "auth": {"access_ttl": "5m"}}`)
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		args    []string
+		port    string
+		path    string
+		origins []string
+	}{
+		{
+			name:    "defaults",
+			port:    "8000",
+			path:    "./data/ecotracker.db",
+			origins: []string{"*"},
+		},
+		{
+			name:    "file",
+			env:     map[string]string{"CONFIG_FILE": file},
+			port:    "8001",
+			path:    "file.db",
+			origins: []string{"https://file.example.com"},
+		},
+		{
+			name:    "file named by flag",
+			args:    []string{"-config", file},
+			port:    "8001",
+			path:    "file.db",
+			origins: []string{"https://file.example.com"},
+		},
+		{
+			name:    "environment over file",
+			env:     map[string]string{"CONFIG_FILE": file, "PORT": "8002", "CORS_ORIGINS": "https://a.example.com, https://b.example.com"},
+			port:    "8002",
+			path:    "file.db",
+			origins: []string{"https://a.example.com", "https://b.example.com"},
+		},
+		{
+			name:    "flags over environment",
+			env:     map[string]string{"CONFIG_FILE": file, "PORT": "8002", "DB_PATH": "env.db"},
+			args:    []string{"-port", "8003", "-cors-origins", "https://flag.example.com"},
+			port:    "8003",
+			path:    "env.db",
+			origins: []string{"https://flag.example.com"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			cfg, _, err := Load(tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Port != tt.port || cfg.Database.Path != tt.path || !reflect.DeepEqual(cfg.CORSOrigins, tt.origins) {
+				t.Errorf("port %s, path %s, origins %q; want %s, %s, %q",
+					cfg.Port, cfg.Database.Path, cfg.CORSOrigins, tt.port, tt.path, tt.origins)
+			}
+			// Settings no layer mentions keep their defaults
+			if cfg.PasswordHasher != "bcrypt" || cfg.Auth.RefreshTTL != Default().Auth.RefreshTTL {
+				t.Errorf("password hasher %s, refresh ttl %s; want the defaults", cfg.PasswordHasher, cfg.Auth.RefreshTTL)
+			}
+		})
+	}
+}
+
+func TestLoadEnv(t *testing.T) {
+	clearEnv(t)
+	for name, value := range map[string]string{
+		"DB_DRIVER":         "postgres",
+		"DB_DSN":            "postgres://localhost/eco",
+		"DB_JOURNAL_MODE":   "DELETE",
+		"DB_BUSY_TIMEOUT":   "2s",
+		"DB_FOREIGN_KEYS":   "false",
+		"AUTH_SECRET":       "secret",
+		"PASSWORD_HASHER":   "argon2id",
+		"POINTS_RULES_FILE": "rules.json",
+		"IDEMPOTENCY_TTL":   "1h",
+		"ADMIN_EMAILS":      "a@example.com,,b@example.com ",
+	} {
+		t.Setenv(name, value)
+	}
+
+	cfg, _, err := Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Default()
+	want.Database = database.Config{
+		Driver:        database.DriverPostgres,
+		Path:          want.Database.Path,
+		DSN:           "postgres://localhost/eco",
+		JournalMode:   "DELETE",
+		BusyTimeoutMS: 2000,
+		ForeignKeys:   false,
+	}
+	want.Auth.Secret = "secret"
+	want.PasswordHasher = "argon2id"
+	want.PointsRules = "rules.json"
+	want.IdempotencyTTL = Duration(time.Hour)
+	want.AdminEmails = []string{"a@example.com", "b@example.com"}
+	if !reflect.DeepEqual(*cfg, want) {
+		t.Errorf("config = %+v\nwant %+v", *cfg, want)
+	}
+}
+
+// Arguments after the flags, such as a subcommand, are handed back.
+func TestLoadRemainingArgs(t *testing.T) {
+	clearEnv(t)
+
+	cfg, rest, err := Load([]string{"-db-path", "eco.db", "reconcile", "-fix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Database.Path != "eco.db" || !reflect.DeepEqual(rest, []string{"reconcile", "-fix"}) {
+		t.Errorf("path %s, remaining arguments %q; want eco.db and the subcommand", cfg.Database.Path, rest)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		file string
+		args []string
+		want string
+	}{
+		{name: "unknown flag", args: []string{"-verbose"}, want: "flag provided but not defined"},
+		{name: "missing file", args: []string{"-config", "/nonexistent/config.json"}, want: "config file"},
+		{name: "unknown file field", file: `{"prot": "8001"}`, want: `unknown field "prot"`},
+		{name: "bad file duration", file: `{"idempotency_ttl": "a day"}`, want: "config file"},
+		{name: "bad busy timeout", env: map[string]string{"DB_BUSY_TIMEOUT": "5000"}, want: "DB_BUSY_TIMEOUT"},
+		{name: "bad foreign keys", env: map[string]string{"DB_FOREIGN_KEYS": "maybe"}, want: "DB_FOREIGN_KEYS"},
+		{name: "bad idempotency ttl", env: map[string]string{"IDEMPOTENCY_TTL": "soon"}, want: "IDEMPOTENCY_TTL"},
+		{name: "invalid value", args: []string{"-db-driver", "mysql"}, want: `database driver "mysql"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			if tt.file != "" {
+				t.Setenv("CONFIG_FILE", writeFile(t, tt.file))
+			}
+
+			_, _, err := Load(tt.args)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want one mentioning %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(*Config)
+		want   string
+	}{
+		{"port zero", func(c *Config) { c.Port = "0" }, `port "0"`},
+		{"port too high", func(c *Config) { c.Port = "65536" }, `port "65536"`},
+		{"port name", func(c *Config) { c.Port = "http" }, `port "http"`},
+		{"unknown driver", func(c *Config) { c.Database.Driver = "mysql" }, `database driver "mysql"`},
+		{"postgres without dsn", func(c *Config) { c.Database.Driver = database.DriverPostgres }, "dsn is required"},
+		{"sqlite without path", func(c *Config) { c.Database.Path = "" }, "database path or dsn is required"},
+		{"journal mode", func(c *Config) { c.Database.JournalMode = "fast" }, `journal_mode "fast"`},
+		{"busy timeout", func(c *Config) { c.Database.BusyTimeoutMS = -1 }, "busy_timeout_ms"},
+		{"no cors origins", func(c *Config) { c.CORSOrigins = nil }, "cors_origins must not be empty"},
+		{"cors origin without scheme", func(c *Config) { c.CORSOrigins = []string{"app.example.com"} }, `cors origin "app.example.com"`},
+		{"cors origin scheme", func(c *Config) { c.CORSOrigins = []string{"*", "ftp://example.com"} }, `cors origin "ftp://example.com"`},
+		{"password hasher", func(c *Config) { c.PasswordHasher = "md5" }, `password_hasher "md5"`},
+		{"access ttl", func(c *Config) { c.Auth.AccessTTL = 0 }, "token lifetimes"},
+		{"refresh ttl", func(c *Config) { c.Auth.RefreshTTL = Duration(-time.Hour) }, "token lifetimes"},
+		{"idempotency ttl", func(c *Config) { c.IdempotencyTTL = 0 }, "idempotency_ttl"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			tt.change(&cfg)
+
+			err := cfg.Validate()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want one mentioning %q", err, tt.want)
+			}
+		})
+	}
+
+	// Valid alternatives to the defaults
+	cfg := Default()
+	cfg.Database = database.Config{Driver: database.DriverPostgres, DSN: "postgres://localhost/eco"}
+	cfg.CORSOrigins = []string{"http://localhost:3000", "https://app.example.com"}
+	cfg.PasswordHasher = "argon2id"
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("valid configuration: %v", err)
+	}
+}
+
+// Every problem is reported at once.
+func TestValidateReportsAllProblems(t *testing.T) {
+	cfg := Default()
+	cfg.Port = ""
+	cfg.PasswordHasher = ""
+	cfg.IdempotencyTTL = 0
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Validate accepted an invalid configuration")
+	}
+	for _, want := range []string{"port", "password_hasher", "idempotency_ttl"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("err = %v, want it to mention %s", err, want)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package database
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Drivers the server can store its data in.
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+)
+
+// Config describes where the database lives and how connections are set up.
+type Config struct {
+	// Driver is DriverSQLite or DriverPostgres.
+	Driver string `json:"driver"`
+	// Path of the SQLite file; ignored when DSN is set.
+	Path string `json:"path"`
+	// DSN is passed to the driver unchanged when set; PostgreSQL requires it.
+	// A SQLite DSN should then set _txlock=immediate itself.
+	DSN string `json:"dsn"`
+	// SQLite pragmas applied to every connection.
+	JournalMode   string `json:"journal_mode"`
+	BusyTimeoutMS int    `json:"busy_timeout_ms"`
+	ForeignKeys   bool   `json:"foreign_keys"`
+}
+
+var journalModes = []string{"DELETE", "TRUNCATE", "PERSIST", "MEMORY", "WAL", "OFF"}
+
+func (c Config) Validate() error {
+	switch c.Driver {
+	case DriverSQLite:
+	case DriverPostgres:
+		if c.DSN == "" {
+			return fmt.Errorf("database dsn is required for the postgres driver")
+		}
+		return nil
+	default:
+		return fmt.Errorf("database driver %q must be %s or %s", c.Driver, DriverSQLite, DriverPostgres)
+	}
+
+	if c.Path == "" && c.DSN == "" {
+		return fmt.Errorf("database path or dsn is required")
+	}
+	if c.JournalMode != "" && !contains(journalModes, strings.ToUpper(c.JournalMode)) {
+		return fmt.Errorf("database journal_mode %q must be one of %s", c.JournalMode, strings.Join(journalModes, ", "))
+	}
+	if c.BusyTimeoutMS < 0 {
+		return fmt.Errorf("database busy_timeout_ms must not be negative")
+	}
+	return nil
+}
+
+// dataSourceName returns the DSN, or builds one for Path that applies the
+// configured pragmas through the driver's _pragma parameters.
+func (c Config) dataSourceName() string {
+	if c.DSN != "" {
+		return c.DSN
+	}
+
+	params := url.Values{}
+	if c.JournalMode != "" {
+		params.Add("_pragma", "journal_mode("+strings.ToUpper(c.JournalMode)+")")
+	}
+	params.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", c.BusyTimeoutMS))
+	if c.ForeignKeys {
+		params.Add("_pragma", "foreign_keys(1)")
+	} else {
+		params.Add("_pragma", "foreign_keys(0)")
+	}
+
+	// Store times in SQLite's own format so they sort and compare as text
+	params.Set("_time_format", "sqlite")
+
+	// Take the write lock when a transaction begins. A deferred transaction
+	// that reads first fails with SQLITE_BUSY, without waiting out the busy
+	// timeout, when another connection writes before it does.
+	params.Set("_txlock", "immediate")
+
+	return "file:" + c.Path + "?" + params.Encode()
+}
+
+func (c Config) String() string {
+	if c.DSN != "" {
+		return fmt.Sprintf("driver=%s dsn=(set)", c.Driver)
+	}
+	return fmt.Sprintf("driver=%s path=%s journal_mode=%s busy_timeout_ms=%d foreign_keys=%t",
+		c.Driver, c.Path, c.JournalMode, c.BusyTimeoutMS, c.ForeignKeys)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
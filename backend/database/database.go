@@ -4,120 +4,100 @@ import (
 "database/sql"
 "log"
 "os"
+"path/filepath"
+"strconv"
+"strings"
 
+_ "github.com/lib/pq"
 _ "modernc.org/sqlite"
 )
 
 type Database struct {
-DB *sql.DB
+DB     *sql.DB
+Driver string
 }
 
-func NewDatabase() (*Database, error) {
-// Create database directory if it doesn't exist
-if err := os.MkdirAll("./data", 0755); err != nil {
-return nil, err
+// Querier is implemented by both *sql.DB and *sql.Tx, so a query helper can
+// run on its own or as part of a transaction.
+type Querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
 }
 
-// Open SQLite database
-db, err := sql.Open("sqlite", "./data/ecotracker.db")
-if err != nil {
-return nil, err
+// WithTx runs fn inside a transaction. The transaction is committed when fn
+// returns nil and rolled back when it returns an error or panics.
+func (d *Database) WithTx(fn func(tx *sql.Tx) error) (err error) {
+	tx, err := d.DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err = fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-// Test the connection
-if err := db.Ping(); err != nil {
-return nil, err
+// Rebind rewrites the ? placeholders used throughout the code base into the
+// $1, $2, ... form PostgreSQL expects. SQLite queries are returned unchanged.
+func (d *Database) Rebind(query string) string {
+	if d.Driver != DriverPostgres {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	inString := false
+	for _, r := range query {
+		switch {
+		case r == '\'':
+			inString = !inString
+		case r == '?' && !inString:
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
 }
 
-database := &Database{DB: db}
-
-// Initialize tables
-if err := database.InitTables(); err != nil {
+func NewDatabase(cfg Config) (*Database, error) {
+if err := cfg.Validate(); err != nil {
 return nil, err
 }
 
-log.Println("Database connected successfully")
-return database, nil
+// Create database directory if it doesn't exist
+if cfg.Driver == DriverSQLite && cfg.DSN == "" {
+if err := os.MkdirAll(filepath.Dir(cfg.Path), 0755); err != nil {
+return nil, err
+}
 }
 
-func (d *Database) InitTables() error {
-// Create users table
-usersTable := `
-CREATE TABLE IF NOT EXISTS users (
-id INTEGER PRIMARY KEY AUTOINCREMENT,
-email TEXT UNIQUE NOT NULL,
-password TEXT NOT NULL,
-name TEXT NOT NULL,
-phone TEXT NOT NULL,
-points INTEGER DEFAULT 0,
-created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-);`
-
-// Create shops table
-shopsTable := `
-CREATE TABLE IF NOT EXISTS shops (
-id INTEGER PRIMARY KEY AUTOINCREMENT,
-email TEXT UNIQUE NOT NULL,
-password TEXT NOT NULL,
-name TEXT NOT NULL,
-address TEXT NOT NULL,
-phone TEXT NOT NULL,
-description TEXT,
-created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-);`
-
-// Create shop_items table
-shopItemsTable := `
-CREATE TABLE IF NOT EXISTS shop_items (
-id INTEGER PRIMARY KEY AUTOINCREMENT,
-shop_id INTEGER NOT NULL,
-name TEXT NOT NULL,
-price REAL NOT NULL,
-category TEXT NOT NULL,
-description TEXT,
-is_eco_friendly BOOLEAN DEFAULT FALSE,
-FOREIGN KEY (shop_id) REFERENCES shops (id)
-);`
-
-// Create receipts table
-receiptsTable := `
-CREATE TABLE IF NOT EXISTS receipts (
-id INTEGER PRIMARY KEY AUTOINCREMENT,
-user_id INTEGER NOT NULL,
-shop_id INTEGER NOT NULL,
-total_amount REAL NOT NULL,
-points_earned INTEGER NOT NULL,
-created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-FOREIGN KEY (user_id) REFERENCES users (id),
-FOREIGN KEY (shop_id) REFERENCES shops (id)
-);`
-
-// Create receipt_items table
-receiptItemsTable := `
-CREATE TABLE IF NOT EXISTS receipt_items (
-id INTEGER PRIMARY KEY AUTOINCREMENT,
-receipt_id INTEGER NOT NULL,
-name TEXT NOT NULL,
-price REAL NOT NULL,
-quantity INTEGER NOT NULL,
-category TEXT NOT NULL,
-is_eco_friendly BOOLEAN DEFAULT FALSE,
-FOREIGN KEY (receipt_id) REFERENCES receipts (id)
-);`
-
-// Execute table creation
-tables := []string{usersTable, shopsTable, shopItemsTable, receiptsTable, receiptItemsTable}
-
-for _, table := range tables {
-if _, err := d.DB.Exec(table); err != nil {
-return err
+// Open the database; the driver names match the registered sql drivers
+db, err := sql.Open(cfg.Driver, cfg.dataSourceName())
+if err != nil {
+return nil, err
 }
+
+// Test the connection
+if err := db.Ping(); err != nil {
+return nil, err
 }
 
-log.Println("Database tables initialized successfully")
-return nil
+log.Println("Database connected successfully")
+return &Database{DB: db, Driver: cfg.Driver}, nil
 }
 
 func (d *Database) Close() error {
@@ -0,0 +1,286 @@
+package database
+
+import (
+	"database/sql"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// newTestDatabase opens an empty SQLite database.
+func newTestDatabase(t *testing.T) *Database {
+	t.Helper()
+
+	db, err := NewDatabase(Config{
+		Driver:        DriverSQLite,
+		Path:          filepath.Join(t.TempDir(), "test.db"),
+		JournalMode:   "WAL",
+		BusyTimeoutMS: 5000,
+		ForeignKeys:   true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// migrateTo applies or reverts migrations until version is the latest one
+// applied.
+func migrateTo(t *testing.T, db *Database, version int) {
+	t.Helper()
+
+	if err := db.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	statuses, err := db.MigrationStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps := 0
+	for _, status := range statuses {
+		if status.Version > version {
+			steps++
+		}
+	}
+	if err := db.MigrateDown(steps); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Transactions that read before they write must wait for each other rather
+// than fail with SQLITE_BUSY.
+func TestWithTxConcurrentReadThenWrite(t *testing.T) {
+	db := newTestDatabase(t)
+
+	if _, err := db.DB.Exec(`CREATE TABLE counter (n INTEGER NOT NULL)`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.DB.Exec(`INSERT INTO counter (n) VALUES (0)`); err != nil {
+		t.Fatal(err)
+	}
+
+	const writers = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- db.WithTx(func(tx *sql.Tx) error {
+				var n int
+				if err := tx.QueryRow(`SELECT n FROM counter`).Scan(&n); err != nil {
+					return err
+				}
+				_, err := tx.Exec(`UPDATE counter SET n = ?`, n+1)
+				return err
+			})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	var n int
+	if err := db.DB.QueryRow(`SELECT n FROM counter`).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != writers {
+		t.Errorf("counter = %d, want %d", n, writers)
+	}
+}
+
+// Migration 0009 turns REAL prices into cents, rounding them, and recomputes
+// receipt totals from their items.
+func TestMigrateMoneyToMinorUnits(t *testing.T) {
+	db := newTestDatabase(t)
+	migrateTo(t, db, 8)
+
+	for _, statement := range []string{
+		`INSERT INTO users (id, email, password, name, phone) VALUES (1, 'c@example.com', 'x', 'C', '+441234567890')`,
+		`INSERT INTO shops (id, email, password, name, address, phone) VALUES (1, 's@example.com', 'x', 'S', '1 High St', '+441234567891')`,
+		`INSERT INTO shop_items (id, shop_id, name, price, category) VALUES (1, 1, 'Gum', 0.29, 'Food'), (2, 1, 'Soap', 19.999, 'General'), (3, 1, 'Jam', 2.5, 'Food')`,
+		// Totals summed in floating point drift from their items: this one
+		// would round to 3.36 rather than the 3.37 the items add up to
+		`INSERT INTO receipts (id, user_id, shop_id, total_amount, points_earned) VALUES (1, 1, 1, 3.3649999, 3), (2, 1, 1, 12.5, 12)`,
+		`INSERT INTO receipt_items (receipt_id, name, price, quantity, category) VALUES (1, 'Gum', 0.29, 3, 'Food'), (1, 'Jam', 2.5, 1, 'Food')`,
+	} {
+		if _, err := db.DB.Exec(statement); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := db.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		query string
+		want  []int64
+	}{
+		{`SELECT price_minor FROM shop_items ORDER BY id`, []int64{29, 2000, 250}},
+		{`SELECT price_minor FROM receipt_items ORDER BY id`, []int64{29, 250}},
+		// A receipt without items keeps its rounded total
+		{`SELECT total_minor FROM receipts ORDER BY id`, []int64{337, 1250}},
+	} {
+		rows, err := db.DB.Query(tc.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []int64
+		for rows.Next() {
+			var n int64
+			if err := rows.Scan(&n); err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, n)
+		}
+		rows.Close()
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("%s = %v, want %v", tc.query, got, tc.want)
+		}
+	}
+
+	var currency string
+	if err := db.DB.QueryRow(`SELECT currency FROM receipts WHERE id = 1`).Scan(&currency); err != nil || currency != "USD" {
+		t.Errorf("currency = %q, %v; want USD", currency, err)
+	}
+}
+
+// tables lists the tables in db other than schema_migrations.
+func tables(t *testing.T, db *Database) []string {
+	t.Helper()
+
+	rows, err := db.DB.Query(`
+		SELECT name FROM sqlite_master
+		WHERE type = 'table' AND name NOT LIKE 'sqlite_%' AND name <> 'schema_migrations'
+		ORDER BY name`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, name)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return names
+}
+
+// Every migration reverts cleanly, newest first, and applies again
+// afterwards.
+func TestMigrateDown(t *testing.T) {
+	db := newTestDatabase(t)
+	if err := db.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	migrated := tables(t, db)
+	statuses, err := db.MigrationStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	latest := statuses[len(statuses)-1].Version
+
+	if err := db.MigrateDown(1); err != nil {
+		t.Fatal(err)
+	}
+	statuses, err = db.MigrationStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, status := range statuses {
+		if applied := status.AppliedAt != nil; applied != (status.Version != latest) {
+			t.Errorf("after one step down, migration %d applied = %v", status.Version, applied)
+		}
+	}
+
+	if err := db.MigrateDown(len(statuses)); err != nil {
+		t.Fatal(err)
+	}
+	if left := tables(t, db); len(left) != 0 {
+		t.Errorf("after reverting everything, tables %v remain", left)
+	}
+	statuses, err = db.MigrationStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, status := range statuses {
+		if status.AppliedAt != nil {
+			t.Errorf("migration %d still applied", status.Version)
+		}
+	}
+
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("migrating again: %v", err)
+	}
+	if again := tables(t, db); !slices.Equal(again, migrated) {
+		t.Errorf("tables after migrating again = %v, want %v", again, migrated)
+	}
+}
+
+// Once the recorded migrations stop matching the files, no migration
+// command runs.
+func TestMigrateRefusesChangedMigrations(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper string
+		want   string
+	}{
+		{
+			name:   "checksum mismatch",
+			tamper: `UPDATE schema_migrations SET checksum = 'edited' WHERE version = 1`,
+			want:   "migration 0001_initial: checksum mismatch",
+		},
+		{
+			name:   "applied without a file",
+			tamper: `INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES (9999, 'gone', 'x', CURRENT_TIMESTAMP)`,
+			want:   "migration 9999 is applied but has no file",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDatabase(t)
+			migrateTo(t, db, 2)
+			if _, err := db.DB.Exec(tt.tamper); err != nil {
+				t.Fatal(err)
+			}
+
+			commands := map[string]func() error{
+				"Migrate":     db.Migrate,
+				"MigrateDown": func() error { return db.MigrateDown(1) },
+				"MigrationStatus": func() error {
+					_, err := db.MigrationStatus()
+					return err
+				},
+			}
+			for name, command := range commands {
+				if err := command(); err == nil || !strings.Contains(err.Error(), tt.want) {
+					t.Errorf("%s: err = %v, want %q", name, err, tt.want)
+				}
+			}
+
+			// Nothing was applied or reverted
+			var applied int
+			if err := db.DB.QueryRow(`SELECT COUNT(*) FROM schema_migrations WHERE version <= 2`).Scan(&applied); err != nil || applied != 2 {
+				t.Errorf("%d of migrations 1-2 applied, %v; want both", applied, err)
+			}
+			if got := tables(t, db); !slices.Contains(got, "shop_items") {
+				t.Errorf("tables = %v, want those of migrations 1-2 kept", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS receipt_items;
+DROP TABLE IF EXISTS receipts;
+DROP TABLE IF EXISTS shop_items;
+DROP TABLE IF EXISTS shops;
+DROP TABLE IF EXISTS users;
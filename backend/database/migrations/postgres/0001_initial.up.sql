@@ -0,0 +1,55 @@
+CREATE TABLE IF NOT EXISTS users (
+    id SERIAL PRIMARY KEY,
+    email TEXT UNIQUE NOT NULL,
+    password TEXT NOT NULL,
+    name TEXT NOT NULL,
+    phone TEXT NOT NULL,
+    points INTEGER DEFAULT 0,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS shops (
+    id SERIAL PRIMARY KEY,
+    email TEXT UNIQUE NOT NULL,
+    password TEXT NOT NULL,
+    name TEXT NOT NULL,
+    address TEXT NOT NULL,
+    phone TEXT NOT NULL,
+    description TEXT,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS shop_items (
+    id SERIAL PRIMARY KEY,
+    shop_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    price DOUBLE PRECISION NOT NULL,
+    category TEXT NOT NULL,
+    description TEXT,
+    is_eco_friendly BOOLEAN DEFAULT FALSE,
+    FOREIGN KEY (shop_id) REFERENCES shops (id)
+);
+
+CREATE TABLE IF NOT EXISTS receipts (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    shop_id INTEGER NOT NULL,
+    total_amount DOUBLE PRECISION NOT NULL,
+    points_earned INTEGER NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users (id),
+    FOREIGN KEY (shop_id) REFERENCES shops (id)
+);
+
+CREATE TABLE IF NOT EXISTS receipt_items (
+    id SERIAL PRIMARY KEY,
+    receipt_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    price DOUBLE PRECISION NOT NULL,
+    quantity INTEGER NOT NULL,
+    category TEXT NOT NULL,
+    is_eco_friendly BOOLEAN DEFAULT FALSE,
+    FOREIGN KEY (receipt_id) REFERENCES receipts (id)
+);
@@ -0,0 +1,2 @@
+ALTER TABLE user_challenges DROP COLUMN points_awarded;
+ALTER TABLE user_challenges DROP COLUMN receipt_id;
@@ -0,0 +1,2 @@
+ALTER TABLE user_challenges ADD COLUMN receipt_id INTEGER;
+ALTER TABLE user_challenges ADD COLUMN points_awarded INTEGER NOT NULL DEFAULT 0;
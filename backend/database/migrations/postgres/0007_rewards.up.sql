@@ -0,0 +1,30 @@
+CREATE TABLE rewards (
+    id SERIAL PRIMARY KEY,
+    shop_id INTEGER,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    points_cost INTEGER NOT NULL,
+    stock INTEGER,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    FOREIGN KEY (shop_id) REFERENCES shops (id)
+);
+
+CREATE TABLE redemptions (
+    id SERIAL PRIMARY KEY,
+    reward_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    code TEXT UNIQUE NOT NULL,
+    points_spent INTEGER NOT NULL,
+    status TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    consumed_at TIMESTAMPTZ,
+    consumed_by_shop_id INTEGER,
+    FOREIGN KEY (reward_id) REFERENCES rewards (id),
+    FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+CREATE INDEX redemptions_user_id ON redemptions (user_id);
+
+ALTER TABLE points_ledger ADD COLUMN redemption_id INTEGER;
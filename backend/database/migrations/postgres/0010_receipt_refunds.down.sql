@@ -0,0 +1,6 @@
+DROP TABLE receipt_refund_items;
+DROP TABLE receipt_refunds;
+ALTER TABLE receipt_items DROP COLUMN refunded_quantity;
+ALTER TABLE receipts DROP COLUMN points_reversed;
+ALTER TABLE receipts DROP COLUMN refunded_minor;
+ALTER TABLE receipts DROP COLUMN status;
@@ -0,0 +1,3 @@
+ALTER TABLE receipt_items DROP COLUMN list_price_minor;
+ALTER TABLE receipt_items DROP COLUMN price_override;
+ALTER TABLE receipt_items DROP COLUMN item_id;
@@ -0,0 +1,20 @@
+-- Catalogue items are archived instead of deleted, so the receipts that
+-- reference them keep resolving, and every price an item has had is kept.
+ALTER TABLE shop_items ADD COLUMN archived_at TIMESTAMPTZ;
+ALTER TABLE shop_items ADD COLUMN created_at TIMESTAMPTZ;
+ALTER TABLE shop_items ADD COLUMN updated_at TIMESTAMPTZ;
+UPDATE shop_items SET created_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP;
+
+CREATE TABLE shop_item_prices (
+    id SERIAL PRIMARY KEY,
+    item_id INTEGER NOT NULL,
+    price_minor BIGINT NOT NULL,
+    currency TEXT NOT NULL,
+    effective_from TIMESTAMPTZ NOT NULL,
+    FOREIGN KEY (item_id) REFERENCES shop_items (id)
+);
+
+CREATE INDEX shop_item_prices_item_id ON shop_item_prices (item_id);
+
+INSERT INTO shop_item_prices (item_id, price_minor, currency, effective_from)
+    SELECT id, price_minor, currency, created_at FROM shop_items;
@@ -0,0 +1,5 @@
+-- Shops can give catalogue items a SKU, unique among the shop's items that
+-- are not archived, and import their catalogue matching items by it.
+ALTER TABLE shop_items ADD COLUMN sku TEXT;
+
+CREATE UNIQUE INDEX shop_items_shop_id_sku ON shop_items (shop_id, sku) WHERE archived_at IS NULL;
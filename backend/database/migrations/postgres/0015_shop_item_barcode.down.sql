@@ -0,0 +1,3 @@
+DROP INDEX shop_items_shop_id_barcode;
+
+ALTER TABLE shop_items DROP COLUMN barcode;
@@ -0,0 +1,6 @@
+-- Catalogue items can carry an EAN-13 barcode, stored as 13 digits (UPC-A
+-- codes get a leading zero), unique among the shop's items that are not
+-- archived.
+ALTER TABLE shop_items ADD COLUMN barcode TEXT;
+
+CREATE UNIQUE INDEX shop_items_shop_id_barcode ON shop_items (shop_id, barcode) WHERE archived_at IS NULL;
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS sessions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    principal_kind TEXT NOT NULL,
+    principal_id INTEGER NOT NULL,
+    refresh_token_hash TEXT UNIQUE NOT NULL,
+    expires_at DATETIME NOT NULL,
+    revoked_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,2 @@
+DROP TABLE user_challenges;
+DROP TABLE challenges;
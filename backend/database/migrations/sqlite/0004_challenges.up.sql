@@ -0,0 +1,28 @@
+CREATE TABLE challenges (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    icon TEXT NOT NULL DEFAULT '',
+    rule TEXT NOT NULL,
+    target INTEGER NOT NULL,
+    points INTEGER NOT NULL DEFAULT 0,
+    retired_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE user_challenges (
+    user_id INTEGER NOT NULL,
+    challenge_id INTEGER NOT NULL,
+    completed_at DATETIME NOT NULL,
+    PRIMARY KEY (user_id, challenge_id),
+    FOREIGN KEY (user_id) REFERENCES users (id),
+    FOREIGN KEY (challenge_id) REFERENCES challenges (id)
+);
+
+-- The challenges that used to be built into the server
+INSERT INTO challenges (name, description, icon, rule, target, points, created_at, updated_at) VALUES
+    ('Eco-Friendly Shopping', 'Buy 5 organic products', '', '{"metric":"items","categories":["Organic"]}', 5, 0, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
+    ('Fruit & Veggie Lover', 'Purchase 10 fruits/vegetables', '', '{"metric":"items","categories":["Fruits","Vegetables"]}', 10, 0, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
+    ('Sustainable Living', 'Buy 3 eco-friendly items', '', '{"metric":"items","categories":["Eco-Friendly"],"eco_friendly":true}', 3, 0, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
+    ('Local Business Support', 'Shop at 2 local stores', '', '{"metric":"shops"}', 2, 0, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP);
@@ -0,0 +1,17 @@
+CREATE TABLE points_ledger (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    kind TEXT NOT NULL,
+    amount INTEGER NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    receipt_id INTEGER,
+    challenge_id INTEGER,
+    created_at DATETIME NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+CREATE INDEX points_ledger_user_id ON points_ledger (user_id);
+
+-- Carry existing balances over as the first entry of each ledger
+INSERT INTO points_ledger (user_id, kind, amount, reason, created_at)
+SELECT id, 'adjust', points, 'Opening balance', CURRENT_TIMESTAMP FROM users WHERE points <> 0;
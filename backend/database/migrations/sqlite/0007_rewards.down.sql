@@ -0,0 +1,3 @@
+ALTER TABLE points_ledger DROP COLUMN redemption_id;
+DROP TABLE redemptions;
+DROP TABLE rewards;
@@ -0,0 +1,30 @@
+CREATE TABLE rewards (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    shop_id INTEGER,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    points_cost INTEGER NOT NULL,
+    stock INTEGER,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL,
+    FOREIGN KEY (shop_id) REFERENCES shops (id)
+);
+
+CREATE TABLE redemptions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    reward_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    code TEXT UNIQUE NOT NULL,
+    points_spent INTEGER NOT NULL,
+    status TEXT NOT NULL,
+    created_at DATETIME NOT NULL,
+    consumed_at DATETIME,
+    consumed_by_shop_id INTEGER,
+    FOREIGN KEY (reward_id) REFERENCES rewards (id),
+    FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+CREATE INDEX redemptions_user_id ON redemptions (user_id);
+
+ALTER TABLE points_ledger ADD COLUMN redemption_id INTEGER;
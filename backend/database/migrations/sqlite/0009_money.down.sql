@@ -0,0 +1,13 @@
+ALTER TABLE receipts ADD COLUMN total_amount REAL NOT NULL DEFAULT 0;
+UPDATE receipts SET total_amount = total_minor / 100.0;
+ALTER TABLE receipts DROP COLUMN currency;
+ALTER TABLE receipts DROP COLUMN total_minor;
+
+ALTER TABLE receipt_items ADD COLUMN price REAL NOT NULL DEFAULT 0;
+UPDATE receipt_items SET price = price_minor / 100.0;
+ALTER TABLE receipt_items DROP COLUMN price_minor;
+
+ALTER TABLE shop_items ADD COLUMN price REAL NOT NULL DEFAULT 0;
+UPDATE shop_items SET price = price_minor / 100.0;
+ALTER TABLE shop_items DROP COLUMN currency;
+ALTER TABLE shop_items DROP COLUMN price_minor;
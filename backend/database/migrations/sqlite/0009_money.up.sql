@@ -0,0 +1,18 @@
+-- Amounts become whole minor units (cents) with an ISO 4217 currency. Existing
+-- REAL prices are rounded to the cent and receipt totals are recomputed from
+-- their items, which drops the rounding error they accumulated.
+ALTER TABLE shop_items ADD COLUMN price_minor INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE shop_items ADD COLUMN currency TEXT NOT NULL DEFAULT 'USD';
+UPDATE shop_items SET price_minor = CAST(ROUND(price * 100) AS INTEGER);
+ALTER TABLE shop_items DROP COLUMN price;
+
+ALTER TABLE receipt_items ADD COLUMN price_minor INTEGER NOT NULL DEFAULT 0;
+UPDATE receipt_items SET price_minor = CAST(ROUND(price * 100) AS INTEGER);
+ALTER TABLE receipt_items DROP COLUMN price;
+
+ALTER TABLE receipts ADD COLUMN total_minor INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE receipts ADD COLUMN currency TEXT NOT NULL DEFAULT 'USD';
+UPDATE receipts SET total_minor = COALESCE(
+    (SELECT SUM(price_minor * quantity) FROM receipt_items WHERE receipt_id = receipts.id),
+    CAST(ROUND(total_amount * 100) AS INTEGER));
+ALTER TABLE receipts DROP COLUMN total_amount;
@@ -0,0 +1,25 @@
+ALTER TABLE receipts ADD COLUMN status TEXT NOT NULL DEFAULT 'active';
+ALTER TABLE receipts ADD COLUMN refunded_minor INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE receipts ADD COLUMN points_reversed INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE receipt_items ADD COLUMN refunded_quantity INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE receipt_refunds (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    receipt_id INTEGER NOT NULL,
+    amount_minor INTEGER NOT NULL,
+    points_reversed INTEGER NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL,
+    FOREIGN KEY (receipt_id) REFERENCES receipts (id)
+);
+
+CREATE INDEX receipt_refunds_receipt_id ON receipt_refunds (receipt_id);
+
+CREATE TABLE receipt_refund_items (
+    refund_id INTEGER NOT NULL,
+    receipt_item_id INTEGER NOT NULL,
+    quantity INTEGER NOT NULL,
+    PRIMARY KEY (refund_id, receipt_item_id),
+    FOREIGN KEY (refund_id) REFERENCES receipt_refunds (id),
+    FOREIGN KEY (receipt_item_id) REFERENCES receipt_items (id)
+);
@@ -0,0 +1,7 @@
+-- Receipt lines reference the catalogue item they were sold from. Lines of
+-- older receipts keep a NULL item_id. list_price_minor is the catalogue
+-- price at the time of sale, which differs from price_minor when the shop
+-- overrode it.
+ALTER TABLE receipt_items ADD COLUMN item_id INTEGER REFERENCES shop_items (id);
+ALTER TABLE receipt_items ADD COLUMN price_override BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE receipt_items ADD COLUMN list_price_minor INTEGER;
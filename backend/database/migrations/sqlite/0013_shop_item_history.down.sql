@@ -0,0 +1,5 @@
+DROP TABLE shop_item_prices;
+
+ALTER TABLE shop_items DROP COLUMN updated_at;
+ALTER TABLE shop_items DROP COLUMN created_at;
+ALTER TABLE shop_items DROP COLUMN archived_at;
@@ -0,0 +1,4 @@
+UPDATE challenges
+SET rule = '{"metric":"items","categories":["Eco-Friendly"],"eco_friendly":true}', updated_at = CURRENT_TIMESTAMP
+WHERE name = 'Sustainable Living'
+    AND rule = '{"metric":"items","eco_friendly":true}';
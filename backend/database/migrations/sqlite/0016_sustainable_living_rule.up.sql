@@ -0,0 +1,7 @@
+-- "Buy 3 eco-friendly items" counts every item flagged eco-friendly, not
+-- only those in the Eco-Friendly category. A rule an admin has changed
+-- since it was seeded is left alone.
+UPDATE challenges
+SET rule = '{"metric":"items","eco_friendly":true}', updated_at = CURRENT_TIMESTAMP
+WHERE name = 'Sustainable Living'
+    AND rule = '{"metric":"items","categories":["Eco-Friendly"],"eco_friendly":true}';
@@ -0,0 +1,60 @@
+//go:build gofr
+
+// Package gofrserver serves the API route table through GoFr.
+package gofrserver
+
+import (
+	"ecotracker-backend/auth"
+	"ecotracker-backend/routes"
+	"net/http"
+
+	"gofr.dev/pkg/gofr"
+)
+
+// Mount registers every route of the table on the GoFr app.
+//
+// GoFr handlers return values instead of writing to an http.ResponseWriter,
+// so the routes are registered with a placeholder handler and a middleware
+// serves matched requests from the same ServeMux the net/http server uses.
+// GoFr's own logging, metrics and tracing middleware wrap ours, so every API
+// call is observed by GoFr, and requests that match a route get the same
+// status codes and bodies as from the net/http server. GoFr only runs
+// middleware for requests that match one of its routes, though: unknown
+// paths and unsupported methods are answered by GoFr itself, with its own
+// 404 and 405 bodies rather than the apierror envelope.
+func Mount(app *gofr.App, table []routes.Route, signer *auth.TokenSigner) {
+	mux := routes.NewMux(table, signer)
+
+	for _, route := range table {
+		switch route.Method {
+		case http.MethodGet:
+			app.GET(route.Pattern, unreachable)
+		case http.MethodPost:
+			app.POST(route.Pattern, unreachable)
+		case http.MethodPut:
+			app.PUT(route.Pattern, unreachable)
+		case http.MethodPatch:
+			app.PATCH(route.Pattern, unreachable)
+		case http.MethodDelete:
+			app.DELETE(route.Pattern, unreachable)
+		}
+	}
+
+	app.UseMiddleware(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Leave GoFr's built-in endpoints such as /.well-known/health alone
+			if !routes.Matches(mux, r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			mux.ServeHTTP(w, r)
+		})
+	})
+}
+
+// unreachable stands in for the GoFr handler of a mounted route; the
+// middleware installed by Mount answers those requests first.
+func unreachable(*gofr.Context) (any, error) {
+	return nil, nil
+}
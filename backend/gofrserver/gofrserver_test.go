@@ -0,0 +1,67 @@
+//go:build gofr
+
+package gofrserver
+
+import (
+	"ecotracker-backend/routes"
+	"ecotracker-backend/routes/routetest"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"gofr.dev/pkg/gofr"
+)
+
+func TestMountRoutes(t *testing.T) {
+	port := freePort(t)
+	t.Setenv("HTTP_PORT", port)
+	t.Setenv("METRICS_PORT", freePort(t))
+
+	app := gofr.New()
+	Mount(app, routetest.Table(), routetest.Signer)
+	go app.Run()
+
+	baseURL := "http://localhost:" + port
+	waitForServer(t, baseURL+"/.well-known/alive")
+
+	// Requests no route matches are answered by GoFr, not the fallback
+	routetest.Run(t, baseURL, routetest.Cases)
+}
+
+// Every pattern of the real route table must be one GoFr can register.
+func TestMountTable(t *testing.T) {
+	t.Setenv("HTTP_PORT", freePort(t))
+	t.Setenv("METRICS_PORT", freePort(t))
+
+	Mount(gofr.New(), routes.Table(routes.Handlers{}), routetest.Signer)
+}
+
+func freePort(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	return strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)
+}
+
+func waitForServer(t *testing.T, url string) {
+	t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		resp, err := http.Get(url)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatalf("GoFr server did not start: no answer from %s", url)
+}
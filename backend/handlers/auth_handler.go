@@ -0,0 +1,48 @@
+﻿package handlers
+
+import (
+	"ecotracker-backend/models"
+	"ecotracker-backend/services"
+	"encoding/json"
+	"net/http"
+)
+
+type AuthHandler struct {
+	sessionService *services.SessionService
+}
+
+func NewAuthHandler(sessionService *services.SessionService) *AuthHandler {
+	return &AuthHandler{sessionService: sessionService}
+}
+
+func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var req models.RefreshRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	tokens, err := h.sessionService.Refresh(req.RefreshToken)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tokens)
+}
+
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	var req models.RefreshRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	err := h.sessionService.Logout(req.RefreshToken)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Logged out successfully"})
+}
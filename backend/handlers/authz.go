@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"ecotracker-backend/apierror"
+	"ecotracker-backend/auth"
+	"ecotracker-backend/services"
+	"fmt"
+	"net/http"
+)
+
+// authorize checks the request's principal against a policy from the auth
+// package. It writes 401 or 403 and returns false when the check fails.
+func authorize(w http.ResponseWriter, r *http.Request, allowed func(auth.Principal) bool) bool {
+	principal, ok := auth.FromContext(r.Context())
+	if !ok {
+		apierror.Write(w, http.StatusUnauthorized, apierror.CodeUnauthorized, "Authentication required", nil)
+		return false
+	}
+
+	if !allowed(principal) {
+		apierror.Write(w, http.StatusForbidden, apierror.CodeForbidden, "Forbidden", nil)
+		return false
+	}
+
+	return true
+}
+
+// authorizeFound is authorize for a record the request names by ID, such as
+// a receipt. A caller the policy rejects gets the 404 a missing record gets,
+// so responses do not reveal which IDs exist.
+func authorizeFound(w http.ResponseWriter, r *http.Request, record string, allowed func(auth.Principal) bool) bool {
+	principal, ok := auth.FromContext(r.Context())
+	if !ok {
+		apierror.Write(w, http.StatusUnauthorized, apierror.CodeUnauthorized, "Authentication required", nil)
+		return false
+	}
+
+	if !allowed(principal) {
+		writeError(w, fmt.Errorf("%s %w", record, services.ErrNotFound))
+		return false
+	}
+
+	return true
+}
+
+// authenticated is the policy for routes open to any signed-in caller.
+func authenticated(auth.Principal) bool {
+	return true
+}
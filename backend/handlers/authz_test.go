@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"ecotracker-backend/apierror"
+	"ecotracker-backend/auth"
+	"ecotracker-backend/database"
+	"ecotracker-backend/models"
+	"ecotracker-backend/repository"
+	"ecotracker-backend/services"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestStore returns a store on a fresh, migrated SQLite database.
+func newTestStore(t *testing.T) repository.Store {
+	t.Helper()
+
+	db, err := database.NewDatabase(database.Config{
+		Driver:        database.DriverSQLite,
+		Path:          filepath.Join(t.TempDir(), "test.db"),
+		JournalMode:   "WAL",
+		BusyTimeoutMS: 5000,
+		ForeignKeys:   true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := db.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	return repository.New(db)
+}
+
+// call runs handler for principal with a request to target, with body as
+// its JSON body, and returns the response.
+func call(principal auth.Principal, method, target string, body any, handler http.HandlerFunc) *httptest.ResponseRecorder {
+	var payload string
+	if body != nil {
+		data, _ := json.Marshal(body)
+		payload = string(data)
+	}
+
+	r := httptest.NewRequest(method, target, strings.NewReader(payload))
+	r = r.WithContext(auth.WithPrincipal(r.Context(), principal))
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+}
+
+// A customer or shop reaching for another one's records gets 403, or 404
+// where the request names a record by ID so its existence stays hidden.
+func TestHandlersRefuseOtherPrincipals(t *testing.T) {
+	store := newTestStore(t)
+	pointsRules := services.NewPointsRulesService(store, models.DefaultPointsRules())
+	receiptService := services.NewReceiptService(store, pointsRules, time.Hour)
+	rewardService := services.NewRewardService(store)
+	receipts := NewReceiptHandler(receiptService)
+	users := NewUserHandler(services.NewUserService(store, services.NewBcryptHasher(4)), nil)
+	shops := NewShopHandler(services.NewShopService(store, services.NewBcryptHasher(4)), nil)
+	points := NewPointsHandler(services.NewPointsService(store), pointsRules)
+	rewards := NewRewardHandler(rewardService)
+
+	var accounts []auth.Principal
+	for i := range 2 {
+		user := &models.User{Email: fmt.Sprintf("c%d@example.com", i), Password: "x", Name: "Customer", Phone: "+441234567890"}
+		if err := store.Users().Create(user); err != nil {
+			t.Fatal(err)
+		}
+		accounts = append(accounts, auth.Principal{Kind: auth.KindUser, ID: user.ID, Role: auth.RoleCustomer})
+	}
+	for i := range 2 {
+		shop := &models.Shop{Email: fmt.Sprintf("s%d@example.com", i), Password: "x", Name: "Shop", Address: "1 High St", Phone: "+441234567891"}
+		if err := store.Shops().Create(shop); err != nil {
+			t.Fatal(err)
+		}
+		accounts = append(accounts, auth.Principal{Kind: auth.KindShop, ID: shop.ID, Role: auth.RoleShopkeeper})
+	}
+	customer, otherCustomer, shop, otherShop := accounts[0], accounts[1], accounts[2], accounts[3]
+
+	item := &models.ShopItem{
+		ShopID:   shop.ID,
+		Name:     "Bread",
+		Price:    models.Money{Amount: 500, Currency: models.DefaultCurrency},
+		Currency: models.DefaultCurrency,
+		Category: "Food",
+	}
+	if err := store.Shops().AddItem(item); err != nil {
+		t.Fatal(err)
+	}
+	receipt, err := receiptService.CreateReceipt(models.ReceiptCreate{
+		UserID: customer.ID,
+		ShopID: shop.ID,
+		Items:  []models.ReceiptLine{{ItemID: item.ID, Quantity: 1}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reward, err := rewardService.CreateReward(models.RewardDefinition{ShopID: shop.ID, Name: "Tote bag", PointsCost: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	redemption, err := rewardService.Redeem(customer.ID, reward.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	byID := func(handler func(http.ResponseWriter, *http.Request, int), id int) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) { handler(w, r, id) }
+	}
+	preview := models.PointsPreviewRequest{
+		UserID: customer.ID,
+		ShopID: shop.ID,
+		Items:  []models.ReceiptItem{{Name: "Bread", Category: "Food", Price: item.Price, Quantity: 1}},
+	}
+
+	tests := []struct {
+		name      string
+		principal auth.Principal
+		method    string
+		body      any
+		handler   http.HandlerFunc
+		status    int
+	}{
+		{"own account", customer, "GET", nil, byID(users.GetUser, customer.ID), http.StatusOK},
+		{"another customer's account", otherCustomer, "GET", nil, byID(users.GetUser, customer.ID), http.StatusForbidden},
+		{"a customer's account as a shop", shop, "GET", nil, byID(users.GetUser, customer.ID), http.StatusForbidden},
+		{"another customer's receipts", otherCustomer, "GET", nil, byID(receipts.GetUserReceipts, customer.ID), http.StatusForbidden},
+		{"another customer's redemptions", otherCustomer, "GET", nil, byID(rewards.GetUserRedemptions, customer.ID), http.StatusForbidden},
+		{"another customer's points history", otherCustomer, "GET", nil, byID(points.GetHistory, customer.ID), http.StatusForbidden},
+
+		{"own receipt", customer, "GET", nil, byID(receipts.GetReceipt, receipt.ID), http.StatusOK},
+		{"issued receipt", shop, "GET", nil, byID(receipts.GetReceipt, receipt.ID), http.StatusOK},
+		{"another customer's receipt", otherCustomer, "GET", nil, byID(receipts.GetReceipt, receipt.ID), http.StatusNotFound},
+		{"another shop's receipt", otherShop, "GET", nil, byID(receipts.GetReceipt, receipt.ID), http.StatusNotFound},
+		{"voiding own receipt as a customer", customer, "POST", nil, byID(receipts.VoidReceipt, receipt.ID), http.StatusNotFound},
+		{"voiding another shop's receipt", otherShop, "POST", nil, byID(receipts.VoidReceipt, receipt.ID), http.StatusNotFound},
+		{"refunding another shop's receipt", otherShop, "POST",
+			models.RefundRequest{Items: []models.RefundLine{{ItemID: receipt.Items[0].ID, Quantity: 1}}},
+			byID(receipts.RefundItems, receipt.ID), http.StatusNotFound},
+		{"receipt for another shop", otherShop, "POST",
+			models.ReceiptCreate{UserID: customer.ID, ShopID: shop.ID, Items: []models.ReceiptLine{{ItemID: item.ID, Quantity: 1}}},
+			receipts.CreateReceipt, http.StatusForbidden},
+		{"another shop's receipts", otherShop, "GET", nil, byID(receipts.GetShopReceipts, shop.ID), http.StatusForbidden},
+
+		{"item for another shop", otherShop, "POST",
+			models.ShopItem{Name: "Milk", Price: item.Price, Category: "Food"},
+			byID(shops.AddItem, shop.ID), http.StatusForbidden},
+		{"reward of another shop", otherShop, "PUT",
+			models.RewardDefinition{ShopID: otherShop.ID, Name: "Mug", PointsCost: 1},
+			byID(rewards.UpdateReward, reward.ID), http.StatusForbidden},
+		{"redemption of another shop", otherShop, "POST", models.RedemptionCode{Code: redemption.Code}, rewards.VerifyRedemption, http.StatusNotFound},
+		{"redemption as a customer", customer, "POST", models.RedemptionCode{Code: redemption.Code}, rewards.VerifyRedemption, http.StatusForbidden},
+		{"own redemption", shop, "POST", models.RedemptionCode{Code: redemption.Code}, rewards.VerifyRedemption, http.StatusOK},
+
+		{"preview for own customer", shop, "POST", preview, points.Preview, http.StatusOK},
+		{"preview for another shop's customer", otherShop, "POST", preview, points.Preview, http.StatusForbidden},
+		{"preview for another customer", otherCustomer, "POST", preview, points.Preview, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := call(tt.principal, tt.method, "/", tt.body, tt.handler)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d; body %s", w.Code, tt.status, w.Body)
+			}
+
+			if tt.status >= 400 {
+				var envelope apierror.Response
+				if err := json.Unmarshal(w.Body.Bytes(), &envelope); err != nil || envelope.Code == "" {
+					t.Errorf("body = %s, want an error envelope", w.Body)
+				}
+			}
+		})
+	}
+}
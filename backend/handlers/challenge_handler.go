@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"ecotracker-backend/auth"
+	"ecotracker-backend/models"
+	"ecotracker-backend/services"
+	"encoding/json"
+	"net/http"
+)
+
+type ChallengeHandler struct {
+	challengeService *services.ChallengeService
+}
+
+func NewChallengeHandler(challengeService *services.ChallengeService) *ChallengeHandler {
+	return &ChallengeHandler{challengeService: challengeService}
+}
+
+// ListChallenges returns the active challenges; admins also see retired ones.
+func (h *ChallengeHandler) ListChallenges(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, authenticated) {
+		return
+	}
+	principal, _ := auth.FromContext(r.Context())
+
+	challenges, err := h.challengeService.ListChallenges(auth.CanManageChallenges(principal))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(challenges)
+}
+
+func (h *ChallengeHandler) CreateChallenge(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.CanManageChallenges) {
+		return
+	}
+
+	var req models.ChallengeDefinition
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	challenge, err := h.challengeService.CreateChallenge(req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(challenge)
+}
+
+func (h *ChallengeHandler) UpdateChallenge(w http.ResponseWriter, r *http.Request, challengeID int) {
+	if !authorize(w, r, auth.CanManageChallenges) {
+		return
+	}
+
+	var req models.ChallengeDefinition
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	challenge, err := h.challengeService.UpdateChallenge(challengeID, req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(challenge)
+}
+
+func (h *ChallengeHandler) RetireChallenge(w http.ResponseWriter, r *http.Request, challengeID int) {
+	if !authorize(w, r, auth.CanManageChallenges) {
+		return
+	}
+
+	challenge, err := h.challengeService.RetireChallenge(challengeID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(challenge)
+}
+
+func (h *ChallengeHandler) GetUserChallenges(w http.ResponseWriter, r *http.Request, userID int) {
+	if !authorize(w, r, func(p auth.Principal) bool { return auth.CanAccessUser(p, userID) }) {
+		return
+	}
+
+	challenges, err := h.challengeService.GetUserChallenges(userID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(challenges)
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"ecotracker-backend/apierror"
+	"ecotracker-backend/services"
+	"ecotracker-backend/validation"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+)
+
+// writeError maps an error returned by a service to the JSON error envelope.
+// Unexpected errors are logged and reported without their details, so raw
+// database messages never reach clients.
+func writeError(w http.ResponseWriter, err error) {
+	var validationErr *services.ValidationError
+
+	switch {
+	case errors.As(err, &validationErr):
+		apierror.Write(w, http.StatusBadRequest, apierror.CodeValidation, validationErr.Error(), validationErr.Fields)
+	case errors.Is(err, services.ErrValidation):
+		apierror.Write(w, http.StatusBadRequest, apierror.CodeValidation, err.Error(), nil)
+	case errors.Is(err, services.ErrNotFound):
+		apierror.Write(w, http.StatusNotFound, apierror.CodeNotFound, err.Error(), nil)
+	case errors.Is(err, services.ErrConflict), errors.Is(err, services.ErrVoided), errors.Is(err, services.ErrKeyReused):
+		apierror.Write(w, http.StatusConflict, apierror.CodeConflict, err.Error(), nil)
+	case errors.Is(err, services.ErrConcurrentUpdate):
+		apierror.Write(w, http.StatusConflict, apierror.CodeConcurrentUpdate, err.Error(), nil)
+	case errors.Is(err, services.ErrInsufficientPoints):
+		apierror.Write(w, http.StatusConflict, apierror.CodeInsufficientPoints, err.Error(), nil)
+	case errors.Is(err, services.ErrUnavailable):
+		apierror.Write(w, http.StatusConflict, apierror.CodeUnavailable, err.Error(), nil)
+	case errors.Is(err, services.ErrInvalidCredentials):
+		apierror.Write(w, http.StatusUnauthorized, apierror.CodeInvalidCredentials, err.Error(), nil)
+	case errors.Is(err, services.ErrInvalidToken):
+		apierror.Write(w, http.StatusUnauthorized, apierror.CodeUnauthorized, err.Error(), nil)
+	default:
+		log.Printf("internal error: %v", err)
+		apierror.Write(w, http.StatusInternalServerError, apierror.CodeInternal, "Internal server error", nil)
+	}
+}
+
+// decodeRequest reads the request body into v and enforces its validate
+// tags, answering 400 when the body is not valid JSON or fails validation.
+func decodeRequest(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		apierror.Write(w, http.StatusBadRequest, apierror.CodeBadRequest, "Invalid JSON", nil)
+		return false
+	}
+
+	if err := validation.Struct(v); err != nil {
+		writeError(w, err)
+		return false
+	}
+	return true
+}
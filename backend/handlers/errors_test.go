@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"ecotracker-backend/apierror"
+	"ecotracker-backend/models"
+	"ecotracker-backend/services"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWriteError(t *testing.T) {
+	tests := []struct {
+		err     error
+		status  int
+		code    string
+		message string
+	}{
+		{services.NewValidationError("email", "is required"), http.StatusBadRequest, apierror.CodeValidation, "validation failed: email is required"},
+		{fmt.Errorf("receipt %w", services.ErrNotFound), http.StatusNotFound, apierror.CodeNotFound, "receipt not found"},
+		{fmt.Errorf("email %w", services.ErrConflict), http.StatusConflict, apierror.CodeConflict, "email already exists"},
+		{fmt.Errorf("receipt %w", services.ErrConcurrentUpdate), http.StatusConflict, apierror.CodeConcurrentUpdate, "receipt changed while the request ran"},
+		{services.ErrInsufficientPoints, http.StatusConflict, apierror.CodeInsufficientPoints, "insufficient points"},
+		{services.ErrInvalidCredentials, http.StatusUnauthorized, apierror.CodeInvalidCredentials, "invalid credentials"},
+		{errors.New("pq: connection refused"), http.StatusInternalServerError, apierror.CodeInternal, "Internal server error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			writeError(w, tt.err)
+
+			var envelope apierror.Response
+			if err := json.Unmarshal(w.Body.Bytes(), &envelope); err != nil {
+				t.Fatal(err)
+			}
+			if w.Code != tt.status || envelope.Code != tt.code || envelope.Message != tt.message {
+				t.Errorf("got %d %+v, want %d %s %q", w.Code, envelope, tt.status, tt.code, tt.message)
+			}
+		})
+	}
+}
+
+// Invalid requests are refused with 400 and a fields entry, under its JSON
+// name, for every field at fault.
+func TestDecodeRequestValidation(t *testing.T) {
+	categories := "must be one of " + strings.Join(models.Categories, ", ")
+	currencies := "must be one of " + strings.Join(models.Currencies, ", ")
+	barcode := "must be an EAN-13 or UPC-A barcode"
+
+	tests := []struct {
+		name   string
+		into   func() any
+		body   string
+		fields map[string]string
+	}{
+		{
+			name: "valid registration",
+			into: func() any { return &models.UserRegistration{} },
+			body: `{"email": "c@example.com", "password": "secret", "name": "C", "phone": "+44 1234-567890"}`,
+		},
+		{
+			name: "empty registration",
+			into: func() any { return &models.UserRegistration{} },
+			body: `{}`,
+			fields: map[string]string{
+				"email":    "is required",
+				"password": "is required",
+				"name":     "is required",
+				"phone":    "is required",
+			},
+		},
+		{
+			name: "malformed registration",
+			into: func() any { return &models.ShopRegistration{} },
+			body: `{"email": "shop", "password": "short", "name": "S", "address": "1 High St", "phone": "call us"}`,
+			fields: map[string]string{
+				"email":    "must be a valid email address",
+				"password": "must be at least 6 characters",
+				"phone":    "must be a valid phone number",
+			},
+		},
+		{
+			name: "valid item",
+			into: func() any { return &models.ShopItem{} },
+			body: `{"name": "Bread", "price": 2.5, "currency": "EUR", "category": "Food", "barcode": "4006381333931"}`,
+		},
+		{
+			name: "item with a UPC-A barcode",
+			into: func() any { return &models.ShopItem{} },
+			body: `{"name": "Bread", "price": 2.5, "category": "Food", "barcode": "036000291452"}`,
+		},
+		{
+			name: "invalid item",
+			into: func() any { return &models.ShopItem{} },
+			body: `{"name": "Bread", "price": 0.001, "currency": "JPY", "category": "Bakery", "barcode": "4006381333932"}`,
+			fields: map[string]string{
+				"price":    "must be greater than 0",
+				"currency": currencies,
+				"category": categories,
+				"barcode":  barcode,
+			},
+		},
+		{
+			name: "patch removing a barcode",
+			into: func() any { return &models.ShopItemPatch{} },
+			body: `{"barcode": ""}`,
+		},
+		{
+			name:   "patch with an invalid barcode",
+			into:   func() any { return &models.ShopItemPatch{} },
+			body:   `{"barcode": "12345", "category": "Bakery"}`,
+			fields: map[string]string{"barcode": barcode, "category": categories},
+		},
+		{
+			name:   "receipt without items",
+			into:   func() any { return &models.ReceiptCreate{} },
+			body:   `{"user_id": 1, "shop_id": 1, "items": [], "currency": "XXX"}`,
+			fields: map[string]string{"items": "must contain at least 1 entries", "currency": currencies},
+		},
+		{
+			name: "receipt with invalid lines",
+			into: func() any { return &models.ReceiptCreate{} },
+			body: `{"user_id": 1, "shop_id": 1, "items": [{"item_id": 1, "quantity": 1}, {"barcode": "4006381333932", "quantity": 0, "price": -1}]}`,
+			fields: map[string]string{
+				"items[1].barcode":  barcode,
+				"items[1].quantity": "must be at least 1",
+				"items[1].price":    "must be greater than 0",
+			},
+		},
+		{
+			name: "challenge with an invalid rule",
+			into: func() any { return &models.ChallengeDefinition{} },
+			body: `{"name": "Greens", "rule": {"metric": "visits", "categories": ["Fruits", "Sweets"]}, "target": 0}`,
+			fields: map[string]string{
+				"rule.metric":        "must be one of items shops spend",
+				"rule.categories[1]": categories,
+				"target":             "must be at least 1",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			ok := decodeRequest(w, r, tt.into())
+
+			if tt.fields == nil {
+				if !ok {
+					t.Fatalf("refused a valid request: %d %s", w.Code, w.Body)
+				}
+				return
+			}
+			if ok {
+				t.Fatal("accepted an invalid request")
+			}
+
+			var envelope apierror.Response
+			if err := json.Unmarshal(w.Body.Bytes(), &envelope); err != nil {
+				t.Fatal(err)
+			}
+			if w.Code != http.StatusBadRequest || envelope.Code != apierror.CodeValidation {
+				t.Errorf("got %d %s, want 400 %s", w.Code, envelope.Code, apierror.CodeValidation)
+			}
+			if !maps.Equal(envelope.Fields, tt.fields) {
+				t.Errorf("fields = %v, want %v", envelope.Fields, tt.fields)
+			}
+		})
+	}
+}
+
+func TestDecodeRequestInvalidJSON(t *testing.T) {
+	r := httptest.NewRequest("POST", "/", strings.NewReader(`{"email": `))
+	w := httptest.NewRecorder()
+	if decodeRequest(w, r, &models.UserLogin{}) {
+		t.Fatal("accepted truncated JSON")
+	}
+
+	var envelope apierror.Response
+	if err := json.Unmarshal(w.Body.Bytes(), &envelope); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusBadRequest || envelope.Code != apierror.CodeBadRequest || envelope.Fields != nil {
+		t.Errorf("got %d %+v, want 400 %s without fields", w.Code, envelope, apierror.CodeBadRequest)
+	}
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"ecotracker-backend/apierror"
+	"net/http"
+	"strconv"
+)
+
+// includeItems reads the "items" query parameter, which lets receipt
+// listings leave out line items. Items are included unless it is false; an
+// unparseable value is answered with 400.
+func includeItems(w http.ResponseWriter, r *http.Request) (bool, bool) {
+	return queryBool(w, r, "items", true)
+}
+
+// queryBool reads an optional boolean query parameter, returning def when it
+// is absent and answering 400 when it cannot be parsed.
+func queryBool(w http.ResponseWriter, r *http.Request, name string, def bool) (bool, bool) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return def, true
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		apierror.Write(w, http.StatusBadRequest, apierror.CodeBadRequest, "Invalid "+name, nil)
+		return false, false
+	}
+	return b, true
+}
+
+// IDHandler handles a route with a single integer path parameter.
+type IDHandler func(w http.ResponseWriter, r *http.Request, id int)
+
+// WithID parses the named path parameter of the route pattern (for example
+// {id} in "/api/users/{id}") and passes it to next, answering 400 when it is
+// not a positive integer.
+func WithID(name string, next IDHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.PathValue(name))
+		if err != nil || id <= 0 {
+			apierror.Write(w, http.StatusBadRequest, apierror.CodeBadRequest, "Invalid "+name, nil)
+			return
+		}
+
+		next(w, r, id)
+	}
+}
+
+// IDsHandler handles a route with two integer path parameters.
+type IDsHandler func(w http.ResponseWriter, r *http.Request, id, subID int)
+
+// WithIDs is WithID for routes with two path parameters, such as
+// "/api/shops/{id}/items/{item_id}".
+func WithIDs(name, subName string, next IDsHandler) http.HandlerFunc {
+	return WithID(name, func(w http.ResponseWriter, r *http.Request, id int) {
+		WithID(subName, func(w http.ResponseWriter, r *http.Request, subID int) {
+			next(w, r, id, subID)
+		})(w, r)
+	})
+}
+
+// queryID reads an optional integer query parameter, returning 0 when it is
+// absent and answering 400 when it is not a positive integer.
+func queryID(w http.ResponseWriter, r *http.Request, name string) (int, bool) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return 0, true
+	}
+
+	id, err := strconv.Atoi(value)
+	if err != nil || id <= 0 {
+		apierror.Write(w, http.StatusBadRequest, apierror.CodeBadRequest, "Invalid "+name, nil)
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"ecotracker-backend/auth"
+	"ecotracker-backend/models"
+	"ecotracker-backend/services"
+	"encoding/json"
+	"net/http"
+)
+
+type PointsHandler struct {
+	pointsService *services.PointsService
+	rulesService  *services.PointsRulesService
+}
+
+func NewPointsHandler(pointsService *services.PointsService, rulesService *services.PointsRulesService) *PointsHandler {
+	return &PointsHandler{pointsService: pointsService, rulesService: rulesService}
+}
+
+func (h *PointsHandler) GetHistory(w http.ResponseWriter, r *http.Request, userID int) {
+	if !authorize(w, r, func(p auth.Principal) bool { return auth.CanAccessUser(p, userID) }) {
+		return
+	}
+
+	entries, err := h.pointsService.GetHistory(userID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+// GetRules returns the points rules in effect, so clients can show
+// promotions.
+func (h *PointsHandler) GetRules(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, authenticated) {
+		return
+	}
+
+	rules, err := h.rulesService.GetRules()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rules)
+}
+
+func (h *PointsHandler) SetRules(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.CanManagePointsRules) {
+		return
+	}
+
+	var req models.PointsRules
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	if err := h.rulesService.SetRules(req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(req)
+}
+
+// ResetRules goes back to the rules from the configuration and returns them.
+func (h *PointsHandler) ResetRules(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.CanManagePointsRules) {
+		return
+	}
+
+	rules, err := h.rulesService.ResetRules()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rules)
+}
+
+// Preview is the dry run of the points rules: it quotes a basket without
+// recording anything. Quoting against a customer's daily cap reveals what
+// the customer earned today, so a shop may only do it for its customers,
+// and previewing draft rules is for admins.
+func (h *PointsHandler) Preview(w http.ResponseWriter, r *http.Request) {
+	var req models.PointsPreviewRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	principal, _ := auth.FromContext(r.Context())
+	isCustomer := false
+	if req.UserID != 0 && principal.IsShop() {
+		var err error
+		if isCustomer, err = h.rulesService.IsCustomer(principal.ID, req.UserID); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+
+	if !authorize(w, r, func(p auth.Principal) bool {
+		if req.UserID != 0 && !auth.CanViewEarnings(p, req.UserID, isCustomer) {
+			return false
+		}
+		return req.Rules == nil || auth.CanManagePointsRules(p)
+	}) {
+		return
+	}
+
+	quote, err := h.rulesService.Preview(req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(quote)
+}
@@ -1,12 +1,12 @@
 ﻿package handlers
 
 import (
+"ecotracker-backend/apierror"
+"ecotracker-backend/auth"
 "ecotracker-backend/models"
 "ecotracker-backend/services"
 "encoding/json"
 "net/http"
-"strconv"
-"strings"
 )
 
 type ReceiptHandler struct {
@@ -18,20 +18,42 @@ return &ReceiptHandler{receiptService: receiptService}
 }
 
 func (h *ReceiptHandler) CreateReceipt(w http.ResponseWriter, r *http.Request) {
-if r.Method != "POST" {
-http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+var req models.ReceiptCreate
+if !decodeRequest(w, r, &req) {
 return
 }
 
-var req models.ReceiptCreate
-if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-http.Error(w, "Invalid JSON", http.StatusBadRequest)
+if !authorize(w, r, func(p auth.Principal) bool { return auth.CanManageShop(p, req.ShopID) }) {
+return
+}
+
+// With an Idempotency-Key header a retry returns the receipt created
+// the first time rather than a duplicate
+key := r.Header.Get("Idempotency-Key")
+if len(key) > maxIdempotencyKeyLength {
+apierror.Write(w, http.StatusBadRequest, apierror.CodeBadRequest, "Invalid Idempotency-Key", nil)
 return
 }
 
-receipt, err := h.receiptService.CreateReceipt(req)
+var receipt *models.Receipt
+var err error
+if key == "" {
+receipt, err = h.receiptService.CreateReceipt(req)
+} else {
+principal, _ := auth.FromContext(r.Context())
+var replayed bool
+receipt, replayed, err = h.receiptService.CreateReceiptOnce(models.IdempotencyKey{
+PrincipalKind: principal.Kind,
+PrincipalID:   principal.ID,
+Scope:         "POST /api/receipts",
+Key:           key,
+}, req)
+if replayed {
+w.Header().Set("Idempotent-Replayed", "true")
+}
+}
 if err != nil {
-http.Error(w, err.Error(), http.StatusBadRequest)
+writeError(w, err)
 return
 }
 
@@ -39,30 +61,23 @@ w.Header().Set("Content-Type", "application/json")
 json.NewEncoder(w).Encode(receipt)
 }
 
-func (h *ReceiptHandler) GetUserReceipts(w http.ResponseWriter, r *http.Request) {
-if r.Method != "GET" {
-http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-return
-}
+// maxIdempotencyKeyLength bounds the Idempotency-Key header; UUIDs, the
+// usual choice, are 36 characters.
+const maxIdempotencyKeyLength = 255
 
-// Extract ID from URL path
-path := strings.TrimPrefix(r.URL.Path, "/api/users/")
-if path == "" {
-http.Error(w, "User ID required", http.StatusBadRequest)
+func (h *ReceiptHandler) GetUserReceipts(w http.ResponseWriter, r *http.Request, userID int) {
+if !authorize(w, r, func(p auth.Principal) bool { return auth.CanAccessUser(p, userID) }) {
 return
 }
 
-// Remove "/receipts" suffix to get just the user ID
-userIDStr := strings.TrimSuffix(path, "/receipts")
-userID, err := strconv.Atoi(userIDStr)
-if err != nil {
-http.Error(w, "Invalid user ID", http.StatusBadRequest)
+withItems, ok := includeItems(w, r)
+if !ok {
 return
 }
 
-receipts, err := h.receiptService.GetUserReceipts(userID)
+receipts, err := h.receiptService.GetUserReceipts(userID, withItems)
 if err != nil {
-http.Error(w, err.Error(), http.StatusInternalServerError)
+writeError(w, err)
 return
 }
 
@@ -70,89 +85,86 @@ w.Header().Set("Content-Type", "application/json")
 json.NewEncoder(w).Encode(receipts)
 }
 
-func (h *ReceiptHandler) DeleteReceipt(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "DELETE" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+func (h *ReceiptHandler) GetReceipt(w http.ResponseWriter, r *http.Request, receiptID int) {
+	receipt, err := h.receiptService.GetReceipt(receiptID)
+	if err != nil {
+		writeError(w, err)
 		return
 	}
 
-	// Extract receipt ID from URL path
-	path := strings.TrimPrefix(r.URL.Path, "/api/receipts/")
-	if path == "" {
-		http.Error(w, "Receipt ID required", http.StatusBadRequest)
+	if !authorizeFound(w, r, "receipt", func(p auth.Principal) bool { return auth.CanViewReceipt(p, receipt.UserID, receipt.ShopID) }) {
 		return
 	}
 
-	receiptID, err := strconv.Atoi(path)
-	if err != nil {
-		http.Error(w, "Invalid receipt ID", http.StatusBadRequest)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(receipt)
+}
+
+// VoidReceipt refunds whatever is left of a receipt and reverses its
+// points. DELETE /api/receipts/{id} is kept as an alias: receipts are never
+// deleted, so they stay available for audit.
+func (h *ReceiptHandler) VoidReceipt(w http.ResponseWriter, r *http.Request, receiptID int) {
+	if !h.authorizeRefund(w, r, receiptID) {
 		return
 	}
 
-	err = h.receiptService.DeleteReceipt(receiptID)
+	receipt, err := h.receiptService.VoidReceipt(receiptID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"message": "Receipt deleted successfully"})
+	json.NewEncoder(w).Encode(receipt)
 }
 
-func (h *ReceiptHandler) GetUserChallenges(w http.ResponseWriter, r *http.Request) {
-if r.Method != "GET" {
-http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-return
-}
-
-// Extract ID from URL path
-path := strings.TrimPrefix(r.URL.Path, "/api/users/")
-if path == "" {
-http.Error(w, "User ID required", http.StatusBadRequest)
-return
-}
+// RefundItems refunds some lines of a receipt and reverses the points they
+// earned.
+func (h *ReceiptHandler) RefundItems(w http.ResponseWriter, r *http.Request, receiptID int) {
+	var req models.RefundRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
 
-// Remove "/challenges" suffix to get just the user ID
-userIDStr := strings.TrimSuffix(path, "/challenges")
-userID, err := strconv.Atoi(userIDStr)
-if err != nil {
-http.Error(w, "Invalid user ID", http.StatusBadRequest)
-return
-}
+	if !h.authorizeRefund(w, r, receiptID) {
+		return
+	}
 
-challenges, err := h.receiptService.GetUserChallenges(userID)
-if err != nil {
-http.Error(w, err.Error(), http.StatusInternalServerError)
-return
-}
+	receipt, err := h.receiptService.RefundItems(receiptID, req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
 
-w.Header().Set("Content-Type", "application/json")
-json.NewEncoder(w).Encode(challenges)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(receipt)
 }
 
-func (h *ReceiptHandler) GetShopReceipts(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "GET" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+// authorizeRefund checks that the principal is the shop that issued the
+// receipt; anyone else gets 404.
+func (h *ReceiptHandler) authorizeRefund(w http.ResponseWriter, r *http.Request, receiptID int) bool {
+	receipt, err := h.receiptService.GetReceipt(receiptID)
+	if err != nil {
+		writeError(w, err)
+		return false
 	}
 
-	// Extract shop ID from URL path
-	path := strings.TrimPrefix(r.URL.Path, "/api/shops/")
-	path = strings.TrimSuffix(path, "/receipts")
-	if path == "" {
-		http.Error(w, "Shop ID required", http.StatusBadRequest)
+	return authorizeFound(w, r, "receipt", func(p auth.Principal) bool { return auth.CanManageShop(p, receipt.ShopID) })
+}
+
+func (h *ReceiptHandler) GetShopReceipts(w http.ResponseWriter, r *http.Request, shopID int) {
+	if !authorize(w, r, func(p auth.Principal) bool { return auth.CanManageShop(p, shopID) }) {
 		return
 	}
 
-	shopID, err := strconv.Atoi(path)
-	if err != nil {
-		http.Error(w, "Invalid shop ID", http.StatusBadRequest)
+	withItems, ok := includeItems(w, r)
+	if !ok {
 		return
 	}
 
-	receipts, err := h.receiptService.GetShopReceipts(shopID)
+	receipts, err := h.receiptService.GetShopReceipts(shopID, withItems)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"ecotracker-backend/apierror"
+	"ecotracker-backend/auth"
+	"ecotracker-backend/models"
+	"ecotracker-backend/services"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Receipt lists carry their items unless ?items=false asks for the
+// receipts alone.
+func TestReceiptListItems(t *testing.T) {
+	store := newTestStore(t)
+	receiptService := services.NewReceiptService(store, services.NewPointsRulesService(store, models.DefaultPointsRules()), time.Hour)
+	receipts := NewReceiptHandler(receiptService)
+
+	user := &models.User{Email: "c@example.com", Password: "x", Name: "Customer", Phone: "+441234567890"}
+	if err := store.Users().Create(user); err != nil {
+		t.Fatal(err)
+	}
+	shop := &models.Shop{Email: "s@example.com", Password: "x", Name: "Shop", Address: "1 High St", Phone: "+441234567891"}
+	if err := store.Shops().Create(shop); err != nil {
+		t.Fatal(err)
+	}
+	customer := auth.Principal{Kind: auth.KindUser, ID: user.ID, Role: auth.RoleCustomer}
+	shopkeeper := auth.Principal{Kind: auth.KindShop, ID: shop.ID, Role: auth.RoleShopkeeper}
+
+	var items []*models.ShopItem
+	for _, name := range []string{"Bread", "Milk"} {
+		item := &models.ShopItem{
+			ShopID:   shop.ID,
+			Name:     name,
+			Price:    models.Money{Amount: 300, Currency: models.DefaultCurrency},
+			Currency: models.DefaultCurrency,
+			Category: "Food",
+		}
+		if err := store.Shops().AddItem(item); err != nil {
+			t.Fatal(err)
+		}
+		items = append(items, item)
+	}
+	// The first receipt sells bread, the second milk twice
+	for i, item := range items {
+		_, err := receiptService.CreateReceipt(models.ReceiptCreate{
+			UserID: user.ID,
+			ShopID: shop.ID,
+			Items:  []models.ReceiptLine{{ItemID: item.ID, Quantity: i + 1}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	list := func(principal auth.Principal, target string, handler func(http.ResponseWriter, *http.Request, int), id int) []models.Receipt {
+		t.Helper()
+		w := call(principal, "GET", target, nil, func(w http.ResponseWriter, r *http.Request) { handler(w, r, id) })
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200; body %s", w.Code, w.Body)
+		}
+		var receipts []models.Receipt
+		if err := json.Unmarshal(w.Body.Bytes(), &receipts); err != nil {
+			t.Fatal(err)
+		}
+		if len(receipts) != 2 {
+			t.Fatalf("got %d receipts, want 2", len(receipts))
+		}
+		return receipts
+	}
+
+	tests := []struct {
+		name      string
+		principal auth.Principal
+		handler   func(http.ResponseWriter, *http.Request, int)
+		id        int
+	}{
+		{"customer", customer, receipts.GetUserReceipts, user.ID},
+		{"shop", shopkeeper, receipts.GetShopReceipts, shop.ID},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Newest first: milk, then bread
+			want := []struct {
+				name     string
+				quantity int
+			}{{"Milk", 2}, {"Bread", 1}}
+			for _, target := range []string{"/", "/?items=true"} {
+				for i, receipt := range list(tt.principal, target, tt.handler, tt.id) {
+					if len(receipt.Items) != 1 || receipt.Items[0].Name != want[i].name || receipt.Items[0].Quantity != want[i].quantity {
+						t.Errorf("%s: items of receipt %d = %+v, want %d %s", target, receipt.ID, receipt.Items, want[i].quantity, want[i].name)
+					}
+				}
+			}
+
+			for _, receipt := range list(tt.principal, "/?items=false", tt.handler, tt.id) {
+				if len(receipt.Items) != 0 {
+					t.Errorf("?items=false: receipt %d has items %+v", receipt.ID, receipt.Items)
+				}
+			}
+
+			w := call(tt.principal, "GET", "/?items=maybe", nil, func(w http.ResponseWriter, r *http.Request) { tt.handler(w, r, tt.id) })
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("?items=maybe: status = %d, want 400", w.Code)
+			}
+		})
+	}
+}
+
+// A retry with the same Idempotency-Key gets the first receipt back, marked
+// as a replay; the key sent with a different receipt gets 409.
+func TestCreateReceiptIdempotencyKey(t *testing.T) {
+	store := newTestStore(t)
+	receipts := NewReceiptHandler(services.NewReceiptService(store, services.NewPointsRulesService(store, models.DefaultPointsRules()), time.Hour))
+
+	user := &models.User{Email: "c@example.com", Password: "x", Name: "Customer", Phone: "+441234567890"}
+	if err := store.Users().Create(user); err != nil {
+		t.Fatal(err)
+	}
+	shop := &models.Shop{Email: "s@example.com", Password: "x", Name: "Shop", Address: "1 High St", Phone: "+441234567891"}
+	if err := store.Shops().Create(shop); err != nil {
+		t.Fatal(err)
+	}
+	shopkeeper := auth.Principal{Kind: auth.KindShop, ID: shop.ID, Role: auth.RoleShopkeeper}
+	item := &models.ShopItem{
+		ShopID:   shop.ID,
+		Name:     "Bread",
+		Price:    models.Money{Amount: 300, Currency: models.DefaultCurrency},
+		Currency: models.DefaultCurrency,
+		Category: "Food",
+	}
+	if err := store.Shops().AddItem(item); err != nil {
+		t.Fatal(err)
+	}
+
+	create := func(key string, quantity int) *httptest.ResponseRecorder {
+		t.Helper()
+		body := models.ReceiptCreate{UserID: user.ID, ShopID: shop.ID, Items: []models.ReceiptLine{{ItemID: item.ID, Quantity: quantity}}}
+		return call(shopkeeper, "POST", "/", body, func(w http.ResponseWriter, r *http.Request) {
+			r.Header.Set("Idempotency-Key", key)
+			receipts.CreateReceipt(w, r)
+		})
+	}
+
+	first := create("key-1", 1)
+	if first.Code != http.StatusOK || first.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatalf("first request: status %d, replayed %q; want 200, not replayed", first.Code, first.Header().Get("Idempotent-Replayed"))
+	}
+	retry := create("key-1", 1)
+	if retry.Code != http.StatusOK || retry.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatalf("retry: status %d, replayed %q; want 200, replayed", retry.Code, retry.Header().Get("Idempotent-Replayed"))
+	}
+	if retry.Body.String() != first.Body.String() {
+		t.Errorf("retry body = %s, want the first response %s", retry.Body, first.Body)
+	}
+
+	reused := create("key-1", 2)
+	var envelope apierror.Response
+	if err := json.Unmarshal(reused.Body.Bytes(), &envelope); err != nil {
+		t.Fatal(err)
+	}
+	if reused.Code != http.StatusConflict || envelope.Code != apierror.CodeConflict {
+		t.Errorf("reused key: status %d, code %q; want 409 %q", reused.Code, envelope.Code, apierror.CodeConflict)
+	}
+
+	if tooLong := create(strings.Repeat("k", maxIdempotencyKeyLength+1), 1); tooLong.Code != http.StatusBadRequest {
+		t.Errorf("overlong key: status %d, want 400", tooLong.Code)
+	}
+
+	if list, err := store.Receipts().ListByUser(user.ID); err != nil || len(list) != 1 {
+		t.Errorf("got %d receipts, %v; want 1", len(list), err)
+	}
+}
@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"ecotracker-backend/auth"
+	"ecotracker-backend/models"
+	"ecotracker-backend/services"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+type RewardHandler struct {
+	rewardService *services.RewardService
+}
+
+func NewRewardHandler(rewardService *services.RewardService) *RewardHandler {
+	return &RewardHandler{rewardService: rewardService}
+}
+
+// ListRewards returns the active rewards, only those valid at one shop when
+// ?shop_id= is given. The shop itself and admins also see inactive ones.
+func (h *RewardHandler) ListRewards(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, authenticated) {
+		return
+	}
+	principal, _ := auth.FromContext(r.Context())
+
+	shopID, ok := queryID(w, r, "shop_id")
+	if !ok {
+		return
+	}
+	includeInactive := principal.IsAdmin() || (shopID != 0 && auth.CanManageShop(principal, shopID))
+
+	rewards, err := h.rewardService.ListRewards(shopID, includeInactive)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rewards)
+}
+
+func (h *RewardHandler) CreateReward(w http.ResponseWriter, r *http.Request) {
+	var req models.RewardDefinition
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	if !authorize(w, r, func(p auth.Principal) bool { return auth.CanManageRewards(p, req.ShopID) }) {
+		return
+	}
+
+	reward, err := h.rewardService.CreateReward(req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reward)
+}
+
+// UpdateReward replaces a reward. The caller must be allowed to manage both
+// the reward's current shop and the one it is moved to.
+func (h *RewardHandler) UpdateReward(w http.ResponseWriter, r *http.Request, rewardID int) {
+	var req models.RewardDefinition
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	reward, err := h.rewardService.GetReward(rewardID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if !authorize(w, r, func(p auth.Principal) bool {
+		return auth.CanManageRewards(p, reward.ShopID) && auth.CanManageRewards(p, req.ShopID)
+	}) {
+		return
+	}
+
+	reward, err = h.rewardService.UpdateReward(rewardID, req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reward)
+}
+
+// Redeem spends the customer's points on a reward and returns the issued
+// redemption with its one-time code.
+func (h *RewardHandler) Redeem(w http.ResponseWriter, r *http.Request, rewardID int) {
+	if !authorize(w, r, func(p auth.Principal) bool { return p.IsUser() }) {
+		return
+	}
+	principal, _ := auth.FromContext(r.Context())
+
+	redemption, err := h.rewardService.Redeem(principal.ID, rewardID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(redemption)
+}
+
+func (h *RewardHandler) GetUserRedemptions(w http.ResponseWriter, r *http.Request, userID int) {
+	if !authorize(w, r, func(p auth.Principal) bool { return auth.CanAccessUser(p, userID) }) {
+		return
+	}
+
+	redemptions, err := h.rewardService.GetUserRedemptions(userID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(redemptions)
+}
+
+// VerifyRedemption checks a code shown at checkout without using it up.
+func (h *RewardHandler) VerifyRedemption(w http.ResponseWriter, r *http.Request) {
+	var req models.RedemptionCode
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	redemption, ok := h.acceptableRedemption(w, r, req.Code)
+	if !ok {
+		return
+	}
+
+	redemption, err := h.rewardService.VerifyRedemption(redemption.Code)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(redemption)
+}
+
+// ConsumeRedemption uses a code up at checkout; a second attempt is
+// answered with 409.
+func (h *RewardHandler) ConsumeRedemption(w http.ResponseWriter, r *http.Request) {
+	var req models.RedemptionCode
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	redemption, ok := h.acceptableRedemption(w, r, req.Code)
+	if !ok {
+		return
+	}
+	principal, _ := auth.FromContext(r.Context())
+
+	shopID := 0
+	if principal.IsShop() {
+		shopID = principal.ID
+	}
+
+	redemption, err := h.rewardService.ConsumeRedemption(redemption.Code, shopID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(redemption)
+}
+
+// acceptableRedemption looks code up and checks the principal may accept it:
+// a shop can only accept codes for its own rewards and platform-wide ones.
+// Other shops are told the code does not exist rather than that it belongs
+// elsewhere.
+func (h *RewardHandler) acceptableRedemption(w http.ResponseWriter, r *http.Request, code string) (*models.Redemption, bool) {
+	if !authorize(w, r, auth.CanLookupCustomers) {
+		return nil, false
+	}
+	principal, _ := auth.FromContext(r.Context())
+
+	redemption, err := h.rewardService.GetRedemption(code)
+	if err == nil && !auth.CanAcceptRedemption(principal, redemption.ShopID) {
+		err = fmt.Errorf("redemption code %w", services.ErrNotFound)
+	}
+	if err != nil {
+		writeError(w, err)
+		return nil, false
+	}
+	return redemption, true
+}
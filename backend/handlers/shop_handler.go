@@ -1,35 +1,42 @@
 ﻿package handlers
 
 import (
+"ecotracker-backend/apierror"
+"ecotracker-backend/auth"
+"ecotracker-backend/catalogue"
 "ecotracker-backend/models"
 "ecotracker-backend/services"
 "encoding/json"
+"errors"
+"fmt"
+"log"
+"mime"
 "net/http"
-"strconv"
-"strings"
 )
 
 type ShopHandler struct {
-shopService *services.ShopService
+shopService    *services.ShopService
+sessionService *services.SessionService
 }
 
-func NewShopHandler(shopService *services.ShopService) *ShopHandler {
-return &ShopHandler{shopService: shopService}
+func NewShopHandler(shopService *services.ShopService, sessionService *services.SessionService) *ShopHandler {
+return &ShopHandler{shopService: shopService, sessionService: sessionService}
 }
 
-func (h *ShopHandler) Register(w http.ResponseWriter, r *http.Request) {
-if r.Method != "POST" {
-http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-return
+// shopSession is returned by register and login: the shop fields plus a
+// freshly issued token pair.
+type shopSession struct {
+*models.Shop
+*models.TokenPair
 }
 
+func (h *ShopHandler) Register(w http.ResponseWriter, r *http.Request) {
 var req struct {
 models.ShopRegistration
-Items []models.ShopItem `json:"items"`
+Items []models.ShopItem `json:"items" validate:"dive"`
 }
 
-if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-http.Error(w, "Invalid JSON", http.StatusBadRequest)
+if !decodeRequest(w, r, &req) {
 return
 }
 
@@ -44,29 +51,51 @@ shop, err = h.shopService.Register(req.ShopRegistration)
 }
 
 if err != nil {
-http.Error(w, err.Error(), http.StatusBadRequest)
+writeError(w, err)
+return
+}
+
+// Registration signs the new account in straight away
+tokens, err := h.sessionService.Create(auth.Principal{Kind: auth.KindShop, ID: shop.ID, Role: auth.RoleShopkeeper})
+if err != nil {
+writeError(w, err)
 return
 }
 
 w.Header().Set("Content-Type", "application/json")
-json.NewEncoder(w).Encode(shop)
+json.NewEncoder(w).Encode(shopSession{shop, tokens})
 }
 
 func (h *ShopHandler) Login(w http.ResponseWriter, r *http.Request) {
-if r.Method != "POST" {
-http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+var req models.ShopLogin
+if !decodeRequest(w, r, &req) {
 return
 }
 
-var req models.ShopLogin
-if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-http.Error(w, "Invalid JSON", http.StatusBadRequest)
+shop, err := h.shopService.Login(req)
+if err != nil {
+writeError(w, err)
 return
 }
 
-shop, err := h.shopService.Login(req)
+tokens, err := h.sessionService.Create(auth.Principal{Kind: auth.KindShop, ID: shop.ID, Role: auth.RoleShopkeeper})
+if err != nil {
+writeError(w, err)
+return
+}
+
+w.Header().Set("Content-Type", "application/json")
+json.NewEncoder(w).Encode(shopSession{shop, tokens})
+}
+
+func (h *ShopHandler) GetShop(w http.ResponseWriter, r *http.Request, shopID int) {
+if !authorize(w, r, authenticated) {
+return
+}
+
+shop, err := h.shopService.GetShop(shopID)
 if err != nil {
-http.Error(w, err.Error(), http.StatusUnauthorized)
+writeError(w, err)
 return
 }
 
@@ -74,83 +103,257 @@ w.Header().Set("Content-Type", "application/json")
 json.NewEncoder(w).Encode(shop)
 }
 
-func (h *ShopHandler) GetShop(w http.ResponseWriter, r *http.Request) {
-if r.Method != "GET" {
-http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+func (h *ShopHandler) AddItem(w http.ResponseWriter, r *http.Request, shopID int) {
+if !authorize(w, r, func(p auth.Principal) bool { return auth.CanManageShop(p, shopID) }) {
 return
 }
 
-// Extract ID from URL path
-path := strings.TrimPrefix(r.URL.Path, "/api/shops/")
-if path == "" {
-http.Error(w, "Shop ID required", http.StatusBadRequest)
+var item models.ShopItem
+if !decodeRequest(w, r, &item) {
 return
 }
 
-shop, err := h.shopService.GetShop(path)
+addedItem, err := h.shopService.AddItem(shopID, item)
 if err != nil {
-http.Error(w, err.Error(), http.StatusNotFound)
+writeError(w, err)
 return
 }
 
 w.Header().Set("Content-Type", "application/json")
-json.NewEncoder(w).Encode(shop)
+json.NewEncoder(w).Encode(addedItem)
 }
 
-func (h *ShopHandler) AddItem(w http.ResponseWriter, r *http.Request) {
-if r.Method != "POST" {
-http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+// GetItems returns the shop's catalogue; with ?archived=true it includes
+// archived items.
+func (h *ShopHandler) GetItems(w http.ResponseWriter, r *http.Request, shopID int) {
+if !authorize(w, r, authenticated) {
+return
+}
+archived, ok := queryBool(w, r, "archived", false)
+if !ok {
 return
 }
 
-// Extract shop ID from URL path
-path := strings.TrimPrefix(r.URL.Path, "/api/shops/")
-path = strings.TrimSuffix(path, "/items")
+items, err := h.shopService.GetItems(shopID, archived)
+if err != nil {
+writeError(w, err)
+return
+}
 
-shopID, err := strconv.Atoi(path)
+w.Header().Set("Content-Type", "application/json")
+json.NewEncoder(w).Encode(items)
+}
+
+func (h *ShopHandler) GetItem(w http.ResponseWriter, r *http.Request, shopID, itemID int) {
+if !authorize(w, r, authenticated) {
+return
+}
+
+item, err := h.shopService.GetItem(shopID, itemID)
 if err != nil {
-http.Error(w, "Invalid shop ID", http.StatusBadRequest)
+writeError(w, err)
+return
+}
+
+w.Header().Set("Content-Type", "application/json")
+json.NewEncoder(w).Encode(item)
+}
+
+// GetItemByBarcode looks up the item of the shop's catalogue with a scanned
+// EAN-13 or UPC-A barcode.
+func (h *ShopHandler) GetItemByBarcode(w http.ResponseWriter, r *http.Request, shopID int) {
+if !authorize(w, r, authenticated) {
+return
+}
+
+item, err := h.shopService.GetItemByBarcode(shopID, r.PathValue("code"))
+if err != nil {
+writeError(w, err)
+return
+}
+
+w.Header().Set("Content-Type", "application/json")
+json.NewEncoder(w).Encode(item)
+}
+
+func (h *ShopHandler) UpdateItem(w http.ResponseWriter, r *http.Request, shopID, itemID int) {
+if !authorize(w, r, func(p auth.Principal) bool { return auth.CanManageShop(p, shopID) }) {
 return
 }
 
 var item models.ShopItem
-if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
-http.Error(w, "Invalid JSON", http.StatusBadRequest)
+if !decodeRequest(w, r, &item) {
 return
 }
 
-addedItem, err := h.shopService.AddItem(shopID, item)
+updatedItem, err := h.shopService.UpdateItem(shopID, itemID, item)
 if err != nil {
-http.Error(w, err.Error(), http.StatusBadRequest)
+writeError(w, err)
 return
 }
 
 w.Header().Set("Content-Type", "application/json")
-json.NewEncoder(w).Encode(addedItem)
+json.NewEncoder(w).Encode(updatedItem)
+}
+
+func (h *ShopHandler) PatchItem(w http.ResponseWriter, r *http.Request, shopID, itemID int) {
+if !authorize(w, r, func(p auth.Principal) bool { return auth.CanManageShop(p, shopID) }) {
+return
+}
+
+var patch models.ShopItemPatch
+if !decodeRequest(w, r, &patch) {
+return
 }
 
-func (h *ShopHandler) GetItems(w http.ResponseWriter, r *http.Request) {
-if r.Method != "GET" {
-http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+updatedItem, err := h.shopService.PatchItem(shopID, itemID, patch)
+if err != nil {
+writeError(w, err)
+return
+}
+
+w.Header().Set("Content-Type", "application/json")
+json.NewEncoder(w).Encode(updatedItem)
+}
+
+// ArchiveItem answers DELETE on an item: it is archived rather than
+// deleted, since receipts refer to it.
+func (h *ShopHandler) ArchiveItem(w http.ResponseWriter, r *http.Request, shopID, itemID int) {
+if !authorize(w, r, func(p auth.Principal) bool { return auth.CanManageShop(p, shopID) }) {
+return
+}
+
+item, err := h.shopService.ArchiveItem(shopID, itemID)
+if err != nil {
+writeError(w, err)
 return
 }
 
-// Extract shop ID from URL path
-path := strings.TrimPrefix(r.URL.Path, "/api/shops/")
-path = strings.TrimSuffix(path, "/items")
+w.Header().Set("Content-Type", "application/json")
+json.NewEncoder(w).Encode(item)
+}
 
-shopID, err := strconv.Atoi(path)
+// GetItemPrices returns the price history of a catalogue item to the shop
+// that sells it.
+func (h *ShopHandler) GetItemPrices(w http.ResponseWriter, r *http.Request, itemID int) {
+item, err := h.shopService.GetItemByID(itemID)
 if err != nil {
-http.Error(w, "Invalid shop ID", http.StatusBadRequest)
+writeError(w, err)
+return
+}
+if !authorizeFound(w, r, "item", func(p auth.Principal) bool { return auth.CanManageShop(p, item.ShopID) }) {
 return
 }
 
-items, err := h.shopService.GetItems(shopID)
+prices, err := h.shopService.GetItemPrices(item.ShopID, item.ID)
 if err != nil {
-http.Error(w, err.Error(), http.StatusInternalServerError)
+writeError(w, err)
 return
 }
 
 w.Header().Set("Content-Type", "application/json")
-json.NewEncoder(w).Encode(items)
+json.NewEncoder(w).Encode(prices)
+}
+
+// maxImportBytes bounds the body of a catalogue import.
+const maxImportBytes = 10 << 20
+
+// ImportItems adds a CSV or JSON catalogue to the shop's in one go. The
+// format is the format query parameter, or CSV when the body is text/csv.
+// With ?upsert=true items replace the catalogue's items with their SKU,
+// and with ?dry_run=true the import is only checked.
+func (h *ShopHandler) ImportItems(w http.ResponseWriter, r *http.Request, shopID int) {
+if !authorize(w, r, func(p auth.Principal) bool { return auth.CanManageShop(p, shopID) }) {
+return
+}
+
+defaultFormat := catalogue.JSON
+if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "text/csv" {
+defaultFormat = catalogue.CSV
+}
+format, ok := catalogueFormat(w, r, defaultFormat)
+if !ok {
+return
+}
+upsert, ok := queryBool(w, r, "upsert", false)
+if !ok {
+return
+}
+dryRun, ok := queryBool(w, r, "dry_run", false)
+if !ok {
+return
+}
+
+items, err := catalogue.Read(http.MaxBytesReader(w, r.Body, maxImportBytes), format)
+var tooLarge *http.MaxBytesError
+if errors.As(err, &tooLarge) {
+apierror.Write(w, http.StatusRequestEntityTooLarge, apierror.CodeBadRequest, "Catalogue is too large", nil)
+return
+}
+if err != nil {
+writeError(w, err)
+return
+}
+
+result, err := h.shopService.ImportItems(shopID, items, models.ItemImportOptions{Upsert: upsert, DryRun: dryRun})
+if err != nil {
+writeError(w, err)
+return
+}
+
+w.Header().Set("Content-Type", "application/json")
+json.NewEncoder(w).Encode(result)
+}
+
+// ExportItems returns the shop's catalogue as a file in the format ImportItems
+// reads, JSON unless the format query parameter is csv. With
+// ?archived=true it includes archived items.
+func (h *ShopHandler) ExportItems(w http.ResponseWriter, r *http.Request, shopID int) {
+if !authorize(w, r, func(p auth.Principal) bool { return auth.CanManageShop(p, shopID) }) {
+return
+}
+
+format, ok := catalogueFormat(w, r, catalogue.JSON)
+if !ok {
+return
+}
+archived, ok := queryBool(w, r, "archived", false)
+if !ok {
+return
+}
+
+if _, err := h.shopService.GetShop(shopID); err != nil {
+writeError(w, err)
+return
+}
+items, err := h.shopService.GetItems(shopID, archived)
+if err != nil {
+writeError(w, err)
+return
+}
+
+contentType := "application/json"
+if format == catalogue.CSV {
+contentType = "text/csv; charset=utf-8"
+}
+w.Header().Set("Content-Type", contentType)
+w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="shop-%d-items.%s"`, shopID, format))
+// The status is sent by now, so a failed write can only be logged
+if err := catalogue.Write(w, format, items); err != nil {
+log.Printf("export items of shop %d: %v", shopID, err)
+}
+}
+
+// catalogueFormat reads the format query parameter of catalogue imports and
+// exports, answering 400 when it is neither csv nor json.
+func catalogueFormat(w http.ResponseWriter, r *http.Request, def string) (string, bool) {
+format := r.URL.Query().Get("format")
+if format == "" {
+return def, true
+}
+if !catalogue.IsFormat(format) {
+apierror.Write(w, http.StatusBadRequest, apierror.CodeBadRequest, "Invalid format", nil)
+return "", false
+}
+return format, true
 }
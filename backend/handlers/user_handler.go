@@ -1,82 +1,82 @@
 ﻿package handlers
 
 import (
+"ecotracker-backend/auth"
 "ecotracker-backend/models"
 "ecotracker-backend/services"
 "encoding/json"
 "net/http"
-"strconv"
-"strings"
 )
 
 type UserHandler struct {
-userService *services.UserService
+userService    *services.UserService
+sessionService *services.SessionService
 }
 
-func NewUserHandler(userService *services.UserService) *UserHandler {
-return &UserHandler{userService: userService}
+func NewUserHandler(userService *services.UserService, sessionService *services.SessionService) *UserHandler {
+return &UserHandler{userService: userService, sessionService: sessionService}
 }
 
-func (h *UserHandler) Register(w http.ResponseWriter, r *http.Request) {
-if r.Method != "POST" {
-http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-return
+// userSession is returned by register and login: the user fields plus a
+// freshly issued token pair.
+type userSession struct {
+*models.User
+*models.TokenPair
 }
 
+func (h *UserHandler) Register(w http.ResponseWriter, r *http.Request) {
 var req models.UserRegistration
-if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-http.Error(w, "Invalid JSON", http.StatusBadRequest)
+if !decodeRequest(w, r, &req) {
 return
 }
 
 user, err := h.userService.Register(req)
 if err != nil {
-http.Error(w, err.Error(), http.StatusBadRequest)
+writeError(w, err)
 return
 }
 
-w.Header().Set("Content-Type", "application/json")
-json.NewEncoder(w).Encode(user)
+// Registration signs the new account in straight away
+tokens, err := h.sessionService.Create(auth.Principal{Kind: auth.KindUser, ID: user.ID, Role: auth.RoleFor(auth.KindUser, user.Role)})
+if err != nil {
+writeError(w, err)
+return
 }
 
-func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
-if r.Method != "POST" {
-http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-return
+w.Header().Set("Content-Type", "application/json")
+json.NewEncoder(w).Encode(userSession{user, tokens})
 }
 
+func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 var req models.UserLogin
-if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-http.Error(w, "Invalid JSON", http.StatusBadRequest)
+if !decodeRequest(w, r, &req) {
 return
 }
 
 user, err := h.userService.Login(req)
 if err != nil {
-http.Error(w, err.Error(), http.StatusUnauthorized)
+writeError(w, err)
 return
 }
 
-w.Header().Set("Content-Type", "application/json")
-json.NewEncoder(w).Encode(user)
+tokens, err := h.sessionService.Create(auth.Principal{Kind: auth.KindUser, ID: user.ID, Role: auth.RoleFor(auth.KindUser, user.Role)})
+if err != nil {
+writeError(w, err)
+return
 }
 
-func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
-if r.Method != "GET" {
-http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-return
+w.Header().Set("Content-Type", "application/json")
+json.NewEncoder(w).Encode(userSession{user, tokens})
 }
 
-// Extract ID from URL path
-path := strings.TrimPrefix(r.URL.Path, "/api/users/")
-if path == "" {
-http.Error(w, "User ID required", http.StatusBadRequest)
+func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request, userID int) {
+if !authorize(w, r, func(p auth.Principal) bool { return auth.CanAccessUser(p, userID) }) {
 return
 }
 
-user, err := h.userService.GetUser(path)
+user, err := h.userService.GetUser(userID)
 if err != nil {
-http.Error(w, err.Error(), http.StatusNotFound)
+writeError(w, err)
 return
 }
 
@@ -85,22 +85,20 @@ json.NewEncoder(w).Encode(user)
 }
 
 func (h *UserHandler) ValidateCustomer(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "POST" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	if !authorize(w, r, auth.CanLookupCustomers) {
 		return
 	}
 
 	var req struct {
-		Identifier string `json:"identifier"`
+		Identifier string `json:"identifier" validate:"required"`
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+	if !decodeRequest(w, r, &req) {
 		return
 	}
 
 	user, err := h.userService.ValidateCustomer(req.Identifier)
 	if err != nil {
-		http.Error(w, "Customer not found", http.StatusNotFound)
+		writeError(w, err)
 		return
 	}
 
@@ -108,37 +106,22 @@ func (h *UserHandler) ValidateCustomer(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(user)
 }
 
-func (h *UserHandler) UpdateUserPoints(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "PUT" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	// Extract user ID from URL path
-	path := strings.TrimPrefix(r.URL.Path, "/api/users/")
-	path = strings.TrimSuffix(path, "/points")
-	if path == "" {
-		http.Error(w, "User ID required", http.StatusBadRequest)
-		return
-	}
-
-	userID, err := strconv.Atoi(path)
-	if err != nil {
-		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+func (h *UserHandler) UpdateUserPoints(w http.ResponseWriter, r *http.Request, userID int) {
+	if !authorize(w, r, auth.CanSetPoints) {
 		return
 	}
 
 	var req struct {
-		Points int `json:"points"`
+		Points int    `json:"points" validate:"gte=0"`
+		Reason string `json:"reason"`
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+	if !decodeRequest(w, r, &req) {
 		return
 	}
 
-	err = h.userService.UpdateUserPoints(userID, req.Points)
+	err := h.userService.UpdateUserPoints(userID, req.Points, req.Reason)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
 
@@ -1,124 +1,129 @@
 ﻿package main
 
 import (
+"crypto/rand"
+"ecotracker-backend/auth"
+"ecotracker-backend/config"
 "ecotracker-backend/database"
 "ecotracker-backend/handlers"
+"ecotracker-backend/repository"
+"ecotracker-backend/routes"
 "ecotracker-backend/services"
-"encoding/json"
-"fmt"
 "log"
-"net/http"
 "os"
-"strings"
+"time"
 )
 
 func main() {
+// Settings from defaults, CONFIG_FILE/-config, environment and flags
+cfg, args, err := config.Load(os.Args[1:])
+if err != nil {
+log.Fatal(err)
+}
+log.Printf("Configuration: %s", cfg)
+
 // Initialize database
-db, err := database.NewDatabase()
+db, err := database.NewDatabase(cfg.Database)
 if err != nil {
 panic(err)
 }
 defer db.Close()
 
-// Initialize services
-userService := services.NewUserService(db)
-shopService := services.NewShopService(db)
-receiptService := services.NewReceiptService(db)
-
-// Initialize handlers
-userHandler := handlers.NewUserHandler(userService)
-shopHandler := handlers.NewShopHandler(shopService)
-receiptHandler := handlers.NewReceiptHandler(receiptService)
-
-// CORS middleware
-corsHandler := func(h http.HandlerFunc) http.HandlerFunc {
-return func(w http.ResponseWriter, r *http.Request) {
-w.Header().Set("Access-Control-Allow-Origin", "*")
-w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-
-if r.Method == "OPTIONS" {
-w.WriteHeader(http.StatusOK)
+// The migrate subcommand manages the schema and exits
+if len(args) > 0 && args[0] == "migrate" {
+if err := runMigrate(db, args[1:]); err != nil {
+log.Fatal(err)
+}
 return
 }
 
-h(w, r)
-}
+// Bring the schema up to date
+if err := db.Migrate(); err != nil {
+panic(err)
 }
 
-// Main router function
-router := func(w http.ResponseWriter, r *http.Request) {
-path := r.URL.Path
-method := r.Method
-
-switch {
-case path == "/health":
-w.Header().Set("Content-Type", "application/json")
-json.NewEncoder(w).Encode(map[string]string{
-"status":   "healthy",
-"database": "connected",
-})
-
-case path == "/api/users/register" && method == "POST":
-userHandler.Register(w, r)
-
-case path == "/api/users/login" && method == "POST":
-userHandler.Login(w, r)
-
-case path == "/api/users/validate" && method == "POST":
-userHandler.ValidateCustomer(w, r)
-
-case strings.HasPrefix(path, "/api/users/") && method == "PUT" && strings.Contains(path, "/points"):
-userHandler.UpdateUserPoints(w, r)
-
-case strings.HasPrefix(path, "/api/users/") && method == "GET":
-// Check if it's receipts or challenges
-if strings.Contains(path, "/receipts") {
-receiptHandler.GetUserReceipts(w, r)
-} else if strings.Contains(path, "/challenges") {
-receiptHandler.GetUserChallenges(w, r)
-} else {
-userHandler.GetUser(w, r)
+// Password hashing for users and shops
+var hasher services.PasswordHasher = services.NewBcryptHasher(0)
+if cfg.PasswordHasher == "argon2id" {
+hasher = services.NewArgon2idHasher()
 }
 
-case path == "/api/shops/register" && method == "POST":
-shopHandler.Register(w, r)
+// Repositories for the configured database driver
+store := repository.New(db)
 
-case path == "/api/shops/login" && method == "POST":
-shopHandler.Login(w, r)
-
-case strings.HasPrefix(path, "/api/shops/") && method == "GET":
-// Check if it's items
-if strings.Contains(path, "/items") {
-shopHandler.GetItems(w, r)
-} else if strings.Contains(path, "/receipts") {
-receiptHandler.GetShopReceipts(w, r)
-} else {
-shopHandler.GetShop(w, r)
+// Points rules from the configured file until an admin saves others
+pointsRules, err := loadPointsRules(cfg.PointsRules)
+if err != nil {
+log.Fatal(err)
 }
 
-case strings.HasPrefix(path, "/api/shops/") && method == "POST" && strings.Contains(path, "/items"):
-shopHandler.AddItem(w, r)
-
-case path == "/api/receipts" && method == "POST":
-receiptHandler.CreateReceipt(w, r)
-
-case strings.HasPrefix(path, "/api/receipts/") && method == "DELETE":
-receiptHandler.DeleteReceipt(w, r)
+// Initialize services
+userService := services.NewUserService(store, hasher)
+shopService := services.NewShopService(store, hasher)
+pointsRulesService := services.NewPointsRulesService(store, pointsRules)
+receiptService := services.NewReceiptService(store, pointsRulesService, time.Duration(cfg.IdempotencyTTL))
+challengeService := services.NewChallengeService(store)
+pointsService := services.NewPointsService(store)
+rewardService := services.NewRewardService(store)
+
+// The reconcile subcommand checks balances against the points ledger and exits
+if len(args) > 0 && args[0] == "reconcile" {
+if err := runReconcile(pointsService, args[1:]); err != nil {
+log.Fatal(err)
+}
+return
+}
 
-default:
-http.Error(w, "Not Found", http.StatusNotFound)
+// The catalogue subcommand imports or exports a shop's items and exits
+if len(args) > 0 && args[0] == "catalogue" {
+if err := runCatalogue(shopService, args[1:]); err != nil {
+log.Fatal(err)
 }
+return
 }
 
-// Apply CORS to the main router
-http.HandleFunc("/", corsHandler(router))
+// Accounts listed in admin_emails get the admin role
+if len(cfg.AdminEmails) > 0 {
+if err := userService.PromoteAdmins(cfg.AdminEmails); err != nil {
+panic(err)
+}
+}
 
-port := "8000"
-if envPort := os.Getenv("PORT"); envPort != "" {
-port = envPort
+// Access tokens are signed with the auth secret; without it a random secret
+// is used and every session ends when the server restarts
+secret := []byte(cfg.Auth.Secret)
+if len(secret) == 0 {
+log.Println("AUTH_SECRET not set, using a random signing secret")
+secret = make([]byte, 32)
+if _, err := rand.Read(secret); err != nil {
+panic(err)
+}
 }
+tokenSigner := auth.NewTokenSigner(secret, time.Duration(cfg.Auth.AccessTTL))
+sessionService := services.NewSessionService(store, tokenSigner, time.Duration(cfg.Auth.RefreshTTL))
+// Access tokens of a session that was logged out are refused at once
+tokenSigner.CheckSessions(sessionService.Active)
+
+// Initialize handlers
+userHandler := handlers.NewUserHandler(userService, sessionService)
+shopHandler := handlers.NewShopHandler(shopService, sessionService)
+receiptHandler := handlers.NewReceiptHandler(receiptService)
+authHandler := handlers.NewAuthHandler(sessionService)
+challengeHandler := handlers.NewChallengeHandler(challengeService)
+pointsHandler := handlers.NewPointsHandler(pointsService, pointsRulesService)
+rewardHandler := handlers.NewRewardHandler(rewardService)
+
+// Route table shared by every endpoint; serve is defined per server
+// flavour (server_http.go, or server_gofr.go when built with -tags gofr)
+table := routes.Table(routes.Handlers{
+User:      userHandler,
+Shop:      shopHandler,
+Receipt:   receiptHandler,
+Auth:      authHandler,
+Challenge: challengeHandler,
+Points:    pointsHandler,
+Reward:    rewardHandler,
+})
 
-fmt.Printf("Server starting on port %s\n", port)
-log.Fatal(http.ListenAndServe(":"+port, nil))
+serve(cfg, table, tokenSigner)
 }
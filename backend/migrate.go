@@ -0,0 +1,50 @@
+package main
+
+import (
+	"ecotracker-backend/database"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+const migrateUsage = "usage: main migrate [up | down [steps] | status]"
+
+// runMigrate implements "main migrate up", "main migrate down [steps]" and
+// "main migrate status".
+func runMigrate(db *database.Database, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	switch args[0] {
+	case "up":
+		return db.Migrate()
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+			steps = n
+		}
+		return db.MigrateDown(steps)
+
+	case "status":
+		statuses, err := db.MigrationStatus()
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			applied := "pending"
+			if status.AppliedAt != nil {
+				applied = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%-24s %s\n", status.Version, status.Name, applied)
+		}
+		return nil
+	}
+
+	return errors.New(migrateUsage)
+}
@@ -0,0 +1,34 @@
+package models
+
+import "strings"
+
+// CanonicalBarcode checks the check digit of an EAN-13 or UPC-A barcode and
+// returns it as 13 digits. A UPC-A code is the EAN-13 code with a leading
+// zero, so both forms of a product's barcode give the same result.
+func CanonicalBarcode(code string) (string, bool) {
+	code = strings.TrimSpace(code)
+	if len(code) == 12 {
+		code = "0" + code
+	}
+	if len(code) != 13 {
+		return "", false
+	}
+
+	// Digits are weighted 1, 3, 1, 3, ... from the left; with the check
+	// digit the sum is a multiple of ten
+	sum := 0
+	for i, c := range code {
+		if c < '0' || c > '9' {
+			return "", false
+		}
+		digit := int(c - '0')
+		if i%2 == 1 {
+			digit *= 3
+		}
+		sum += digit
+	}
+	if sum%10 != 0 {
+		return "", false
+	}
+	return code, true
+}
@@ -0,0 +1,37 @@
+package models
+
+import "testing"
+
+func TestCanonicalBarcode(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want string
+		ok   bool
+	}{
+		{"EAN-13", "4006381333931", "4006381333931", true},
+		{"EAN-13 with check digit 0", "9780201379624", "9780201379624", true},
+		{"surrounding space", " 5901234123457\n", "5901234123457", true},
+		{"UPC-A", "036000291452", "0036000291452", true},
+		{"UPC-A as EAN-13", "0036000291452", "0036000291452", true},
+		{"EAN-13 check digit off by one", "4006381333932", "", false},
+		{"UPC-A check digit off by one", "036000291453", "", false},
+		{"swapped digits", "4006381339331", "", false},
+		{"empty", "", "", false},
+		{"EAN-8", "96385074", "", false},
+		{"11 digits", "03600029145", "", false},
+		{"GTIN-14", "00036000291452", "", false},
+		{"letter", "40063813339A1", "", false},
+		{"sign", "+036000291452", "", false},
+		{"inner space", "4006381 33931", "", false},
+		{"non-ASCII digits", "٤٠٠٦٣٨١٣٣٣٩٣١", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := CanonicalBarcode(tt.code)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("CanonicalBarcode(%q) = %q, %v; want %q, %v", tt.code, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
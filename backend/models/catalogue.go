@@ -0,0 +1,26 @@
+package models
+
+// ItemImportOptions control a catalogue import. With Upsert, an item whose
+// SKU is already in the catalogue replaces that item instead of being
+// rejected. With DryRun, the import is checked but nothing is saved.
+type ItemImportOptions struct {
+	Upsert bool
+	DryRun bool
+}
+
+// ItemImportResult reports a catalogue import. Items are the created and
+// updated items in import order; created items of a dry run have no ID.
+type ItemImportResult struct {
+	DryRun  bool       `json:"dry_run"`
+	Created int        `json:"created"`
+	Updated int        `json:"updated"`
+	Items   []ShopItem `json:"items"`
+}
+
+// CatalogueItem is an item read from a catalogue file. Fields are the
+// columns or JSON fields the file gives it; an item that replaces one
+// already in the catalogue changes only those.
+type CatalogueItem struct {
+	ShopItem
+	Fields []string `json:"-"`
+}
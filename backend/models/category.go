@@ -0,0 +1,28 @@
+package models
+
+// Categories lists the product categories shops can sell under.
+var Categories = []string{"Food", "Organic", "Eco-Friendly", "Fruits", "Vegetables", "General"}
+
+// IsCategory reports whether name is one of Categories.
+func IsCategory(name string) bool {
+	for _, category := range Categories {
+		if category == name {
+			return true
+		}
+	}
+	return false
+}
+
+// EcoCategories are the categories whose items count as eco-friendly even
+// when the shop did not flag them.
+var EcoCategories = []string{"Organic", "Eco-Friendly", "Fruits", "Vegetables"}
+
+// IsEcoCategory reports whether name is one of EcoCategories.
+func IsEcoCategory(name string) bool {
+	for _, category := range EcoCategories {
+		if category == name {
+			return true
+		}
+	}
+	return false
+}
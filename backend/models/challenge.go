@@ -2,19 +2,65 @@
 
 import "time"
 
+// Challenge is a goal customers work towards. When returned for a user it
+// carries their progress; completion is recorded once, at CompletedAt.
 type Challenge struct {
-ID          int       `json:"id"`
-UserID      int       `json:"user_id"`
-Name        string    `json:"name"`
-Description string    `json:"description"`
-Icon        string    `json:"icon"`
-Target      int       `json:"target"`
-Progress    int       `json:"progress"`
-Earned      bool      `json:"earned"`
-IsCompleted bool      `json:"is_completed"`
-Points      int       `json:"points"`
-CreatedAt   time.Time `json:"created_at"`
-UpdatedAt   time.Time `json:"updated_at"`
+ID          int           `json:"id"`
+UserID      int           `json:"user_id,omitempty"`
+Name        string        `json:"name"`
+Description string        `json:"description"`
+Icon        string        `json:"icon"`
+Rule        ChallengeRule `json:"rule"`
+Target      int           `json:"target"`
+Progress    int           `json:"progress"`
+Earned      bool          `json:"earned"`
+IsCompleted bool          `json:"is_completed"`
+CompletedAt *time.Time    `json:"completed_at,omitempty"`
+Points      int           `json:"points"`
+RetiredAt   *time.Time    `json:"retired_at,omitempty"`
+CreatedAt   time.Time     `json:"created_at"`
+UpdatedAt   time.Time     `json:"updated_at"`
+}
+
+// Challenge rule metrics.
+const (
+// MetricItems counts the units bought of matching items.
+MetricItems = "items"
+// MetricShops counts the distinct shops matching items were bought from.
+MetricShops = "shops"
+// MetricSpend sums what was spent on matching items, in whole currency units.
+MetricSpend = "spend"
+)
+
+// ChallengeCompletion is a challenge a customer completed and the bonus
+// points it paid.
+type ChallengeCompletion struct {
+ChallengeID int    `json:"challenge_id"`
+Name        string `json:"name"`
+Points      int    `json:"points"`
+}
+
+// ChallengeRule describes how progress towards a challenge is measured. An
+// item matches when its category is in Categories and, with EcoFriendly set,
+// it is flagged eco-friendly; a rule with neither matches every item.
+// WindowDays limits the rule to receipts from the last WindowDays days, or
+// all time when 0.
+type ChallengeRule struct {
+Metric      string   `json:"metric" validate:"required,oneof=items shops spend"`
+Categories  []string `json:"categories,omitempty" validate:"dive,category"`
+EcoFriendly bool     `json:"eco_friendly,omitempty"`
+WindowDays  int      `json:"window_days,omitempty" validate:"gte=0"`
+}
+
+// ChallengeDefinition is the body admins send to create or replace a
+// challenge.
+type ChallengeDefinition struct {
+Name        string        `json:"name" validate:"required"`
+Description string        `json:"description"`
+Icon        string        `json:"icon"`
+Rule        ChallengeRule `json:"rule"`
+Target      int           `json:"target" validate:"min=1"`
+Points      int           `json:"points" validate:"gte=0"`
 }
 
 type ChallengeUpdate struct {
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// IdempotencyKey identifies a request a client may retry: the key it sent
+// in the Idempotency-Key header, scoped to the caller and the endpoint.
+type IdempotencyKey struct {
+	PrincipalKind string
+	PrincipalID   int
+	Scope         string
+	Key           string
+}
+
+// IdempotencyRecord is what was stored for a key: a hash of the request
+// it was first used with and the response that request got.
+type IdempotencyRecord struct {
+	IdempotencyKey
+	RequestHash string
+	Response    string
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+}
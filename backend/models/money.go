@@ -0,0 +1,145 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency is used when a request does not name a currency.
+const DefaultCurrency = "USD"
+
+// Currencies lists the ISO 4217 codes accepted for prices. All of them have
+// two decimal places, which is what a minor unit means throughout.
+var Currencies = []string{"USD", "EUR", "GBP", "CHF", "CAD", "AUD", "NZD", "SEK", "NOK", "DKK", "PLN", "CZK", "INR", "ZAR", "SGD", "HKD", "MXN", "BRL"}
+
+// IsCurrency reports whether code is one of Currencies.
+func IsCurrency(code string) bool {
+	for _, currency := range Currencies {
+		if currency == code {
+			return true
+		}
+	}
+	return false
+}
+
+// minorPerMajor is the number of minor units (cents) in a major unit.
+const minorPerMajor = 100
+
+// ErrCurrencyMismatch is returned when amounts in different currencies are
+// combined.
+var ErrCurrencyMismatch = errors.New("currency mismatch")
+
+// Money is an exact amount: a whole number of minor units of Currency.
+//
+// In JSON it is written as a plain number of major units, e.g. 12.5, as
+// prices were before amounts were exact, and the currency travels in a
+// separate field of the enclosing object. It is read from such a number, a
+// decimal string, or {"amount": 1250, "currency": "EUR"} in minor units.
+// Numbers with more than two decimals are rounded half away from zero.
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+// Times returns m multiplied by n.
+func (m Money) Times(n int) Money {
+	return Money{Amount: m.Amount * int64(n), Currency: m.Currency}
+}
+
+// Plus returns m + o. An amount without a currency takes the other's.
+func (m Money) Plus(o Money) (Money, error) {
+	currency := m.Currency
+	if currency == "" {
+		currency = o.Currency
+	} else if o.Currency != "" && o.Currency != currency {
+		return Money{}, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, o.Currency)
+	}
+	return Money{Amount: m.Amount + o.Amount, Currency: currency}, nil
+}
+
+// Major returns the amount in major units. It is for calculations that are
+// approximate anyway, such as points, never for amounts that are stored.
+func (m Money) Major() float64 {
+	return float64(m.Amount) / minorPerMajor
+}
+
+// Decimal formats the amount in major units with two decimals, e.g. "12.50".
+func (m Money) Decimal() string {
+	sign, amount := "", m.Amount
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, amount/minorPerMajor, amount%minorPerMajor)
+}
+
+func (m Money) String() string {
+	if m.Currency == "" {
+		return m.Decimal()
+	}
+	return m.Decimal() + " " + m.Currency
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.Decimal()), nil
+}
+
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+
+	if len(data) > 0 && data[0] == '{' {
+		var object struct {
+			Amount   *int64 `json:"amount"`
+			Currency string `json:"currency"`
+		}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&object); err != nil {
+			return err
+		}
+		if object.Amount == nil {
+			return errors.New("money: amount is required")
+		}
+		*m = Money{Amount: *object.Amount, Currency: object.Currency}
+		return nil
+	}
+
+	text := string(data)
+	if unquoted, err := strconv.Unquote(text); err == nil {
+		text = unquoted
+	}
+
+	amount, err := ParseDecimal(text)
+	if err != nil {
+		return err
+	}
+	*m = Money{Amount: amount}
+	return nil
+}
+
+// ParseDecimal converts a decimal number of major units, such as "12.5" or
+// "1e2", to minor units, rounding half away from zero. Parsing the text
+// exactly, rather than through float64, keeps 0.29 from becoming 28 cents.
+func ParseDecimal(text string) (int64, error) {
+	value, ok := new(big.Rat).SetString(strings.TrimSpace(text))
+	if !ok {
+		return 0, fmt.Errorf("money: invalid amount %q", text)
+	}
+
+	value.Mul(value, big.NewRat(minorPerMajor, 1))
+	quotient, remainder := new(big.Int).QuoRem(value.Num(), value.Denom(), new(big.Int))
+
+	// Round half away from zero: |2 * remainder| >= denominator
+	if new(big.Int).Abs(new(big.Int).Lsh(remainder, 1)).Cmp(value.Denom()) >= 0 {
+		quotient.Add(quotient, big.NewInt(int64(value.Sign())))
+	}
+
+	if !quotient.IsInt64() {
+		return 0, fmt.Errorf("money: amount %q out of range", text)
+	}
+	return quotient.Int64(), nil
+}
@@ -0,0 +1,151 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseDecimal(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    int64
+		wantErr bool
+	}{
+		{text: "12", want: 1200},
+		{text: "12.5", want: 1250},
+		{text: "12.50", want: 1250},
+		{text: " 3.10 ", want: 310},
+		{text: "1e2", want: 10000},
+		// Exact, where float64 would make 28.999... cents
+		{text: "0.29", want: 29},
+		{text: "-12.5", want: -1250},
+		{text: "-0.01", want: -1},
+		// More than two decimals round half away from zero
+		{text: "0.284", want: 28},
+		{text: "0.285", want: 29},
+		{text: "-0.285", want: -29},
+		{text: "0.005", want: 1},
+		{text: "-0.005", want: -1},
+		{text: "0.0049", want: 0},
+		{text: "1.23456789", want: 123},
+		{text: "", wantErr: true},
+		{text: "abc", wantErr: true},
+		{text: "12,50", wantErr: true},
+		{text: "1e30", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			got, err := ParseDecimal(tt.text)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseDecimal(%q) error = %v, want error %v", tt.text, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseDecimal(%q) = %d, want %d", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoneyMarshalJSON(t *testing.T) {
+	tests := []struct {
+		money Money
+		want  string
+	}{
+		{Money{Amount: 1250, Currency: "EUR"}, `12.50`},
+		{Money{Amount: 7}, `0.07`},
+		{Money{Amount: 0}, `0.00`},
+		{Money{Amount: -5}, `-0.05`},
+		{Money{Amount: -1250, Currency: "GBP"}, `-12.50`},
+	}
+	for _, tt := range tests {
+		data, err := json.Marshal(tt.money)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != tt.want {
+			t.Errorf("Marshal(%v) = %s, want %s", tt.money, data, tt.want)
+		}
+	}
+}
+
+func TestMoneyUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		json    string
+		want    Money
+		wantErr bool
+	}{
+		{json: `12.5`, want: Money{Amount: 1250}},
+		{json: `"12.50"`, want: Money{Amount: 1250}},
+		{json: `0.29`, want: Money{Amount: 29}},
+		{json: `0.295`, want: Money{Amount: 30}},
+		{json: `-4.005`, want: Money{Amount: -401}},
+		{json: `{"amount": 1250, "currency": "EUR"}`, want: Money{Amount: 1250, Currency: "EUR"}},
+		{json: `{"amount": -3}`, want: Money{Amount: -3}},
+		{json: `{"currency": "EUR"}`, wantErr: true},
+		{json: `{"amount": 12.5}`, wantErr: true},
+		{json: `{"amount": 1, "cents": 1}`, wantErr: true},
+		{json: `"twelve"`, wantErr: true},
+		{json: `true`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.json, func(t *testing.T) {
+			var got Money
+			err := json.Unmarshal([]byte(tt.json), &got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unmarshal(%s) error = %v, want error %v", tt.json, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Unmarshal(%s) = %+v, want %+v", tt.json, got, tt.want)
+			}
+		})
+	}
+}
+
+// An amount survives a JSON round trip; its currency travels in a field of
+// its own.
+func TestMoneyJSONRoundTrip(t *testing.T) {
+	type priced struct {
+		Price    Money  `json:"price"`
+		Currency string `json:"currency"`
+	}
+	for _, amount := range []int64{0, 1, 29, 99, 100, 1250, 123456789, -1, -1250} {
+		in := priced{Price: Money{Amount: amount, Currency: "EUR"}, Currency: "EUR"}
+		data, err := json.Marshal(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var out priced
+		if err := json.Unmarshal(data, &out); err != nil {
+			t.Fatal(err)
+		}
+		if out.Price.Amount != amount || out.Currency != "EUR" {
+			t.Errorf("%s read back as %+v, want amount %d", data, out, amount)
+		}
+	}
+}
+
+// Minor units are cents everywhere, so only currencies with two decimal
+// places are accepted: neither the yen's none nor the dinar's three.
+func TestIsCurrency(t *testing.T) {
+	for _, code := range Currencies {
+		if !IsCurrency(code) {
+			t.Errorf("IsCurrency(%q) = false for a listed currency", code)
+		}
+	}
+	for _, code := range []string{"JPY", "KRW", "ISK", "KWD", "BHD", "TND", "eur", ""} {
+		if IsCurrency(code) {
+			t.Errorf("IsCurrency(%q) = true, want false", code)
+		}
+	}
+}
+
+func TestMoneyPlus(t *testing.T) {
+	sum, err := Money{Amount: 250, Currency: "EUR"}.Plus(Money{Amount: 5})
+	if err != nil || sum != (Money{Amount: 255, Currency: "EUR"}) {
+		t.Errorf("Plus = %+v, %v; want 2.55 EUR", sum, err)
+	}
+	if _, err := (Money{Amount: 1, Currency: "EUR"}).Plus(Money{Amount: 1, Currency: "USD"}); err == nil {
+		t.Error("adding EUR to USD succeeded")
+	}
+}
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// Kinds of points ledger entries.
+const (
+	PointsEarn     = "earn"
+	PointsBonus    = "bonus"
+	PointsRedeem   = "redeem"
+	PointsAdjust   = "adjust"
+	PointsReversal = "reversal"
+	PointsExpire   = "expire"
+)
+
+// PointsEntry is one change to a customer's points. The ledger is append
+// only; a customer's balance is the sum of their entries' amounts.
+type PointsEntry struct {
+	ID           int       `json:"id"`
+	UserID       int       `json:"user_id"`
+	Kind         string    `json:"kind"`
+	Amount       int       `json:"amount"`
+	Reason       string    `json:"reason"`
+	ReceiptID    *int      `json:"receipt_id,omitempty"`
+	ChallengeID  *int      `json:"challenge_id,omitempty"`
+	RedemptionID *int      `json:"redemption_id,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// PointsDrift is a customer whose stored balance no longer matches their
+// ledger.
+type PointsDrift struct {
+	UserID        int `json:"user_id"`
+	Balance       int `json:"balance"`
+	LedgerBalance int `json:"ledger_balance"`
+}
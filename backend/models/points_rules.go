@@ -0,0 +1,75 @@
+package models
+
+import "time"
+
+// PointsRules configures how many points a purchase earns. Every receipt
+// line earns BasePoints per unit of currency, multiplied by each rule that
+// matches it, rounded down. The receipt total is then limited by the caps;
+// a zero cap means no limit.
+type PointsRules struct {
+	BasePoints float64      `json:"base_points" validate:"gt=0"`
+	Rules      []PointsRule `json:"rules" validate:"dive"`
+	ReceiptCap int          `json:"receipt_cap,omitempty" validate:"gte=0"`
+	DailyCap   int          `json:"daily_cap,omitempty" validate:"gte=0"`
+}
+
+// PointsRule multiplies the points of the receipt lines it matches. A line
+// matches when it meets every condition that is set: one of Categories, the
+// EcoFriendly flag, one of ShopIDs, and a purchase time from StartsAt up to
+// but excluding EndsAt. Multipliers of several matching rules compound.
+type PointsRule struct {
+	Name        string     `json:"name" validate:"required"`
+	Multiplier  float64    `json:"multiplier" validate:"gt=0"`
+	Categories  []string   `json:"categories,omitempty" validate:"dive,category"`
+	EcoFriendly *bool      `json:"eco_friendly,omitempty"`
+	ShopIDs     []int      `json:"shop_ids,omitempty" validate:"dive,gt=0"`
+	StartsAt    *time.Time `json:"starts_at,omitempty"`
+	EndsAt      *time.Time `json:"ends_at,omitempty"`
+}
+
+// DefaultPointsRules earns a point per unit of currency, doubled for
+// eco-friendly items.
+func DefaultPointsRules() PointsRules {
+	eco := true
+	return PointsRules{
+		BasePoints: 1,
+		Rules: []PointsRule{
+			{Name: "Eco-friendly items", Multiplier: 2, EcoFriendly: &eco},
+		},
+	}
+}
+
+// PointsPreviewRequest asks what a basket would earn. With UserID set the
+// daily cap accounts for what the customer already earned today; Rules
+// previews a draft configuration instead of the current one.
+type PointsPreviewRequest struct {
+	UserID int           `json:"user_id" validate:"gte=0"`
+	ShopID int           `json:"shop_id" validate:"required"`
+	Items  []ReceiptItem `json:"items" validate:"required,min=1,dive"`
+	Rules  *PointsRules  `json:"rules,omitempty"`
+}
+
+// PointsQuote breaks down the points a basket earns.
+type PointsQuote struct {
+	Lines    []PointsLine `json:"lines"`
+	Subtotal int          `json:"subtotal"`
+	Points   int          `json:"points"`
+	// CappedBy is "receipt" or "daily" when a cap lowered Points.
+	CappedBy string `json:"capped_by,omitempty"`
+}
+
+type PointsLine struct {
+	Name          string   `json:"name"`
+	Category      string   `json:"category"`
+	IsEcoFriendly bool     `json:"is_eco_friendly"`
+	Amount        Money    `json:"amount"`
+	Multiplier    float64  `json:"multiplier"`
+	Rules         []string `json:"rules"`
+	Points        int      `json:"points"`
+}
+
+// Values of PointsQuote.CappedBy.
+const (
+	CappedByReceipt = "receipt"
+	CappedByDaily   = "daily"
+)
@@ -6,24 +6,91 @@ type Receipt struct {
 ID           int           `json:"id"`
 UserID       int           `json:"user_id"`
 ShopID       int           `json:"shop_id"`
-Items        []ReceiptItem `json:"items"`
-TotalAmount  float64       `json:"total_amount"`
+Items        []ReceiptItem `json:"items,omitempty"`
+TotalAmount  Money         `json:"total_amount"`
+Currency     string        `json:"currency"`
 PointsEarned int           `json:"points_earned"`
 CreatedAt    time.Time     `json:"created_at"`
+
+// Refunds keep the receipt for audit: Status tracks them, and
+// RefundedAmount and PointsReversed add them up.
+Status         string          `json:"status"`
+RefundedAmount Money           `json:"refunded_amount"`
+PointsReversed int             `json:"points_reversed"`
+Refunds        []ReceiptRefund `json:"refunds,omitempty"`
+
+// Set when the receipt is created: the challenges it completed and the
+// bonus points they paid on top of PointsEarned.
+CompletedChallenges []ChallengeCompletion `json:"completed_challenges,omitempty"`
+BonusPoints         int                   `json:"bonus_points,omitempty"`
 }
 
 type ReceiptItem struct {
-ID            int     `json:"id"`
-ReceiptID     int     `json:"receipt_id"`
-Name          string  `json:"name"`
-Price         float64 `json:"price"`
-Quantity      int     `json:"quantity"`
-Category      string  `json:"category"`
-IsEcoFriendly bool    `json:"is_eco_friendly"`
+ID            int    `json:"id"`
+ReceiptID     int    `json:"receipt_id"`
+Name          string `json:"name" validate:"required"`
+Price         Money  `json:"price" validate:"gt=0"`
+Quantity      int    `json:"quantity" validate:"min=1"`
+Category      string `json:"category" validate:"required,category"`
+IsEcoFriendly bool   `json:"is_eco_friendly"`
+
+// RefundedQuantity is how many of Quantity were refunded.
+RefundedQuantity int `json:"refunded_quantity"`
+
+// ItemID is the catalogue item the line was sold from; lines of receipts
+// from before receipts referenced the catalogue have none. ListPrice is
+// its catalogue price at the time of sale, and PriceOverride flags lines
+// sold at another price for audit.
+ItemID        *int   `json:"item_id,omitempty"`
+ListPrice     *Money `json:"list_price,omitempty"`
+PriceOverride bool   `json:"price_override"`
 }
 
+// ReceiptCreate is the body of a new receipt. Lines name items of the
+// shop's catalogue, which supplies their name, category and price. When
+// Currency is given it must be the currency of the items.
 type ReceiptCreate struct {
-UserID int           `json:"user_id" validate:"required"`
-ShopID int           `json:"shop_id" validate:"required"`
-Items  []ReceiptItem `json:"items" validate:"required"`
+UserID   int           `json:"user_id" validate:"required"`
+ShopID   int           `json:"shop_id" validate:"required"`
+Currency string        `json:"currency" validate:"omitempty,currency"`
+Items    []ReceiptLine `json:"items" validate:"required,min=1,dive"`
+}
+
+// ReceiptLine sells Quantity of a catalogue item, named by either ItemID or
+// the EAN-13 or UPC-A Barcode scanned at checkout. Price overrides the
+// catalogue price, for instance for a discount; the line is then flagged
+// for audit.
+type ReceiptLine struct {
+ItemID   int    `json:"item_id,omitempty"`
+Barcode  string `json:"barcode,omitempty" validate:"omitempty,barcode"`
+Quantity int    `json:"quantity" validate:"min=1"`
+Price    *Money `json:"price,omitempty" validate:"omitempty,gt=0"`
+}
+
+// Receipt statuses. A receipt whose every line is refunded is voided.
+const (
+ReceiptActive            = "active"
+ReceiptPartiallyRefunded = "partially_refunded"
+ReceiptVoided            = "voided"
+)
+
+// ReceiptRefund records lines given back and the points reversed for them.
+type ReceiptRefund struct {
+ID             int          `json:"id"`
+ReceiptID      int          `json:"receipt_id"`
+Amount         Money        `json:"amount"`
+PointsReversed int          `json:"points_reversed"`
+Reason         string       `json:"reason"`
+Items          []RefundLine `json:"items"`
+CreatedAt      time.Time    `json:"created_at"`
+}
+
+type RefundLine struct {
+ItemID   int `json:"item_id" validate:"required"`
+Quantity int `json:"quantity" validate:"min=1"`
+}
+
+type RefundRequest struct {
+Reason string       `json:"reason"`
+Items  []RefundLine `json:"items" validate:"required,min=1,dive"`
 }
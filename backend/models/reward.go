@@ -0,0 +1,55 @@
+package models
+
+import "time"
+
+// Reward is something customers can spend points on. A reward with a
+// ShopID is redeemed at that shop; one without is valid at every shop.
+// A nil Stock means unlimited.
+type Reward struct {
+	ID          int       `json:"id"`
+	ShopID      int       `json:"shop_id,omitempty"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	PointsCost  int       `json:"points_cost"`
+	Stock       *int      `json:"stock"`
+	Active      bool      `json:"active"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// RewardDefinition is the body sent to create or replace a reward. Active
+// defaults to true.
+type RewardDefinition struct {
+	ShopID      int    `json:"shop_id" validate:"gte=0"`
+	Name        string `json:"name" validate:"required"`
+	Description string `json:"description"`
+	PointsCost  int    `json:"points_cost" validate:"min=1"`
+	Stock       *int   `json:"stock" validate:"omitempty,gte=0"`
+	Active      *bool  `json:"active"`
+}
+
+// Redemption statuses.
+const (
+	RedemptionIssued   = "issued"
+	RedemptionConsumed = "consumed"
+)
+
+// Redemption is a reward a customer paid for. Its one-time Code is shown at
+// checkout, where the shop consumes it.
+type Redemption struct {
+	ID               int        `json:"id"`
+	RewardID         int        `json:"reward_id"`
+	RewardName       string     `json:"reward_name"`
+	ShopID           int        `json:"shop_id,omitempty"`
+	UserID           int        `json:"user_id"`
+	Code             string     `json:"code"`
+	PointsSpent      int        `json:"points_spent"`
+	Status           string     `json:"status"`
+	CreatedAt        time.Time  `json:"created_at"`
+	ConsumedAt       *time.Time `json:"consumed_at,omitempty"`
+	ConsumedByShopID int        `json:"consumed_by_shop_id,omitempty"`
+}
+
+type RedemptionCode struct {
+	Code string `json:"code" validate:"required"`
+}
@@ -15,13 +15,51 @@ UpdatedAt   time.Time `json:"updated_at"`
 }
 
 type ShopItem struct {
-ID            int     `json:"id"`
-ShopID        int     `json:"shop_id"`
-Name          string  `json:"name"`
-Price         float64 `json:"price"`
-Category      string  `json:"category"`
-Description   string  `json:"description"`
-IsEcoFriendly bool    `json:"is_eco_friendly"`
+ID            int    `json:"id"`
+ShopID        int    `json:"shop_id"`
+// SKU is the shop's own code for the item, unique among its items that
+// are not archived. Catalogue imports match existing items by it.
+SKU           string `json:"sku,omitempty" validate:"omitempty,max=64"`
+// Barcode is the item's EAN-13 barcode, also unique among the shop's
+// items that are not archived. A UPC-A barcode is stored as its EAN-13
+// form, with a leading zero.
+Barcode       string `json:"barcode,omitempty" validate:"omitempty,barcode"`
+Name          string `json:"name" validate:"required"`
+Price         Money  `json:"price" validate:"gt=0"`
+Currency      string `json:"currency" validate:"omitempty,currency"`
+Category      string `json:"category" validate:"required,category"`
+Description   string `json:"description"`
+IsEcoFriendly bool   `json:"is_eco_friendly"`
+
+// ArchivedAt is when the item was taken out of the catalogue. Archived
+// items can no longer be sold but stay on the receipts that sold them.
+ArchivedAt *time.Time `json:"archived_at,omitempty"`
+CreatedAt  time.Time  `json:"created_at"`
+UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// ShopItemPatch changes the fields of a catalogue item that are present.
+type ShopItemPatch struct {
+// An empty SKU or Barcode removes it from the item.
+SKU           *string `json:"sku" validate:"omitempty,max=64"`
+Barcode       *string `json:"barcode" validate:"omitempty,barcode|len=0"`
+Name          *string `json:"name" validate:"omitempty,min=1"`
+Price         *Money  `json:"price" validate:"omitempty,gt=0"`
+Currency      *string `json:"currency" validate:"omitempty,currency"`
+Category      *string `json:"category" validate:"omitempty,category"`
+Description   *string `json:"description"`
+IsEcoFriendly *bool   `json:"is_eco_friendly"`
+}
+
+// ShopItemPrice is a price of a catalogue item, in effect from
+// EffectiveFrom until the next one. Currency is the currency of Price, which
+// is encoded as a bare amount.
+type ShopItemPrice struct {
+ID            int       `json:"id"`
+ItemID        int       `json:"item_id"`
+Price         Money     `json:"price"`
+Currency      string    `json:"currency"`
+EffectiveFrom time.Time `json:"effective_from"`
 }
 
 type ShopRegistration struct {
@@ -29,7 +67,7 @@ Email       string `json:"email" validate:"required,email"`
 Password    string `json:"password" validate:"required,min=6"`
 Name        string `json:"name" validate:"required"`
 Address     string `json:"address" validate:"required"`
-Phone       string `json:"phone" validate:"required"`
+Phone       string `json:"phone" validate:"required,phone"`
 Description string `json:"description"`
 }
 
@@ -9,6 +9,7 @@ Password  string    `json:"-"`
 Name      string    `json:"name"`
 Phone     string    `json:"phone"`
 Points    int       `json:"points"`
+Role      string    `json:"role"`
 CreatedAt time.Time `json:"created_at"`
 UpdatedAt time.Time `json:"updated_at"`
 }
@@ -17,7 +18,7 @@ type UserRegistration struct {
 Email    string `json:"email" validate:"required,email"`
 Password string `json:"password" validate:"required,min=6"`
 Name     string `json:"name" validate:"required"`
-Phone    string `json:"phone" validate:"required"`
+Phone    string `json:"phone" validate:"required,phone"`
 }
 
 type UserLogin struct {
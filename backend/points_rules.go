@@ -0,0 +1,40 @@
+package main
+
+import (
+	"ecotracker-backend/models"
+	"ecotracker-backend/services"
+	"ecotracker-backend/validation"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// loadPointsRules reads the points rules file named in the configuration,
+// or returns the built-in rules when there is none. Unknown fields and
+// invalid rules are errors, so a typo cannot silently change earnings.
+func loadPointsRules(path string) (models.PointsRules, error) {
+	if path == "" {
+		return models.DefaultPointsRules(), nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return models.PointsRules{}, fmt.Errorf("points rules: %w", err)
+	}
+	defer file.Close()
+
+	var rules models.PointsRules
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&rules); err != nil {
+		return models.PointsRules{}, fmt.Errorf("points rules %s: %w", path, err)
+	}
+
+	if err := validation.Struct(&rules); err != nil {
+		return models.PointsRules{}, fmt.Errorf("points rules %s: %w", path, err)
+	}
+	if err := services.CheckPointsRules(rules); err != nil {
+		return models.PointsRules{}, fmt.Errorf("points rules %s: %w", path, err)
+	}
+	return rules, nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"ecotracker-backend/services"
+	"errors"
+	"fmt"
+)
+
+const reconcileUsage = "usage: main reconcile [check | fix]"
+
+// runReconcile implements "main reconcile check", which lists the users
+// whose stored points differ from their ledger and fails if there are any,
+// and "main reconcile fix", which resets those balances to the ledger.
+func runReconcile(pointsService *services.PointsService, args []string) error {
+	if len(args) == 0 {
+		return errors.New(reconcileUsage)
+	}
+
+	switch args[0] {
+	case "check":
+		drift, err := pointsService.FindDrift()
+		if err != nil {
+			return err
+		}
+		for _, d := range drift {
+			fmt.Printf("user %d: balance %d, ledger %d\n", d.UserID, d.Balance, d.LedgerBalance)
+		}
+		if len(drift) > 0 {
+			return fmt.Errorf("%d balances drift from the points ledger", len(drift))
+		}
+		fmt.Println("All balances match the points ledger")
+		return nil
+
+	case "fix":
+		drift, err := pointsService.Reconcile()
+		if err != nil {
+			return err
+		}
+		for _, d := range drift {
+			fmt.Printf("user %d: balance %d reset to ledger %d\n", d.UserID, d.Balance, d.LedgerBalance)
+		}
+		fmt.Printf("Reconciled %d balances\n", len(drift))
+		return nil
+	}
+
+	return errors.New(reconcileUsage)
+}
@@ -0,0 +1,188 @@
+package repository
+
+import (
+	"database/sql"
+	"ecotracker-backend/models"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type challengeRepository struct {
+	q querier
+}
+
+const challengeColumns = `id, name, description, icon, rule, target, points, retired_at, created_at, updated_at`
+
+func scanChallenge(row scanner) (*models.Challenge, error) {
+	challenge := &models.Challenge{}
+	var rule string
+	var retiredAt sql.NullTime
+	err := row.Scan(&challenge.ID, &challenge.Name, &challenge.Description, &challenge.Icon,
+		&rule, &challenge.Target, &challenge.Points, &retiredAt, &challenge.CreatedAt, &challenge.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(rule), &challenge.Rule); err != nil {
+		return nil, fmt.Errorf("challenge %d: invalid rule: %w", challenge.ID, err)
+	}
+	if retiredAt.Valid {
+		challenge.RetiredAt = &retiredAt.Time
+	}
+	return challenge, nil
+}
+
+func (r *challengeRepository) Create(challenge *models.Challenge) error {
+	rule, err := json.Marshal(challenge.Rule)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+
+	id, err := r.q.insert(`
+		INSERT INTO challenges (name, description, icon, rule, target, points, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id`,
+		challenge.Name, challenge.Description, challenge.Icon, string(rule),
+		challenge.Target, challenge.Points, now, now)
+	if err != nil {
+		return err
+	}
+
+	challenge.ID = id
+	challenge.CreatedAt = now
+	challenge.UpdatedAt = now
+	return nil
+}
+
+func (r *challengeRepository) Update(challenge *models.Challenge) error {
+	rule, err := json.Marshal(challenge.Rule)
+	if err != nil {
+		return err
+	}
+	challenge.UpdatedAt = time.Now()
+
+	_, err = r.q.Exec(`
+		UPDATE challenges SET name = ?, description = ?, icon = ?, rule = ?, target = ?, points = ?, updated_at = ?
+		WHERE id = ?`,
+		challenge.Name, challenge.Description, challenge.Icon, string(rule),
+		challenge.Target, challenge.Points, challenge.UpdatedAt, challenge.ID)
+	return err
+}
+
+func (r *challengeRepository) GetByID(id int) (*models.Challenge, error) {
+	return scanChallenge(r.q.QueryRow(`SELECT `+challengeColumns+` FROM challenges WHERE id = ?`, id))
+}
+
+func (r *challengeRepository) List(includeRetired bool) ([]models.Challenge, error) {
+	query := `SELECT ` + challengeColumns + ` FROM challenges`
+	if !includeRetired {
+		query += ` WHERE retired_at IS NULL`
+	}
+
+	rows, err := r.q.Query(query + ` ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var challenges []models.Challenge
+	for rows.Next() {
+		challenge, err := scanChallenge(rows)
+		if err != nil {
+			return nil, err
+		}
+		challenges = append(challenges, *challenge)
+	}
+
+	return challenges, rows.Err()
+}
+
+func (r *challengeRepository) Retire(id int, retiredAt time.Time) error {
+	_, err := r.q.Exec(`UPDATE challenges SET retired_at = ?, updated_at = ? WHERE id = ? AND retired_at IS NULL`,
+		retiredAt, retiredAt, id)
+	return err
+}
+
+func (r *challengeRepository) Progress(userID int, rule models.ChallengeRule) (int, error) {
+	var measure string
+	switch rule.Metric {
+	case models.MetricItems:
+		measure = `COALESCE(SUM(ri.quantity - ri.refunded_quantity), 0)`
+	case models.MetricShops:
+		measure = `COUNT(DISTINCT r.shop_id)`
+	case models.MetricSpend:
+		measure = `COALESCE(SUM(ri.price_minor * (ri.quantity - ri.refunded_quantity)), 0) / 100`
+	default:
+		return 0, fmt.Errorf("unknown challenge metric %q", rule.Metric)
+	}
+
+	// Refunded purchases do not count
+	conditions := []string{"r.user_id = ?", "ri.refunded_quantity < ri.quantity"}
+	args := []interface{}{userID}
+
+	if rule.WindowDays > 0 {
+		conditions = append(conditions, "r.created_at >= ?")
+		args = append(args, time.Now().AddDate(0, 0, -rule.WindowDays))
+	}
+
+	// An item must meet every condition of the rule
+	if len(rule.Categories) > 0 {
+		conditions = append(conditions, "ri.category IN ("+placeholders(len(rule.Categories))+")")
+		for _, category := range rule.Categories {
+			args = append(args, category)
+		}
+	}
+	if rule.EcoFriendly {
+		conditions = append(conditions, "ri.is_eco_friendly = TRUE")
+	}
+
+	// Spend is summed in minor units; progress counts whole currency units
+	var progress float64
+	err := r.q.QueryRow(`
+		SELECT `+measure+`
+		FROM receipts r
+		JOIN receipt_items ri ON ri.receipt_id = r.id
+		WHERE `+strings.Join(conditions, " AND "),
+		args...).Scan(&progress)
+	if err != nil {
+		return 0, err
+	}
+	return int(progress), nil
+}
+
+func (r *challengeRepository) Completions(userID int) (map[int]time.Time, error) {
+	rows, err := r.q.Query(`SELECT challenge_id, completed_at FROM user_challenges WHERE user_id = ?`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	completions := map[int]time.Time{}
+	for rows.Next() {
+		var challengeID int
+		var completedAt time.Time
+		if err := rows.Scan(&challengeID, &completedAt); err != nil {
+			return nil, err
+		}
+		completions[challengeID] = completedAt
+	}
+
+	return completions, rows.Err()
+}
+
+func (r *challengeRepository) RecordCompletion(userID, challengeID, receiptID, pointsAwarded int, completedAt time.Time) (bool, error) {
+	result, err := r.q.Exec(`
+		INSERT INTO user_challenges (user_id, challenge_id, receipt_id, points_awarded, completed_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (user_id, challenge_id) DO NOTHING`,
+		userID, challengeID, nullID(receiptID), pointsAwarded, completedAt)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"ecotracker-backend/models"
+	"testing"
+)
+
+// The challenges seeded by migration 0004, with the rule of "Sustainable
+// Living" as migration 0016 left it, measured against a customer's
+// purchases. Items in an eco category are flagged eco-friendly when sold,
+// as the catalogue does, and so is a General item the shop flagged.
+func TestProgressSeededChallenges(t *testing.T) {
+	store := newSQLiteStore(t)
+	user := addUser(t, store, "a@example.com")
+	other := addUser(t, store, "b@example.com")
+	market := addShop(t, store, "market@example.com")
+	grocer := addShop(t, store, "grocer@example.com")
+
+	line := func(category string, quantity int, eco bool) models.ReceiptItem {
+		return models.ReceiptItem{
+			Name:          category,
+			Price:         models.Money{Amount: 100, Currency: "EUR"},
+			Quantity:      quantity,
+			Category:      category,
+			IsEcoFriendly: eco,
+		}
+	}
+	addReceipt := func(userID, shopID int, items ...models.ReceiptItem) {
+		t.Helper()
+		receipt := &models.Receipt{
+			UserID:      userID,
+			ShopID:      shopID,
+			TotalAmount: models.Money{Amount: 100, Currency: "EUR"},
+			Currency:    "EUR",
+			Status:      models.ReceiptActive,
+			Items:       items,
+		}
+		if err := store.Receipts().Create(receipt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	addReceipt(user.ID, market.ID,
+		line("Organic", 2, true),
+		line("Fruits", 3, true),
+		line("Eco-Friendly", 1, true),
+		line("General", 4, true),
+		line("Food", 1, false),
+	)
+	addReceipt(user.ID, grocer.ID,
+		line("Vegetables", 2, true),
+		line("Eco-Friendly", 2, true),
+	)
+	addReceipt(other.ID, grocer.ID, line("Organic", 5, true), line("Eco-Friendly", 5, true))
+
+	challenges, err := store.Challenges().List(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byName := make(map[string]models.Challenge, len(challenges))
+	for _, challenge := range challenges {
+		byName[challenge.Name] = challenge
+	}
+
+	tests := []struct {
+		name string
+		want int
+	}{
+		{"Eco-Friendly Shopping", 2},
+		{"Fruit & Veggie Lover", 5},
+		// Every flagged item counts, produce and the flagged General item
+		// included, but not the unflagged Food item
+		{"Sustainable Living", 14},
+		{"Local Business Support", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			challenge, ok := byName[tt.name]
+			if !ok {
+				t.Fatalf("challenge %q is not seeded", tt.name)
+			}
+			progress, err := store.Challenges().Progress(user.ID, challenge.Rule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if progress != tt.want {
+				t.Errorf("progress = %d, want %d", progress, tt.want)
+			}
+		})
+	}
+}
+
+// An item must meet every condition of a rule. A flagged item counts
+// towards an eco_friendly rule whatever its category.
+func TestProgressRuleConditions(t *testing.T) {
+	store := newSQLiteStore(t)
+	user := addUser(t, store, "a@example.com")
+	shop := addShop(t, store, "shop@example.com")
+
+	receipt := &models.Receipt{
+		UserID:      user.ID,
+		ShopID:      shop.ID,
+		TotalAmount: models.Money{Amount: 100, Currency: "EUR"},
+		Currency:    "EUR",
+		Status:      models.ReceiptActive,
+	}
+	for _, item := range []struct {
+		category string
+		quantity int
+		eco      bool
+	}{
+		{"Vegetables", 2, true},
+		// Sold before eco categories were flagged automatically
+		{"Vegetables", 1, false},
+		{"General", 4, true},
+		{"Eco-Friendly", 3, true},
+	} {
+		receipt.Items = append(receipt.Items, models.ReceiptItem{
+			Name:          item.category,
+			Price:         models.Money{Amount: 100, Currency: "EUR"},
+			Quantity:      item.quantity,
+			Category:      item.category,
+			IsEcoFriendly: item.eco,
+		})
+	}
+	if err := store.Receipts().Create(receipt); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		rule models.ChallengeRule
+		want int
+	}{
+		{"flagged items", models.ChallengeRule{Metric: models.MetricItems, EcoFriendly: true}, 9},
+		{"category", models.ChallengeRule{Metric: models.MetricItems, Categories: []string{"Vegetables"}}, 3},
+		{"flagged items of a category", models.ChallengeRule{Metric: models.MetricItems, Categories: []string{"Vegetables"}, EcoFriendly: true}, 2},
+		{"every item", models.ChallengeRule{Metric: models.MetricItems}, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			progress, err := store.Challenges().Progress(user.ID, tt.rule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if progress != tt.want {
+				t.Errorf("progress = %d, want %d", progress, tt.want)
+			}
+		})
+	}
+}
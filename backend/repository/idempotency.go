@@ -0,0 +1,48 @@
+package repository
+
+import "ecotracker-backend/models"
+
+type idempotencyRepository struct {
+	q querier
+}
+
+func (r *idempotencyRepository) Claim(record *models.IdempotencyRecord) (bool, error) {
+	// Expired keys are purged here rather than by a background job
+	if _, err := r.q.Exec(`DELETE FROM idempotency_keys WHERE expires_at <= ?`, record.CreatedAt); err != nil {
+		return false, err
+	}
+
+	result, err := r.q.Exec(`
+		INSERT INTO idempotency_keys (principal_kind, principal_id, scope, key, request_hash, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (principal_kind, principal_id, scope, key) DO NOTHING`,
+		record.PrincipalKind, record.PrincipalID, record.Scope, record.Key, record.RequestHash,
+		record.CreatedAt, record.ExpiresAt)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+func (r *idempotencyRepository) Get(key models.IdempotencyKey) (*models.IdempotencyRecord, error) {
+	record := &models.IdempotencyRecord{IdempotencyKey: key}
+	err := r.q.QueryRow(`
+		SELECT request_hash, response, created_at, expires_at FROM idempotency_keys
+		WHERE principal_kind = ? AND principal_id = ? AND scope = ? AND key = ?`,
+		key.PrincipalKind, key.PrincipalID, key.Scope, key.Key).Scan(
+		&record.RequestHash, &record.Response, &record.CreatedAt, &record.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+func (r *idempotencyRepository) Complete(key models.IdempotencyKey, response string) error {
+	_, err := r.q.Exec(`
+		UPDATE idempotency_keys SET response = ?
+		WHERE principal_kind = ? AND principal_id = ? AND scope = ? AND key = ?`,
+		response, key.PrincipalKind, key.PrincipalID, key.Scope, key.Key)
+	return err
+}
@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"ecotracker-backend/models"
+	"time"
+)
+
+type pointsRepository struct {
+	q querier
+}
+
+func (r *pointsRepository) Append(entry *models.PointsEntry) error {
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+
+	id, err := r.q.insert(`
+		INSERT INTO points_ledger (user_id, kind, amount, reason, receipt_id, challenge_id, redemption_id, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id`,
+		entry.UserID, entry.Kind, entry.Amount, entry.Reason, entry.ReceiptID, entry.ChallengeID,
+		entry.RedemptionID, entry.CreatedAt)
+	if err != nil {
+		return err
+	}
+	entry.ID = id
+
+	// users.points caches the ledger balance
+	_, err = r.q.Exec(`UPDATE users SET points = points + ?, updated_at = ? WHERE id = ?`,
+		entry.Amount, entry.CreatedAt, entry.UserID)
+	return err
+}
+
+func (r *pointsRepository) Spend(entry *models.PointsEntry) (bool, error) {
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+
+	// The balance check and the debit are one statement, so two concurrent
+	// redemptions cannot both spend the same points.
+	result, err := r.q.Exec(`
+		UPDATE users SET points = points + ?, updated_at = ?
+		WHERE id = ? AND points + ? >= 0`,
+		entry.Amount, entry.CreatedAt, entry.UserID, entry.Amount)
+	if err != nil {
+		return false, err
+	}
+	if rows, err := result.RowsAffected(); err != nil || rows == 0 {
+		return false, err
+	}
+
+	id, err := r.q.insert(`
+		INSERT INTO points_ledger (user_id, kind, amount, reason, receipt_id, challenge_id, redemption_id, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id`,
+		entry.UserID, entry.Kind, entry.Amount, entry.Reason, entry.ReceiptID, entry.ChallengeID,
+		entry.RedemptionID, entry.CreatedAt)
+	if err != nil {
+		return false, err
+	}
+	entry.ID = id
+	return true, nil
+}
+
+func (r *pointsRepository) Earned(userID int, since time.Time) (int, error) {
+	var earned int
+	err := r.q.QueryRow(`
+		SELECT COALESCE(SUM(amount), 0) FROM points_ledger
+		WHERE user_id = ? AND kind = ? AND created_at >= ?`,
+		userID, models.PointsEarn, since).Scan(&earned)
+	return earned, err
+}
+
+func (r *pointsRepository) History(userID int) ([]models.PointsEntry, error) {
+	rows, err := r.q.Query(`
+		SELECT id, user_id, kind, amount, reason, receipt_id, challenge_id, redemption_id, created_at
+		FROM points_ledger WHERE user_id = ? ORDER BY id DESC`,
+		userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []models.PointsEntry{}
+	for rows.Next() {
+		var entry models.PointsEntry
+		err := rows.Scan(&entry.ID, &entry.UserID, &entry.Kind, &entry.Amount, &entry.Reason,
+			&entry.ReceiptID, &entry.ChallengeID, &entry.RedemptionID, &entry.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+func (r *pointsRepository) Drift() ([]models.PointsDrift, error) {
+	rows, err := r.q.Query(`
+		SELECT u.id, COALESCE(u.points, 0), COALESCE(l.total, 0)
+		FROM users u
+		LEFT JOIN (SELECT user_id, SUM(amount) AS total FROM points_ledger GROUP BY user_id) l
+			ON l.user_id = u.id
+		WHERE COALESCE(u.points, 0) <> COALESCE(l.total, 0)
+		ORDER BY u.id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var drift []models.PointsDrift
+	for rows.Next() {
+		var d models.PointsDrift
+		if err := rows.Scan(&d.UserID, &d.Balance, &d.LedgerBalance); err != nil {
+			return nil, err
+		}
+		drift = append(drift, d)
+	}
+
+	return drift, rows.Err()
+}
+
+func (r *pointsRepository) Reconcile(userID int) error {
+	_, err := r.q.Exec(`
+		UPDATE users SET points = (SELECT COALESCE(SUM(amount), 0) FROM points_ledger WHERE user_id = ?),
+			updated_at = ?
+		WHERE id = ?`,
+		userID, time.Now(), userID)
+	return err
+}
@@ -0,0 +1,262 @@
+package repository
+
+import (
+	"database/sql"
+	"ecotracker-backend/models"
+	"time"
+)
+
+type receiptRepository struct {
+	q querier
+}
+
+const receiptColumns = `id, user_id, shop_id, total_minor, currency, points_earned, created_at,
+	status, refunded_minor, points_reversed`
+
+func scanReceipt(row scanner) (*models.Receipt, error) {
+	receipt := &models.Receipt{}
+	err := row.Scan(&receipt.ID, &receipt.UserID, &receipt.ShopID,
+		&receipt.TotalAmount.Amount, &receipt.Currency, &receipt.PointsEarned, &receipt.CreatedAt,
+		&receipt.Status, &receipt.RefundedAmount.Amount, &receipt.PointsReversed)
+	if err != nil {
+		return nil, err
+	}
+	receipt.TotalAmount.Currency = receipt.Currency
+	receipt.RefundedAmount.Currency = receipt.Currency
+	return receipt, nil
+}
+
+func (r *receiptRepository) Create(receipt *models.Receipt) error {
+	now := time.Now()
+
+	id, err := r.q.insert(`
+		INSERT INTO receipts (user_id, shop_id, total_minor, currency, points_earned, created_at, status)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		RETURNING id`,
+		receipt.UserID, receipt.ShopID, receipt.TotalAmount.Amount, receipt.Currency, receipt.PointsEarned, now,
+		models.ReceiptActive)
+	if err != nil {
+		return err
+	}
+
+	receipt.ID = id
+	receipt.CreatedAt = now
+	receipt.Status = models.ReceiptActive
+	receipt.RefundedAmount = models.Money{Currency: receipt.Currency}
+
+	for i := range receipt.Items {
+		item := &receipt.Items[i]
+		itemID, err := r.q.insert(`
+			INSERT INTO receipt_items (receipt_id, name, price_minor, quantity, category, is_eco_friendly,
+				item_id, price_override, list_price_minor)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+			RETURNING id`,
+			receipt.ID, item.Name, item.Price.Amount, item.Quantity, item.Category, item.IsEcoFriendly,
+			item.ItemID, item.PriceOverride, listPriceMinor(item))
+		if err != nil {
+			return err
+		}
+		item.ID = itemID
+		item.ReceiptID = receipt.ID
+	}
+
+	return nil
+}
+
+func listPriceMinor(item *models.ReceiptItem) sql.NullInt64 {
+	if item.ListPrice == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: item.ListPrice.Amount, Valid: true}
+}
+
+func (r *receiptRepository) GetByID(id int) (*models.Receipt, error) {
+	return scanReceipt(r.q.QueryRow(`SELECT `+receiptColumns+` FROM receipts WHERE id = ?`, id))
+}
+
+func (r *receiptRepository) ListByUser(userID int) ([]models.Receipt, error) {
+	return r.list(`SELECT `+receiptColumns+` FROM receipts WHERE user_id = ? ORDER BY created_at DESC, id DESC`, userID)
+}
+
+func (r *receiptRepository) ListByShop(shopID int) ([]models.Receipt, error) {
+	return r.list(`SELECT `+receiptColumns+` FROM receipts WHERE shop_id = ? ORDER BY created_at DESC, id DESC`, shopID)
+}
+
+func (r *receiptRepository) IsCustomer(shopID, userID int) (bool, error) {
+	return r.q.exists(`SELECT COUNT(*) FROM receipts WHERE shop_id = ? AND user_id = ?`, shopID, userID)
+}
+
+func (r *receiptRepository) list(query string, args ...interface{}) ([]models.Receipt, error) {
+	rows, err := r.q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var receipts []models.Receipt
+	for rows.Next() {
+		receipt, err := scanReceipt(rows)
+		if err != nil {
+			return nil, err
+		}
+		receipts = append(receipts, *receipt)
+	}
+
+	return receipts, rows.Err()
+}
+
+func (r *receiptRepository) LoadItems(receipts []models.Receipt) error {
+	byID := make(map[int]*models.Receipt, len(receipts))
+	ids := make([]interface{}, 0, len(receipts))
+	for i := range receipts {
+		byID[receipts[i].ID] = &receipts[i]
+		ids = append(ids, receipts[i].ID)
+	}
+
+	// Long receipt histories are loaded a chunk of receipts at a time
+	for len(ids) > 0 {
+		chunk := ids[:min(len(ids), maxInList)]
+		ids = ids[len(chunk):]
+		if err := r.loadItems(byID, chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadItems appends the items of the receipts with the given IDs to those
+// in byID.
+func (r *receiptRepository) loadItems(byID map[int]*models.Receipt, ids []interface{}) error {
+	rows, err := r.q.Query(`
+		SELECT id, receipt_id, name, price_minor, quantity, category, is_eco_friendly, refunded_quantity,
+			item_id, price_override, list_price_minor
+		FROM receipt_items WHERE receipt_id IN (`+placeholders(len(ids))+`) ORDER BY id`,
+		ids...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item models.ReceiptItem
+		var listPrice sql.NullInt64
+		err := rows.Scan(&item.ID, &item.ReceiptID, &item.Name, &item.Price.Amount,
+			&item.Quantity, &item.Category, &item.IsEcoFriendly, &item.RefundedQuantity,
+			&item.ItemID, &item.PriceOverride, &listPrice)
+		if err != nil {
+			return err
+		}
+		if listPrice.Valid {
+			item.ListPrice = &models.Money{Amount: listPrice.Int64}
+		}
+
+		// Items are priced in the currency of their receipt
+		if receipt := byID[item.ReceiptID]; receipt != nil {
+			item.Price.Currency = receipt.Currency
+			if item.ListPrice != nil {
+				item.ListPrice.Currency = receipt.Currency
+			}
+			receipt.Items = append(receipt.Items, item)
+		}
+	}
+
+	return rows.Err()
+}
+
+func (r *receiptRepository) AddRefund(refund *models.ReceiptRefund, status string) error {
+	if refund.CreatedAt.IsZero() {
+		refund.CreatedAt = time.Now()
+	}
+
+	id, err := r.q.insert(`
+		INSERT INTO receipt_refunds (receipt_id, amount_minor, points_reversed, reason, created_at)
+		VALUES (?, ?, ?, ?, ?)
+		RETURNING id`,
+		refund.ReceiptID, refund.Amount.Amount, refund.PointsReversed, refund.Reason, refund.CreatedAt)
+	if err != nil {
+		return err
+	}
+	refund.ID = id
+
+	for _, line := range refund.Items {
+		_, err := r.q.Exec(`
+			INSERT INTO receipt_refund_items (refund_id, receipt_item_id, quantity) VALUES (?, ?, ?)`,
+			refund.ID, line.ItemID, line.Quantity)
+		if err != nil {
+			return err
+		}
+
+		// The guard keeps concurrent refunds from giving back more than was bought
+		result, err := r.q.Exec(`
+			UPDATE receipt_items SET refunded_quantity = refunded_quantity + ?
+			WHERE id = ? AND receipt_id = ? AND refunded_quantity + ? <= quantity`,
+			line.Quantity, line.ItemID, refund.ReceiptID, line.Quantity)
+		if err != nil {
+			return err
+		}
+		if rows, err := result.RowsAffected(); err != nil {
+			return err
+		} else if rows == 0 {
+			return ErrOverRefund
+		}
+	}
+
+	_, err = r.q.Exec(`
+		UPDATE receipts SET status = ?, refunded_minor = refunded_minor + ?, points_reversed = points_reversed + ?
+		WHERE id = ?`,
+		status, refund.Amount.Amount, refund.PointsReversed, refund.ReceiptID)
+	return err
+}
+
+func (r *receiptRepository) ListRefunds(receiptID int) ([]models.ReceiptRefund, error) {
+	rows, err := r.q.Query(`
+		SELECT f.id, f.receipt_id, f.amount_minor, r.currency, f.points_reversed, f.reason, f.created_at
+		FROM receipt_refunds f JOIN receipts r ON r.id = f.receipt_id
+		WHERE f.receipt_id = ? ORDER BY f.id`,
+		receiptID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var refunds []models.ReceiptRefund
+	byID := map[int]int{}
+	for rows.Next() {
+		var refund models.ReceiptRefund
+		err := rows.Scan(&refund.ID, &refund.ReceiptID, &refund.Amount.Amount, &refund.Amount.Currency,
+			&refund.PointsReversed, &refund.Reason, &refund.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		refund.Items = []models.RefundLine{}
+		byID[refund.ID] = len(refunds)
+		refunds = append(refunds, refund)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	lines, err := r.q.Query(`
+		SELECT i.refund_id, i.receipt_item_id, i.quantity
+		FROM receipt_refund_items i JOIN receipt_refunds f ON f.id = i.refund_id
+		WHERE f.receipt_id = ? ORDER BY i.refund_id, i.receipt_item_id`,
+		receiptID)
+	if err != nil {
+		return nil, err
+	}
+	defer lines.Close()
+
+	for lines.Next() {
+		var refundID int
+		var line models.RefundLine
+		if err := lines.Scan(&refundID, &line.ItemID, &line.Quantity); err != nil {
+			return nil, err
+		}
+		if i, ok := byID[refundID]; ok {
+			refunds[i].Items = append(refunds[i].Items, line)
+		}
+	}
+
+	return refunds, lines.Err()
+}
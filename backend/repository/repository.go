@@ -0,0 +1,204 @@
+// Package repository holds the persistence layer. Services talk to the
+// repository interfaces below, never to SQL, so the storage backend can be
+// chosen in the configuration.
+//
+// Lookups of a single row return sql.ErrNoRows when nothing matches.
+package repository
+
+import (
+	"ecotracker-backend/models"
+	"errors"
+	"time"
+)
+
+// ErrOverRefund is returned by ReceiptRepository.AddRefund when a refund
+// exceeds the quantity left to refund.
+var ErrOverRefund = errors.New("refund exceeds the quantity bought")
+
+type UserRepository interface {
+	// EmailExists reports whether a user registered with email.
+	EmailExists(email string) (bool, error)
+	// Create inserts user, setting its ID and timestamps.
+	Create(user *models.User) error
+	GetByID(id int) (*models.User, error)
+	GetByEmail(email string) (*models.User, error)
+	// FindByEmailOrPhone returns the user whose email or phone is identifier.
+	FindByEmailOrPhone(identifier string) (*models.User, error)
+	// Update changes the name and phone of a user; nil values are kept.
+	Update(id int, name, phone *string) error
+	// SetPassword replaces the password hash, unless it no longer equals
+	// oldHash because another request changed it first.
+	SetPassword(id int, oldHash, newHash string) error
+	SetRoleByEmail(email, role string) error
+}
+
+type ShopRepository interface {
+	// EmailExists reports whether a shop registered with email.
+	EmailExists(email string) (bool, error)
+	// Create inserts shop, setting its ID and timestamps.
+	Create(shop *models.Shop) error
+	GetByID(id int) (*models.Shop, error)
+	GetByEmail(email string) (*models.Shop, error)
+	// SetPassword replaces the password hash, unless it no longer equals
+	// oldHash because another request changed it first.
+	SetPassword(id int, oldHash, newHash string) error
+	// AddItem inserts item into the catalogue of item.ShopID and sets its ID
+	// and timestamps.
+	AddItem(item *models.ShopItem) error
+	GetItemByID(id int) (*models.ShopItem, error)
+	GetItem(shopID, id int) (*models.ShopItem, error)
+	// ItemBySKU returns the shop's item with the SKU that is not archived.
+	ItemBySKU(shopID int, sku string) (*models.ShopItem, error)
+	// ItemByBarcode returns the shop's item with the barcode, in its EAN-13
+	// form, that is not archived.
+	ItemByBarcode(shopID int, barcode string) (*models.ShopItem, error)
+	// ListItems returns the shop's catalogue in ID order, including archived
+	// items when includeArchived is true.
+	ListItems(shopID int, includeArchived bool) ([]models.ShopItem, error)
+	// UpdateItem saves the item's fields and sets its update time.
+	UpdateItem(item *models.ShopItem) error
+	ArchiveItem(id int, archivedAt time.Time) error
+	// AddItemPrice records a price of an item and sets its ID; ItemPrices
+	// returns them oldest first.
+	AddItemPrice(price *models.ShopItemPrice) error
+	ItemPrices(itemID int) ([]models.ShopItemPrice, error)
+	// ItemsByID returns the items of the shop's catalogue among ids, by ID,
+	// archived ones included. IDs of other shops' items are left out.
+	ItemsByID(shopID int, ids []int) (map[int]models.ShopItem, error)
+	// ItemsByBarcode returns the shop's items that are not archived among
+	// barcodes, by barcode.
+	ItemsByBarcode(shopID int, barcodes []string) (map[string]models.ShopItem, error)
+}
+
+type ReceiptRepository interface {
+	// Create inserts the receipt and its items, setting their IDs and the
+	// receipt's creation time.
+	Create(receipt *models.Receipt) error
+	GetByID(id int) (*models.Receipt, error)
+	// ListByUser and ListByShop return receipts newest first, without
+	// their items.
+	ListByUser(userID int) ([]models.Receipt, error)
+	ListByShop(shopID int) ([]models.Receipt, error)
+	// IsCustomer reports whether shopID has issued a receipt to userID.
+	IsCustomer(shopID, userID int) (bool, error)
+	// LoadItems fills in the Items of every receipt, querying them for a
+	// few hundred receipts at a time.
+	LoadItems(receipts []models.Receipt) error
+	// AddRefund records refund with its lines, setting its ID, adds them to
+	// the refunded quantities and totals of the receipt and sets its status.
+	// It returns ErrOverRefund when a line would refund more than was
+	// bought.
+	AddRefund(refund *models.ReceiptRefund, status string) error
+	// ListRefunds returns the refunds of a receipt, oldest first.
+	ListRefunds(receiptID int) ([]models.ReceiptRefund, error)
+}
+
+type SessionRepository interface {
+	// Create inserts session, identified by the hash of its refresh token,
+	// and sets its ID.
+	Create(session *models.Session, tokenHash string) error
+	FindByTokenHash(tokenHash string) (*models.Session, error)
+	GetByID(id int) (*models.Session, error)
+	// Rotate replaces the token hash and expiry of a session. It reports
+	// false when the session no longer has oldHash.
+	Rotate(id int, oldHash, newHash string, expiresAt time.Time) (bool, error)
+	Revoke(id int, revokedAt time.Time) error
+}
+
+type ChallengeRepository interface {
+	// Create inserts challenge, setting its ID and timestamps.
+	Create(challenge *models.Challenge) error
+	// Update replaces the definition of challenge.
+	Update(challenge *models.Challenge) error
+	GetByID(id int) (*models.Challenge, error)
+	// List returns challenges in ID order, including retired ones when
+	// includeRetired is true.
+	List(includeRetired bool) ([]models.Challenge, error)
+	Retire(id int, retiredAt time.Time) error
+	// Progress measures the user's purchases against rule.
+	Progress(userID int, rule models.ChallengeRule) (int, error)
+	// Completions returns when the user completed each challenge, by ID.
+	Completions(userID int) (map[int]time.Time, error)
+	// RecordCompletion records that the user completed the challenge, with
+	// the receipt that completed it (0 for none) and the bonus paid. It
+	// reports false when the completion was already recorded.
+	RecordCompletion(userID, challengeID, receiptID, pointsAwarded int, completedAt time.Time) (bool, error)
+}
+
+type PointsRepository interface {
+	// Append adds entry to the ledger, setting its ID, and applies its
+	// amount to the user's stored balance. Entries are never changed or
+	// removed once appended.
+	Append(entry *models.PointsEntry) error
+	// Spend appends entry, whose amount is negative, like Append, but only
+	// when the user's balance covers it. It reports false when it does not.
+	Spend(entry *models.PointsEntry) (bool, error)
+	// Earned sums the points the user earned from purchases since the given
+	// time, before challenge bonuses.
+	Earned(userID int, since time.Time) (int, error)
+	// History returns the user's ledger entries, newest first.
+	History(userID int) ([]models.PointsEntry, error)
+	// Drift returns the users whose stored balance differs from the sum of
+	// their ledger entries.
+	Drift() ([]models.PointsDrift, error)
+	// Reconcile sets the user's stored balance to the sum of their ledger.
+	Reconcile(userID int) error
+}
+
+type RewardRepository interface {
+	// Create inserts reward, setting its ID and timestamps.
+	Create(reward *models.Reward) error
+	// Update replaces the definition of reward.
+	Update(reward *models.Reward) error
+	GetByID(id int) (*models.Reward, error)
+	// List returns rewards in ID order: those valid at shopID, or all of
+	// them when shopID is 0. Inactive rewards are included when
+	// includeInactive is true.
+	List(shopID int, includeInactive bool) ([]models.Reward, error)
+	// TakeStock takes one unit of an active reward out of stock. It reports
+	// false when the reward is inactive or out of stock.
+	TakeStock(id int) (bool, error)
+	// CreateRedemption inserts redemption, setting its ID and creation time.
+	CreateRedemption(redemption *models.Redemption) error
+	GetRedemptionByCode(code string) (*models.Redemption, error)
+	// ListRedemptions returns the user's redemptions, newest first.
+	ListRedemptions(userID int) ([]models.Redemption, error)
+	// ConsumeRedemption marks an issued redemption consumed by shopID (0 for
+	// none). It reports false when the redemption was already consumed.
+	ConsumeRedemption(id, shopID int, consumedAt time.Time) (bool, error)
+}
+
+// SettingsRepository stores named settings changed at runtime, as text.
+type SettingsRepository interface {
+	Get(key string) (string, error)
+	// Put creates or replaces the setting.
+	Put(key, value string) error
+	Delete(key string) error
+}
+
+type IdempotencyRepository interface {
+	// Claim stores record unless its key is already stored, and reports
+	// whether it did. Expired keys are removed first, so they can be reused.
+	Claim(record *models.IdempotencyRecord) (bool, error)
+	Get(key models.IdempotencyKey) (*models.IdempotencyRecord, error)
+	// Complete saves the response to the request that claimed key.
+	Complete(key models.IdempotencyKey, response string) error
+}
+
+// Store gives access to every repository.
+type Store interface {
+	Users() UserRepository
+	Shops() ShopRepository
+	Receipts() ReceiptRepository
+	Sessions() SessionRepository
+	Challenges() ChallengeRepository
+	Points() PointsRepository
+	Rewards() RewardRepository
+	Settings() SettingsRepository
+	Idempotency() IdempotencyRepository
+
+	// WithTx runs fn with a Store whose repositories share one transaction.
+	// The transaction is committed when fn returns nil and rolled back
+	// otherwise. Calling WithTx inside fn joins the outer transaction.
+	WithTx(fn func(Store) error) error
+}
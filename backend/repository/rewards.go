@@ -0,0 +1,192 @@
+package repository
+
+import (
+	"database/sql"
+	"ecotracker-backend/models"
+	"time"
+)
+
+type rewardRepository struct {
+	q querier
+}
+
+const rewardColumns = `id, shop_id, name, description, points_cost, stock, active, created_at, updated_at`
+
+func scanReward(row scanner) (*models.Reward, error) {
+	reward := &models.Reward{}
+	var shopID, stock sql.NullInt64
+	err := row.Scan(&reward.ID, &shopID, &reward.Name, &reward.Description, &reward.PointsCost,
+		&stock, &reward.Active, &reward.CreatedAt, &reward.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	reward.ShopID = int(shopID.Int64)
+	if stock.Valid {
+		n := int(stock.Int64)
+		reward.Stock = &n
+	}
+	return reward, nil
+}
+
+// nullID stores the ID 0 as NULL.
+func nullID(id int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(id), Valid: id != 0}
+}
+
+func (r *rewardRepository) Create(reward *models.Reward) error {
+	now := time.Now()
+
+	id, err := r.q.insert(`
+		INSERT INTO rewards (shop_id, name, description, points_cost, stock, active, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id`,
+		nullID(reward.ShopID), reward.Name, reward.Description, reward.PointsCost, reward.Stock,
+		reward.Active, now, now)
+	if err != nil {
+		return err
+	}
+
+	reward.ID = id
+	reward.CreatedAt = now
+	reward.UpdatedAt = now
+	return nil
+}
+
+func (r *rewardRepository) Update(reward *models.Reward) error {
+	reward.UpdatedAt = time.Now()
+
+	_, err := r.q.Exec(`
+		UPDATE rewards SET shop_id = ?, name = ?, description = ?, points_cost = ?, stock = ?,
+			active = ?, updated_at = ?
+		WHERE id = ?`,
+		nullID(reward.ShopID), reward.Name, reward.Description, reward.PointsCost, reward.Stock,
+		reward.Active, reward.UpdatedAt, reward.ID)
+	return err
+}
+
+func (r *rewardRepository) GetByID(id int) (*models.Reward, error) {
+	return scanReward(r.q.QueryRow(`SELECT `+rewardColumns+` FROM rewards WHERE id = ?`, id))
+}
+
+func (r *rewardRepository) List(shopID int, includeInactive bool) ([]models.Reward, error) {
+	query := `SELECT ` + rewardColumns + ` FROM rewards WHERE 1 = 1`
+	args := []interface{}{}
+
+	if shopID != 0 {
+		query += ` AND (shop_id = ? OR shop_id IS NULL)`
+		args = append(args, shopID)
+	}
+	if !includeInactive {
+		query += ` AND active = TRUE`
+	}
+
+	rows, err := r.q.Query(query+` ORDER BY id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rewards := []models.Reward{}
+	for rows.Next() {
+		reward, err := scanReward(rows)
+		if err != nil {
+			return nil, err
+		}
+		rewards = append(rewards, *reward)
+	}
+
+	return rewards, rows.Err()
+}
+
+func (r *rewardRepository) TakeStock(id int) (bool, error) {
+	result, err := r.q.Exec(`
+		UPDATE rewards SET stock = stock - 1
+		WHERE id = ? AND active = TRUE AND (stock IS NULL OR stock > 0)`,
+		id)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+const redemptionColumns = `d.id, d.reward_id, w.name, w.shop_id, d.user_id, d.code, d.points_spent,
+	d.status, d.created_at, d.consumed_at, d.consumed_by_shop_id`
+
+const redemptionFrom = ` FROM redemptions d JOIN rewards w ON w.id = d.reward_id`
+
+func scanRedemption(row scanner) (*models.Redemption, error) {
+	redemption := &models.Redemption{}
+	var shopID, consumedBy sql.NullInt64
+	var consumedAt sql.NullTime
+	err := row.Scan(&redemption.ID, &redemption.RewardID, &redemption.RewardName, &shopID,
+		&redemption.UserID, &redemption.Code, &redemption.PointsSpent, &redemption.Status,
+		&redemption.CreatedAt, &consumedAt, &consumedBy)
+	if err != nil {
+		return nil, err
+	}
+
+	redemption.ShopID = int(shopID.Int64)
+	redemption.ConsumedByShopID = int(consumedBy.Int64)
+	if consumedAt.Valid {
+		redemption.ConsumedAt = &consumedAt.Time
+	}
+	return redemption, nil
+}
+
+func (r *rewardRepository) CreateRedemption(redemption *models.Redemption) error {
+	redemption.CreatedAt = time.Now()
+
+	id, err := r.q.insert(`
+		INSERT INTO redemptions (reward_id, user_id, code, points_spent, status, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		RETURNING id`,
+		redemption.RewardID, redemption.UserID, redemption.Code, redemption.PointsSpent,
+		redemption.Status, redemption.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	redemption.ID = id
+	return nil
+}
+
+func (r *rewardRepository) GetRedemptionByCode(code string) (*models.Redemption, error) {
+	return scanRedemption(r.q.QueryRow(`SELECT `+redemptionColumns+redemptionFrom+` WHERE d.code = ?`, code))
+}
+
+func (r *rewardRepository) ListRedemptions(userID int) ([]models.Redemption, error) {
+	rows, err := r.q.Query(`SELECT `+redemptionColumns+redemptionFrom+`
+		WHERE d.user_id = ? ORDER BY d.id DESC`,
+		userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	redemptions := []models.Redemption{}
+	for rows.Next() {
+		redemption, err := scanRedemption(rows)
+		if err != nil {
+			return nil, err
+		}
+		redemptions = append(redemptions, *redemption)
+	}
+
+	return redemptions, rows.Err()
+}
+
+func (r *rewardRepository) ConsumeRedemption(id, shopID int, consumedAt time.Time) (bool, error) {
+	result, err := r.q.Exec(`
+		UPDATE redemptions SET status = ?, consumed_at = ?, consumed_by_shop_id = ?
+		WHERE id = ? AND status = ?`,
+		models.RedemptionConsumed, consumedAt, nullID(shopID), id, models.RedemptionIssued)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
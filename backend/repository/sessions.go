@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"database/sql"
+	"ecotracker-backend/models"
+	"time"
+)
+
+type sessionRepository struct {
+	q querier
+}
+
+const sessionColumns = `id, principal_kind, principal_id, expires_at, revoked_at, created_at`
+
+func scanSession(row scanner) (*models.Session, error) {
+	session := &models.Session{}
+	var revokedAt sql.NullTime
+	err := row.Scan(&session.ID, &session.PrincipalKind, &session.PrincipalID,
+		&session.ExpiresAt, &revokedAt, &session.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if revokedAt.Valid {
+		session.RevokedAt = &revokedAt.Time
+	}
+	return session, nil
+}
+
+func (r *sessionRepository) Create(session *models.Session, tokenHash string) error {
+	if session.CreatedAt.IsZero() {
+		session.CreatedAt = time.Now().UTC()
+	}
+
+	id, err := r.q.insert(`
+		INSERT INTO sessions (principal_kind, principal_id, refresh_token_hash, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?)
+		RETURNING id`,
+		session.PrincipalKind, session.PrincipalID, tokenHash, session.ExpiresAt, session.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	session.ID = id
+	return nil
+}
+
+func (r *sessionRepository) FindByTokenHash(tokenHash string) (*models.Session, error) {
+	return scanSession(r.q.QueryRow(`SELECT `+sessionColumns+` FROM sessions WHERE refresh_token_hash = ?`, tokenHash))
+}
+
+func (r *sessionRepository) GetByID(id int) (*models.Session, error) {
+	return scanSession(r.q.QueryRow(`SELECT `+sessionColumns+` FROM sessions WHERE id = ?`, id))
+}
+
+func (r *sessionRepository) Rotate(id int, oldHash, newHash string, expiresAt time.Time) (bool, error) {
+	result, err := r.q.Exec(`
+		UPDATE sessions SET refresh_token_hash = ?, expires_at = ?
+		WHERE id = ? AND refresh_token_hash = ?`,
+		newHash, expiresAt, id, oldHash)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+func (r *sessionRepository) Revoke(id int, revokedAt time.Time) error {
+	_, err := r.q.Exec(`UPDATE sessions SET revoked_at = ? WHERE id = ?`, revokedAt, id)
+	return err
+}
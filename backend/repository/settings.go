@@ -0,0 +1,26 @@
+package repository
+
+import "time"
+
+type settingsRepository struct {
+	q querier
+}
+
+func (r *settingsRepository) Get(key string) (string, error) {
+	var value string
+	err := r.q.QueryRow(`SELECT value FROM settings WHERE key = ?`, key).Scan(&value)
+	return value, err
+}
+
+func (r *settingsRepository) Put(key, value string) error {
+	_, err := r.q.Exec(`
+		INSERT INTO settings (key, value, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT (key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at`,
+		key, value, time.Now())
+	return err
+}
+
+func (r *settingsRepository) Delete(key string) error {
+	_, err := r.q.Exec(`DELETE FROM settings WHERE key = ?`, key)
+	return err
+}
@@ -0,0 +1,272 @@
+package repository
+
+import (
+	"database/sql"
+	"ecotracker-backend/models"
+	"time"
+)
+
+type shopRepository struct {
+	q querier
+}
+
+const shopColumns = `id, email, password, name, address, phone, description, created_at, updated_at`
+
+func scanShop(row scanner) (*models.Shop, error) {
+	shop := &models.Shop{}
+	err := row.Scan(&shop.ID, &shop.Email, &shop.Password, &shop.Name, &shop.Address,
+		&shop.Phone, &shop.Description, &shop.CreatedAt, &shop.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return shop, nil
+}
+
+func (r *shopRepository) EmailExists(email string) (bool, error) {
+	return r.q.exists(`SELECT COUNT(*) FROM shops WHERE email = ?`, email)
+}
+
+func (r *shopRepository) Create(shop *models.Shop) error {
+	now := time.Now()
+
+	id, err := r.q.insert(`
+		INSERT INTO shops (email, password, name, address, phone, description, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id`,
+		shop.Email, shop.Password, shop.Name, shop.Address, shop.Phone, shop.Description, now, now)
+	if err != nil {
+		return err
+	}
+
+	shop.ID = id
+	shop.CreatedAt = now
+	shop.UpdatedAt = now
+	return nil
+}
+
+func (r *shopRepository) GetByID(id int) (*models.Shop, error) {
+	return scanShop(r.q.QueryRow(`SELECT `+shopColumns+` FROM shops WHERE id = ?`, id))
+}
+
+func (r *shopRepository) GetByEmail(email string) (*models.Shop, error) {
+	return scanShop(r.q.QueryRow(`SELECT `+shopColumns+` FROM shops WHERE email = ?`, email))
+}
+
+func (r *shopRepository) SetPassword(id int, oldHash, newHash string) error {
+	_, err := r.q.Exec(`UPDATE shops SET password = ? WHERE id = ? AND password = ?`,
+		newHash, id, oldHash)
+	return err
+}
+
+func (r *shopRepository) AddItem(item *models.ShopItem) error {
+	now := time.Now()
+
+	id, err := r.q.insert(`
+		INSERT INTO shop_items (shop_id, sku, barcode, name, price_minor, currency, category, description,
+			is_eco_friendly, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id`,
+		item.ShopID, nullString(item.SKU), nullString(item.Barcode), item.Name, item.Price.Amount, item.Currency, item.Category, item.Description, item.IsEcoFriendly,
+		now, now)
+	if err != nil {
+		return err
+	}
+
+	item.ID = id
+	item.CreatedAt = now
+	item.UpdatedAt = now
+	return nil
+}
+
+const shopItemColumns = `id, shop_id, sku, barcode, name, price_minor, currency, category, description, is_eco_friendly,
+	archived_at, created_at, updated_at`
+
+func scanShopItem(row scanner) (*models.ShopItem, error) {
+	item := &models.ShopItem{}
+	var sku, barcode sql.NullString
+	var archivedAt sql.NullTime
+	err := row.Scan(&item.ID, &item.ShopID, &sku, &barcode, &item.Name, &item.Price.Amount, &item.Currency,
+		&item.Category, &item.Description, &item.IsEcoFriendly,
+		&archivedAt, &item.CreatedAt, &item.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	item.SKU = sku.String
+	item.Barcode = barcode.String
+	item.Price.Currency = item.Currency
+	if archivedAt.Valid {
+		item.ArchivedAt = &archivedAt.Time
+	}
+	return item, nil
+}
+
+func (r *shopRepository) GetItemByID(id int) (*models.ShopItem, error) {
+	return scanShopItem(r.q.QueryRow(`SELECT `+shopItemColumns+` FROM shop_items WHERE id = ?`, id))
+}
+
+func (r *shopRepository) GetItem(shopID, id int) (*models.ShopItem, error) {
+	return scanShopItem(r.q.QueryRow(`SELECT `+shopItemColumns+` FROM shop_items WHERE shop_id = ? AND id = ?`,
+		shopID, id))
+}
+
+// nullString stores the empty string as NULL, which unique indexes allow
+// any number of times.
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+func (r *shopRepository) ItemBySKU(shopID int, sku string) (*models.ShopItem, error) {
+	return scanShopItem(r.q.QueryRow(`
+		SELECT `+shopItemColumns+` FROM shop_items
+		WHERE shop_id = ? AND sku = ? AND archived_at IS NULL`,
+		shopID, sku))
+}
+
+func (r *shopRepository) ItemByBarcode(shopID int, barcode string) (*models.ShopItem, error) {
+	return scanShopItem(r.q.QueryRow(`
+		SELECT `+shopItemColumns+` FROM shop_items
+		WHERE shop_id = ? AND barcode = ? AND archived_at IS NULL`,
+		shopID, barcode))
+}
+
+func (r *shopRepository) ListItems(shopID int, includeArchived bool) ([]models.ShopItem, error) {
+	query := `SELECT ` + shopItemColumns + ` FROM shop_items WHERE shop_id = ?`
+	if !includeArchived {
+		query += ` AND archived_at IS NULL`
+	}
+
+	rows, err := r.q.Query(query+` ORDER BY id`, shopID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []models.ShopItem
+	for rows.Next() {
+		item, err := scanShopItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, *item)
+	}
+
+	return items, rows.Err()
+}
+
+func (r *shopRepository) UpdateItem(item *models.ShopItem) error {
+	item.UpdatedAt = time.Now()
+
+	_, err := r.q.Exec(`
+		UPDATE shop_items SET sku = ?, barcode = ?, name = ?, price_minor = ?, currency = ?, category = ?,
+			description = ?, is_eco_friendly = ?, updated_at = ?
+		WHERE id = ?`,
+		nullString(item.SKU), nullString(item.Barcode), item.Name, item.Price.Amount, item.Currency, item.Category, item.Description,
+		item.IsEcoFriendly, item.UpdatedAt, item.ID)
+	return err
+}
+
+func (r *shopRepository) ArchiveItem(id int, archivedAt time.Time) error {
+	_, err := r.q.Exec(`UPDATE shop_items SET archived_at = ?, updated_at = ? WHERE id = ? AND archived_at IS NULL`,
+		archivedAt, archivedAt, id)
+	return err
+}
+
+func (r *shopRepository) AddItemPrice(price *models.ShopItemPrice) error {
+	id, err := r.q.insert(`
+		INSERT INTO shop_item_prices (item_id, price_minor, currency, effective_from)
+		VALUES (?, ?, ?, ?)
+		RETURNING id`,
+		price.ItemID, price.Price.Amount, price.Price.Currency, price.EffectiveFrom)
+	if err != nil {
+		return err
+	}
+
+	price.ID = id
+	return nil
+}
+
+func (r *shopRepository) ItemPrices(itemID int) ([]models.ShopItemPrice, error) {
+	rows, err := r.q.Query(`
+		SELECT id, item_id, price_minor, currency, effective_from
+		FROM shop_item_prices WHERE item_id = ? ORDER BY effective_from, id`,
+		itemID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var prices []models.ShopItemPrice
+	for rows.Next() {
+		var price models.ShopItemPrice
+		err := rows.Scan(&price.ID, &price.ItemID, &price.Price.Amount, &price.Price.Currency, &price.EffectiveFrom)
+		if err != nil {
+			return nil, err
+		}
+		price.Currency = price.Price.Currency
+		prices = append(prices, price)
+	}
+
+	return prices, rows.Err()
+}
+
+func (r *shopRepository) ItemsByID(shopID int, ids []int) (map[int]models.ShopItem, error) {
+	items := make(map[int]models.ShopItem, len(ids))
+	if len(ids) == 0 {
+		return items, nil
+	}
+
+	args := []interface{}{shopID}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+
+	rows, err := r.q.Query(`
+		SELECT `+shopItemColumns+` FROM shop_items
+		WHERE shop_id = ? AND id IN (`+placeholders(len(ids))+`)`,
+		args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		item, err := scanShopItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items[item.ID] = *item
+	}
+
+	return items, rows.Err()
+}
+
+func (r *shopRepository) ItemsByBarcode(shopID int, barcodes []string) (map[string]models.ShopItem, error) {
+	items := make(map[string]models.ShopItem, len(barcodes))
+	if len(barcodes) == 0 {
+		return items, nil
+	}
+
+	args := []interface{}{shopID}
+	for _, barcode := range barcodes {
+		args = append(args, barcode)
+	}
+
+	rows, err := r.q.Query(`
+		SELECT `+shopItemColumns+` FROM shop_items
+		WHERE shop_id = ? AND archived_at IS NULL AND barcode IN (`+placeholders(len(barcodes))+`)`,
+		args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		item, err := scanShopItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items[item.Barcode] = *item
+	}
+
+	return items, rows.Err()
+}
@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"database/sql"
+	"ecotracker-backend/database"
+	"strings"
+)
+
+// sqlStore implements Store on database/sql. The same queries serve SQLite
+// and PostgreSQL: they are written with ? placeholders, which the database
+// rebinds for its driver, and read generated IDs with RETURNING, which both
+// support.
+type sqlStore struct {
+	db *database.Database
+	q  querier
+}
+
+// New returns the Store for db, using the driver db was opened with.
+func New(db *database.Database) Store {
+	return &sqlStore{db: db, q: querier{db: db, q: db.DB}}
+}
+
+func (s *sqlStore) Users() UserRepository {
+	return &userRepository{q: s.q}
+}
+
+func (s *sqlStore) Shops() ShopRepository {
+	return &shopRepository{q: s.q}
+}
+
+func (s *sqlStore) Receipts() ReceiptRepository {
+	return &receiptRepository{q: s.q}
+}
+
+func (s *sqlStore) Sessions() SessionRepository {
+	return &sessionRepository{q: s.q}
+}
+
+func (s *sqlStore) Challenges() ChallengeRepository {
+	return &challengeRepository{q: s.q}
+}
+
+func (s *sqlStore) Points() PointsRepository {
+	return &pointsRepository{q: s.q}
+}
+
+func (s *sqlStore) Rewards() RewardRepository {
+	return &rewardRepository{q: s.q}
+}
+
+func (s *sqlStore) Settings() SettingsRepository {
+	return &settingsRepository{q: s.q}
+}
+
+func (s *sqlStore) Idempotency() IdempotencyRepository {
+	return &idempotencyRepository{q: s.q}
+}
+
+func (s *sqlStore) WithTx(fn func(Store) error) error {
+	if _, inTx := s.q.q.(*sql.Tx); inTx {
+		return fn(s)
+	}
+
+	return s.db.WithTx(func(tx *sql.Tx) error {
+		return fn(&sqlStore{db: s.db, q: querier{db: s.db, q: tx}})
+	})
+}
+
+// querier runs queries on a connection or transaction after rebinding their
+// placeholders for the driver.
+type querier struct {
+	db *database.Database
+	q  database.Querier
+}
+
+func (q querier) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return q.q.Exec(q.db.Rebind(query), args...)
+}
+
+func (q querier) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return q.q.Query(q.db.Rebind(query), args...)
+}
+
+func (q querier) QueryRow(query string, args ...interface{}) *sql.Row {
+	return q.q.QueryRow(q.db.Rebind(query), args...)
+}
+
+// insert runs an INSERT ending in RETURNING id and returns the new ID.
+func (q querier) insert(query string, args ...interface{}) (int, error) {
+	var id int
+	err := q.QueryRow(query, args...).Scan(&id)
+	return id, err
+}
+
+// exists reports whether query, a SELECT COUNT(*), counts any rows.
+func (q querier) exists(query string, args ...interface{}) (bool, error) {
+	var count int
+	if err := q.QueryRow(query, args...).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// maxInList bounds the values bound to one IN list, keeping statements
+// under the 999 parameters older SQLite builds allow.
+const maxInList = 500
+
+// placeholders returns n comma-separated ? placeholders for an IN list.
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"ecotracker-backend/auth"
+	"ecotracker-backend/models"
+	"strings"
+	"time"
+)
+
+type userRepository struct {
+	q querier
+}
+
+const userColumns = `id, email, password, name, phone, points, role, created_at, updated_at`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanUser(row scanner) (*models.User, error) {
+	user := &models.User{}
+	err := row.Scan(&user.ID, &user.Email, &user.Password, &user.Name, &user.Phone,
+		&user.Points, &user.Role, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (r *userRepository) EmailExists(email string) (bool, error) {
+	return r.q.exists(`SELECT COUNT(*) FROM users WHERE email = ?`, email)
+}
+
+func (r *userRepository) Create(user *models.User) error {
+	now := time.Now()
+	if user.Role == "" {
+		user.Role = auth.RoleCustomer
+	}
+
+	id, err := r.q.insert(`
+		INSERT INTO users (email, password, name, phone, points, role, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id`,
+		user.Email, user.Password, user.Name, user.Phone, user.Points, user.Role, now, now)
+	if err != nil {
+		return err
+	}
+
+	user.ID = id
+	user.CreatedAt = now
+	user.UpdatedAt = now
+	return nil
+}
+
+func (r *userRepository) GetByID(id int) (*models.User, error) {
+	return scanUser(r.q.QueryRow(`SELECT `+userColumns+` FROM users WHERE id = ?`, id))
+}
+
+func (r *userRepository) GetByEmail(email string) (*models.User, error) {
+	return scanUser(r.q.QueryRow(`SELECT `+userColumns+` FROM users WHERE email = ?`, email))
+}
+
+func (r *userRepository) FindByEmailOrPhone(identifier string) (*models.User, error) {
+	return scanUser(r.q.QueryRow(`
+		SELECT `+userColumns+` FROM users WHERE email = ? OR phone = ?
+		ORDER BY id LIMIT 1`,
+		identifier, identifier))
+}
+
+func (r *userRepository) Update(id int, name, phone *string) error {
+	setParts := []string{}
+	args := []interface{}{}
+
+	if name != nil {
+		setParts = append(setParts, "name = ?")
+		args = append(args, *name)
+	}
+	if phone != nil {
+		setParts = append(setParts, "phone = ?")
+		args = append(args, *phone)
+	}
+
+	setParts = append(setParts, "updated_at = ?")
+	args = append(args, time.Now(), id)
+
+	_, err := r.q.Exec("UPDATE users SET "+strings.Join(setParts, ", ")+" WHERE id = ?", args...)
+	return err
+}
+
+func (r *userRepository) SetPassword(id int, oldHash, newHash string) error {
+	_, err := r.q.Exec(`UPDATE users SET password = ? WHERE id = ? AND password = ?`,
+		newHash, id, oldHash)
+	return err
+}
+
+func (r *userRepository) SetRoleByEmail(email, role string) error {
+	_, err := r.q.Exec(`UPDATE users SET role = ? WHERE email = ?`, role, email)
+	return err
+}
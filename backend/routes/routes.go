@@ -0,0 +1,148 @@
+package routes
+
+import (
+	"ecotracker-backend/apierror"
+	"ecotracker-backend/auth"
+	"ecotracker-backend/handlers"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// Route is one entry of the API route table. Pattern uses the net/http
+// ServeMux syntax, so path parameters are written as {name}.
+type Route struct {
+	Method  string
+	Pattern string
+	Public  bool
+	Handler http.HandlerFunc
+}
+
+type Handlers struct {
+	User      *handlers.UserHandler
+	Shop      *handlers.ShopHandler
+	Receipt   *handlers.ReceiptHandler
+	Auth      *handlers.AuthHandler
+	Challenge *handlers.ChallengeHandler
+	Points    *handlers.PointsHandler
+	Reward    *handlers.RewardHandler
+}
+
+// Table returns every API route. New endpoints only need a line here.
+func Table(h Handlers) []Route {
+	return []Route{
+		{"GET", "/health", true, health},
+
+		// Sessions
+		{"POST", "/api/auth/refresh", true, h.Auth.Refresh},
+		{"POST", "/api/auth/logout", false, h.Auth.Logout},
+
+		// Users
+		{"POST", "/api/users/register", true, h.User.Register},
+		{"POST", "/api/users/login", true, h.User.Login},
+		{"POST", "/api/users/validate", false, h.User.ValidateCustomer},
+		{"POST", "/api/redemptions/verify", false, h.Reward.VerifyRedemption},
+		{"POST", "/api/redemptions/consume", false, h.Reward.ConsumeRedemption},
+		{"GET", "/api/users/{id}", false, handlers.WithID("id", h.User.GetUser)},
+		{"PUT", "/api/users/{id}/points", false, handlers.WithID("id", h.User.UpdateUserPoints)},
+		{"GET", "/api/users/{id}/receipts", false, handlers.WithID("id", h.Receipt.GetUserReceipts)},
+		{"GET", "/api/users/{id}/points/history", false, handlers.WithID("id", h.Points.GetHistory)},
+		{"GET", "/api/users/{id}/challenges", false, handlers.WithID("id", h.Challenge.GetUserChallenges)},
+		{"GET", "/api/users/{id}/redemptions", false, handlers.WithID("id", h.Reward.GetUserRedemptions)},
+
+		// Shops
+		{"POST", "/api/shops/register", true, h.Shop.Register},
+		{"POST", "/api/shops/login", true, h.Shop.Login},
+		{"GET", "/api/shops/{id}", false, handlers.WithID("id", h.Shop.GetShop)},
+		{"GET", "/api/shops/{id}/items", false, handlers.WithID("id", h.Shop.GetItems)},
+		{"POST", "/api/shops/{id}/items", false, handlers.WithID("id", h.Shop.AddItem)},
+		{"POST", "/api/shops/{id}/items/import", false, handlers.WithID("id", h.Shop.ImportItems)},
+		{"GET", "/api/shops/{id}/items/export", false, handlers.WithID("id", h.Shop.ExportItems)},
+		{"GET", "/api/shops/{id}/items/by-barcode/{code}", false, handlers.WithID("id", h.Shop.GetItemByBarcode)},
+		{"GET", "/api/shops/{id}/items/{item_id}", false, handlers.WithIDs("id", "item_id", h.Shop.GetItem)},
+		{"PUT", "/api/shops/{id}/items/{item_id}", false, handlers.WithIDs("id", "item_id", h.Shop.UpdateItem)},
+		{"PATCH", "/api/shops/{id}/items/{item_id}", false, handlers.WithIDs("id", "item_id", h.Shop.PatchItem)},
+		{"DELETE", "/api/shops/{id}/items/{item_id}", false, handlers.WithIDs("id", "item_id", h.Shop.ArchiveItem)},
+		{"GET", "/api/items/{id}/prices", false, handlers.WithID("id", h.Shop.GetItemPrices)},
+		{"GET", "/api/shops/{id}/receipts", false, handlers.WithID("id", h.Receipt.GetShopReceipts)},
+
+		// Receipts
+		{"POST", "/api/receipts", false, h.Receipt.CreateReceipt},
+		{"GET", "/api/receipts/{id}", false, handlers.WithID("id", h.Receipt.GetReceipt)},
+		{"DELETE", "/api/receipts/{id}", false, handlers.WithID("id", h.Receipt.VoidReceipt)},
+		{"POST", "/api/receipts/{id}/void", false, handlers.WithID("id", h.Receipt.VoidReceipt)},
+		{"POST", "/api/receipts/{id}/refunds", false, handlers.WithID("id", h.Receipt.RefundItems)},
+
+		// Challenges
+		{"GET", "/api/challenges", false, h.Challenge.ListChallenges},
+		{"POST", "/api/challenges", false, h.Challenge.CreateChallenge},
+		{"PUT", "/api/challenges/{id}", false, handlers.WithID("id", h.Challenge.UpdateChallenge)},
+		{"DELETE", "/api/challenges/{id}", false, handlers.WithID("id", h.Challenge.RetireChallenge)},
+
+		// Points rules
+		{"GET", "/api/points/rules", false, h.Points.GetRules},
+		{"PUT", "/api/points/rules", false, h.Points.SetRules},
+		{"DELETE", "/api/points/rules", false, h.Points.ResetRules},
+		{"POST", "/api/points/preview", false, h.Points.Preview},
+
+		// Rewards
+		{"GET", "/api/rewards", false, h.Reward.ListRewards},
+		{"POST", "/api/rewards", false, h.Reward.CreateReward},
+		{"PUT", "/api/rewards/{id}", false, handlers.WithID("id", h.Reward.UpdateReward)},
+		{"POST", "/api/rewards/{id}/redeem", false, handlers.WithID("id", h.Reward.Redeem)},
+	}
+}
+
+// NewMux registers the routes on a ServeMux. Routes that are not public
+// require an access token.
+func NewMux(routes []Route, signer *auth.TokenSigner) *http.ServeMux {
+	mux := http.NewServeMux()
+	for _, route := range routes {
+		mux.HandleFunc(route.Method+" "+route.Pattern, auth.Authenticate(signer, !route.Public)(route.Handler))
+	}
+	mux.HandleFunc(fallbackPattern, fallback(mux))
+	return mux
+}
+
+// Matches reports whether a route of the mux, rather than the fallback,
+// handles the request.
+func Matches(mux *http.ServeMux, r *http.Request) bool {
+	_, pattern := mux.Handler(r)
+	return pattern != "" && pattern != fallbackPattern
+}
+
+const fallbackPattern = "/"
+
+var methods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+// fallback answers requests no route matches with the JSON error envelope:
+// 405 with an Allow header when the path exists under other methods,
+// otherwise 404.
+func fallback(mux *http.ServeMux) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var allowed []string
+		for _, method := range methods {
+			probe := r.Clone(r.Context())
+			probe.Method = method
+			if Matches(mux, probe) {
+				allowed = append(allowed, method)
+			}
+		}
+
+		if len(allowed) > 0 {
+			w.Header().Set("Allow", strings.Join(allowed, ", "))
+			apierror.Write(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "Method not allowed", nil)
+			return
+		}
+
+		apierror.Write(w, http.StatusNotFound, apierror.CodeNotFound, "Not found", nil)
+	}
+}
+
+func health(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":   "healthy",
+		"database": "connected",
+	})
+}
@@ -0,0 +1,140 @@
+// Package routetest checks that a server answers a route table the way
+// routes.NewMux does, so the net/http and GoFr servers share their tests.
+package routetest
+
+import (
+	"ecotracker-backend/apierror"
+	"ecotracker-backend/auth"
+	"ecotracker-backend/handlers"
+	"ecotracker-backend/routes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Signer signs the tokens of the requests Run sends.
+var Signer = auth.NewTokenSigner([]byte("routetest"), time.Hour)
+
+// Table returns stub routes for Run to call: one for every method, public
+// and protected routes, and path parameters.
+func Table() []routes.Route {
+	return []routes.Route{
+		{Method: "GET", Pattern: "/api/things/{id}", Public: true, Handler: handlers.WithID("id", func(w http.ResponseWriter, r *http.Request, id int) {
+			writeJSON(w, http.StatusOK, map[string]any{"id": id})
+		})},
+		{Method: "POST", Pattern: "/api/things", Public: false, Handler: func(w http.ResponseWriter, r *http.Request) {
+			principal, _ := auth.FromContext(r.Context())
+			writeJSON(w, http.StatusCreated, map[string]any{"owner": principal.ID})
+		}},
+		{Method: "PUT", Pattern: "/api/things/{id}", Public: false, Handler: handlers.WithID("id", func(w http.ResponseWriter, r *http.Request, id int) {
+			writeJSON(w, http.StatusOK, map[string]any{"id": id})
+		})},
+		{Method: "PATCH", Pattern: "/api/things/{id}/parts/{part_id}", Public: false, Handler: handlers.WithIDs("id", "part_id", func(w http.ResponseWriter, r *http.Request, id, partID int) {
+			writeJSON(w, http.StatusOK, map[string]any{"id": id, "part_id": partID})
+		})},
+		{Method: "DELETE", Pattern: "/api/things/{id}", Public: false, Handler: handlers.WithID("id", func(w http.ResponseWriter, r *http.Request, id int) {
+			w.WriteHeader(http.StatusNoContent)
+		})},
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// A Case is a request to a server mounting Table and the response expected.
+// Body is compared as JSON; Code is the error code of an error envelope.
+type Case struct {
+	Name   string
+	Method string
+	Path   string
+	Token  bool
+	Status int
+	Body   string
+	Code   string
+}
+
+// Cases are answered alike by every server that mounts Table.
+var Cases = []Case{
+	{Name: "public route", Method: "GET", Path: "/api/things/7", Status: http.StatusOK, Body: `{"id":7}`},
+	{Name: "bad path parameter", Method: "GET", Path: "/api/things/abc", Status: http.StatusBadRequest, Code: apierror.CodeBadRequest},
+	{Name: "protected route without token", Method: "POST", Path: "/api/things", Status: http.StatusUnauthorized, Code: apierror.CodeUnauthorized},
+	{Name: "protected route", Method: "POST", Path: "/api/things", Token: true, Status: http.StatusCreated, Body: `{"owner":5}`},
+	{Name: "put", Method: "PUT", Path: "/api/things/7", Token: true, Status: http.StatusOK, Body: `{"id":7}`},
+	{Name: "two path parameters", Method: "PATCH", Path: "/api/things/7/parts/9", Token: true, Status: http.StatusOK, Body: `{"id":7,"part_id":9}`},
+	{Name: "delete", Method: "DELETE", Path: "/api/things/7", Token: true, Status: http.StatusNoContent},
+}
+
+// FallbackCases are the requests routes.NewMux answers with the error
+// envelope because no route matches them.
+var FallbackCases = []Case{
+	{Name: "unknown path", Method: "GET", Path: "/api/nothing", Status: http.StatusNotFound, Code: apierror.CodeNotFound},
+	{Name: "wrong method", Method: "PATCH", Path: "/api/things", Status: http.StatusMethodNotAllowed, Code: apierror.CodeMethodNotAllowed},
+}
+
+// Run sends each case to the server at baseURL and checks the response.
+func Run(t *testing.T, baseURL string, cases []Case) {
+	t.Helper()
+
+	token, _, err := Signer.Sign(auth.Principal{Kind: auth.KindUser, ID: 5, Role: auth.RoleCustomer}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			req, err := http.NewRequest(tc.Method, baseURL+tc.Path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.Token {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if resp.StatusCode != tc.Status {
+				t.Fatalf("status = %d, want %d; body %s", resp.StatusCode, tc.Status, body)
+			}
+			if tc.Body != "" {
+				if got, want := normalize(t, body), normalize(t, []byte(tc.Body)); got != want {
+					t.Errorf("body = %s, want %s", got, want)
+				}
+			}
+			if tc.Code != "" {
+				var envelope apierror.Response
+				if err := json.Unmarshal(body, &envelope); err != nil || envelope.Code != tc.Code {
+					t.Errorf("body = %s, want an error envelope with code %q", body, tc.Code)
+				}
+			}
+		})
+	}
+}
+
+// normalize re-encodes a JSON body so bodies compare regardless of spacing.
+func normalize(t *testing.T, body []byte) string {
+	t.Helper()
+
+	var value any
+	if err := json.Unmarshal(body, &value); err != nil {
+		t.Fatalf("body %q is not JSON: %v", strings.TrimSpace(string(body)), err)
+	}
+	normalized, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(normalized)
+}
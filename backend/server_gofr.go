@@ -0,0 +1,37 @@
+//go:build gofr
+
+package main
+
+import (
+	"ecotracker-backend/auth"
+	"ecotracker-backend/config"
+	"ecotracker-backend/gofrserver"
+	"ecotracker-backend/routes"
+	"log"
+	"os"
+
+	"gofr.dev/pkg/gofr"
+)
+
+// serve runs the route table on GoFr, which adds its request logging,
+// metrics and tracing. The port and CORS origin from cfg are handed to GoFr
+// through its HTTP_PORT and ACCESS_CONTROL_* settings; GoFr sends the origin
+// as it is, so only one origin or * can be configured. Build with:
+// go build -tags gofr
+func serve(cfg *config.Config, table []routes.Route, signer *auth.TokenSigner) {
+	origin, err := cfg.SingleCORSOrigin()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	os.Setenv("HTTP_PORT", cfg.Port)
+	os.Setenv("ACCESS_CONTROL_ALLOW_ORIGIN", origin)
+	os.Setenv("ACCESS_CONTROL_ALLOW_HEADERS", "Idempotency-Key")
+	os.Setenv("ACCESS_CONTROL_EXPOSE_HEADERS", "Idempotent-Replayed")
+
+	app := gofr.New()
+
+	gofrserver.Mount(app, table, signer)
+
+	app.Run()
+}
@@ -0,0 +1,55 @@
+//go:build !gofr
+
+package main
+
+import (
+	"ecotracker-backend/auth"
+	"ecotracker-backend/config"
+	"ecotracker-backend/routes"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// serve runs the route table on the standard library HTTP server.
+func serve(cfg *config.Config, table []routes.Route, signer *auth.TokenSigner) {
+	mux := routes.NewMux(table, signer)
+
+	// Apply CORS to the router
+	http.Handle("/", corsHandler(cfg.CORSOrigins, mux.ServeHTTP))
+
+	fmt.Printf("Server starting on port %s\n", cfg.Port)
+	log.Fatal(http.ListenAndServe(":"+cfg.Port, nil))
+}
+
+// CORS middleware; origins lists the allowed origins, or "*" for any
+func corsHandler(origins []string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if origin := allowedOrigin(origins, r.Header.Get("Origin")); origin != "" {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+		}
+		w.Header().Add("Vary", "Origin")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Idempotency-Key")
+		w.Header().Set("Access-Control-Expose-Headers", "Idempotent-Replayed")
+
+		if r.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		h(w, r)
+	}
+}
+
+func allowedOrigin(origins []string, origin string) string {
+	for _, allowed := range origins {
+		if allowed == "*" {
+			return "*"
+		}
+		if origin != "" && allowed == origin {
+			return origin
+		}
+	}
+	return ""
+}
@@ -0,0 +1,19 @@
+//go:build !gofr
+
+package main
+
+import (
+	"ecotracker-backend/routes"
+	"ecotracker-backend/routes/routetest"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServeRoutes(t *testing.T) {
+	mux := routes.NewMux(routetest.Table(), routetest.Signer)
+	server := httptest.NewServer(corsHandler([]string{"*"}, mux.ServeHTTP))
+	defer server.Close()
+
+	routetest.Run(t, server.URL, routetest.Cases)
+	routetest.Run(t, server.URL, routetest.FallbackCases)
+}
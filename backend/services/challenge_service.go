@@ -0,0 +1,188 @@
+package services
+
+import (
+	"database/sql"
+	"ecotracker-backend/models"
+	"ecotracker-backend/repository"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type ChallengeService struct {
+	store repository.Store
+}
+
+func NewChallengeService(store repository.Store) *ChallengeService {
+	return &ChallengeService{store: store}
+}
+
+// ListChallenges returns the active challenges, and retired ones as well
+// when includeRetired is true.
+func (s *ChallengeService) ListChallenges(includeRetired bool) ([]models.Challenge, error) {
+	return s.store.Challenges().List(includeRetired)
+}
+
+func (s *ChallengeService) GetChallenge(id int) (*models.Challenge, error) {
+	challenge, err := s.store.Challenges().GetByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("challenge %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return challenge, nil
+}
+
+func (s *ChallengeService) CreateChallenge(def models.ChallengeDefinition) (*models.Challenge, error) {
+	challenge := &models.Challenge{}
+	applyDefinition(challenge, def)
+
+	if err := s.store.Challenges().Create(challenge); err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+// UpdateChallenge replaces the definition of a challenge. Completions
+// already recorded are kept.
+func (s *ChallengeService) UpdateChallenge(id int, def models.ChallengeDefinition) (*models.Challenge, error) {
+	challenge, err := s.GetChallenge(id)
+	if err != nil {
+		return nil, err
+	}
+	applyDefinition(challenge, def)
+
+	if err := s.store.Challenges().Update(challenge); err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+// RetireChallenge hides a challenge from customers. It stays in the
+// database so past completions still refer to it.
+func (s *ChallengeService) RetireChallenge(id int) (*models.Challenge, error) {
+	if _, err := s.GetChallenge(id); err != nil {
+		return nil, err
+	}
+
+	if err := s.store.Challenges().Retire(id, time.Now()); err != nil {
+		return nil, err
+	}
+	return s.GetChallenge(id)
+}
+
+func applyDefinition(challenge *models.Challenge, def models.ChallengeDefinition) {
+	challenge.Name = def.Name
+	challenge.Description = def.Description
+	challenge.Icon = def.Icon
+	challenge.Rule = def.Rule
+	challenge.Target = def.Target
+	challenge.Points = def.Points
+}
+
+// GetUserChallenges returns the active challenges with the user's progress.
+// A challenge whose target is reached counts as completed even before a
+// receipt recorded it, as for one created after the purchases that satisfy
+// it; listing only reads, so its bonus is paid and CompletedAt set by the
+// user's next receipt.
+func (s *ChallengeService) GetUserChallenges(userID int) ([]models.Challenge, error) {
+	challenges, _, err := measureChallenges(s.store, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range challenges {
+		if challenges[i].Progress >= challenges[i].Target {
+			challenges[i].Earned = true
+			challenges[i].IsCompleted = true
+		}
+	}
+
+	return challenges, nil
+}
+
+// measureChallenges returns every active challenge with the user's progress
+// and recorded completion, and when the user completed each challenge.
+func measureChallenges(store repository.Store, userID int) ([]models.Challenge, map[int]time.Time, error) {
+	challenges, err := store.Challenges().List(false)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	completions, err := store.Challenges().Completions(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for i := range challenges {
+		challenge := &challenges[i]
+		challenge.UserID = userID
+
+		challenge.Progress, err = store.Challenges().Progress(userID, challenge.Rule)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		completedAt, done := completions[challenge.ID]
+		if done {
+			challenge.CompletedAt = &completedAt
+		}
+		challenge.Earned = done
+		challenge.IsCompleted = done
+	}
+
+	return challenges, completions, nil
+}
+
+// updateChallenges measures the user's progress on every active challenge.
+// A challenge whose target is reached for the first time is recorded as
+// completed by receiptID and its bonus points are credited. Completions are
+// unique per user and challenge, so the bonus is paid once even when this
+// runs concurrently or receipts are replayed, and a challenge stays earned
+// if progress later drops. It returns the completions made by this call
+// that paid a bonus; challenges worth no points are recorded without a
+// ledger entry or an announcement.
+func updateChallenges(store repository.Store, userID, receiptID int) ([]models.ChallengeCompletion, error) {
+	challenges, completions, err := measureChallenges(store, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var completed []models.ChallengeCompletion
+	now := time.Now()
+
+	for _, challenge := range challenges {
+		if _, done := completions[challenge.ID]; done || challenge.Progress < challenge.Target {
+			continue
+		}
+
+		recorded, err := store.Challenges().RecordCompletion(userID, challenge.ID, receiptID, challenge.Points, now)
+		if err != nil {
+			return nil, err
+		}
+
+		// Only the call that recorded the completion pays the bonus
+		if recorded && challenge.Points > 0 {
+			err := store.Points().Append(&models.PointsEntry{
+				UserID:      userID,
+				Kind:        models.PointsBonus,
+				Amount:      challenge.Points,
+				Reason:      "Completed challenge " + challenge.Name,
+				ReceiptID:   &receiptID,
+				ChallengeID: &challenge.ID,
+			})
+			if err != nil {
+				return nil, err
+			}
+			completed = append(completed, models.ChallengeCompletion{
+				ChallengeID: challenge.ID,
+				Name:        challenge.Name,
+				Points:      challenge.Points,
+			})
+		}
+	}
+
+	return completed, nil
+}
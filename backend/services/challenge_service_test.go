@@ -0,0 +1,164 @@
+package services
+
+import (
+	"ecotracker-backend/models"
+	"ecotracker-backend/repository"
+	"testing"
+	"time"
+)
+
+// newTestItem adds an item to a shop's catalogue for receipts to sell.
+func newTestItem(t *testing.T, store repository.Store, shopID int, category string) *models.ShopItem {
+	t.Helper()
+
+	item := &models.ShopItem{
+		ShopID:        shopID,
+		Name:          category + " item",
+		Price:         models.Money{Amount: 200, Currency: models.DefaultCurrency},
+		Currency:      models.DefaultCurrency,
+		Category:      category,
+		IsEcoFriendly: models.IsEcoCategory(category),
+	}
+	if err := store.Shops().AddItem(item); err != nil {
+		t.Fatal(err)
+	}
+	return item
+}
+
+func bonusEntries(t *testing.T, store repository.Store, userID int) []models.PointsEntry {
+	t.Helper()
+
+	history, err := store.Points().History(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var bonuses []models.PointsEntry
+	for _, entry := range history {
+		if entry.Kind == models.PointsBonus {
+			bonuses = append(bonuses, entry)
+		}
+	}
+	return bonuses
+}
+
+// A challenge worth no points is completed without a bonus entry or an
+// announcement on the receipt; one worth points gets both.
+func TestCreateReceiptChallengeBonus(t *testing.T) {
+	store := newTestStore(t)
+	customer := newTestCustomer(t, store, "c@example.com")
+	shop := newTestShop(t, store, "s@example.com")
+	organic := newTestItem(t, store, shop.ID, "Organic")
+	receipts := NewReceiptService(store, NewPointsRulesService(store, models.DefaultPointsRules()), time.Hour)
+	challenges := NewChallengeService(store)
+
+	// The seeded "Eco-Friendly Shopping" challenge is worth no points
+	paid, err := challenges.CreateChallenge(models.ChallengeDefinition{
+		Name:   "First organic",
+		Rule:   models.ChallengeRule{Metric: models.MetricItems, Categories: []string{"Organic"}},
+		Target: 1,
+		Points: 50,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	receipt, err := receipts.CreateReceipt(models.ReceiptCreate{
+		UserID: customer.ID,
+		ShopID: shop.ID,
+		Items:  []models.ReceiptLine{{ItemID: organic.ID, Quantity: 5}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []models.ChallengeCompletion{{ChallengeID: paid.ID, Name: paid.Name, Points: 50}}
+	if len(receipt.CompletedChallenges) != 1 || receipt.CompletedChallenges[0] != want[0] {
+		t.Errorf("completed challenges = %+v, want %+v", receipt.CompletedChallenges, want)
+	}
+	if receipt.BonusPoints != 50 {
+		t.Errorf("bonus points = %d, want 50", receipt.BonusPoints)
+	}
+	if bonuses := bonusEntries(t, store, customer.ID); len(bonuses) != 1 || bonuses[0].Amount != 50 {
+		t.Errorf("bonus entries = %+v, want one of 50 points", bonuses)
+	}
+
+	// The unpaid challenge still counts as completed
+	list, err := challenges.GetUserChallenges(customer.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, challenge := range list {
+		if challenge.Name == "Eco-Friendly Shopping" && !challenge.IsCompleted {
+			t.Errorf("challenge %q is not completed", challenge.Name)
+		}
+	}
+}
+
+// A challenge reached before it existed is listed as completed, but listing
+// records nothing: the next receipt records it and pays the bonus.
+func TestGetUserChallengesIsReadOnly(t *testing.T) {
+	store := newTestStore(t)
+	customer := newTestCustomer(t, store, "c@example.com")
+	shop := newTestShop(t, store, "s@example.com")
+	food := newTestItem(t, store, shop.ID, "Food")
+	receipts := NewReceiptService(store, NewPointsRulesService(store, models.DefaultPointsRules()), time.Hour)
+	challenges := NewChallengeService(store)
+
+	buy := func() *models.Receipt {
+		t.Helper()
+		receipt, err := receipts.CreateReceipt(models.ReceiptCreate{
+			UserID: customer.ID,
+			ShopID: shop.ID,
+			Items:  []models.ReceiptLine{{ItemID: food.ID, Quantity: 1}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return receipt
+	}
+
+	buy()
+	late, err := challenges.CreateChallenge(models.ChallengeDefinition{
+		Name:   "Late challenge",
+		Rule:   models.ChallengeRule{Metric: models.MetricItems, Categories: []string{"Food"}},
+		Target: 1,
+		Points: 20,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := challenges.GetUserChallenges(customer.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, challenge := range list {
+		if challenge.ID == late.ID && (challenge.Progress != 1 || !challenge.IsCompleted || !challenge.Earned || challenge.CompletedAt != nil) {
+			t.Errorf("challenge = %+v, want progress 1 and completed but not yet recorded", challenge)
+		}
+	}
+	completions, err := store.Challenges().Completions(customer.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(completions) != 0 {
+		t.Errorf("completions after listing = %v, want none", completions)
+	}
+	if bonuses := bonusEntries(t, store, customer.ID); len(bonuses) != 0 {
+		t.Errorf("bonus entries after listing = %+v, want none", bonuses)
+	}
+
+	receipt := buy()
+	if receipt.BonusPoints != 20 {
+		t.Errorf("bonus points of the next receipt = %d, want 20", receipt.BonusPoints)
+	}
+	list, err = challenges.GetUserChallenges(customer.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, challenge := range list {
+		if challenge.ID == late.ID && (!challenge.IsCompleted || challenge.CompletedAt == nil) {
+			t.Errorf("challenge after the next receipt = %+v, want it recorded", challenge)
+		}
+	}
+}
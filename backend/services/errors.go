@@ -0,0 +1,52 @@
+package services
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// Sentinel errors returned by the services. Callers wrap them with the
+// resource involved, e.g. fmt.Errorf("user %w", ErrNotFound) reads
+// "user not found", and test for them with errors.Is.
+var (
+	ErrNotFound           = errors.New("not found")
+	ErrConflict           = errors.New("already exists")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrInvalidToken       = errors.New("invalid refresh token")
+	ErrValidation         = errors.New("validation failed")
+	ErrInsufficientPoints = errors.New("insufficient points")
+	ErrUnavailable        = errors.New("is no longer available")
+	ErrVoided             = errors.New("is voided")
+	ErrKeyReused          = errors.New("was already used for a different request")
+	// ErrConcurrentUpdate means another request changed the same records
+	// first; retrying the request may succeed.
+	ErrConcurrentUpdate = errors.New("changed while the request ran")
+)
+
+// ValidationError reports invalid input per field. It matches ErrValidation.
+type ValidationError struct {
+	Fields map[string]string
+}
+
+func NewValidationError(field, problem string) *ValidationError {
+	return &ValidationError{Fields: map[string]string{field: problem}}
+}
+
+func (e *ValidationError) Error() string {
+	names := make([]string, 0, len(e.Fields))
+	for name := range e.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	problems := make([]string, 0, len(names))
+	for _, name := range names {
+		problems = append(problems, name+" "+e.Fields[name])
+	}
+	return "validation failed: " + strings.Join(problems, ", ")
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"crypto/sha256"
+	"ecotracker-backend/models"
+	"ecotracker-backend/repository"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// idempotent runs fn at most once per key within ttl. The key is claimed,
+// fn runs and its result is stored in one transaction, so a retry that
+// arrives while the first request is still running waits for it and then
+// gets its result. A retry returns the stored result and true; reusing the
+// key with a different request fails with ErrKeyReused.
+func idempotent[T any](store repository.Store, key models.IdempotencyKey, ttl time.Duration, request interface{}, fn func(tx repository.Store) (T, error)) (T, bool, error) {
+	var result T
+	replayed := false
+
+	hash, err := requestHash(request)
+	if err != nil {
+		return result, false, err
+	}
+
+	err = store.WithTx(func(tx repository.Store) error {
+		now := time.Now()
+		claimed, err := tx.Idempotency().Claim(&models.IdempotencyRecord{
+			IdempotencyKey: key,
+			RequestHash:    hash,
+			CreatedAt:      now,
+			ExpiresAt:      now.Add(ttl),
+		})
+		if err != nil {
+			return err
+		}
+
+		if !claimed {
+			record, err := tx.Idempotency().Get(key)
+			if err != nil {
+				return err
+			}
+			if record.RequestHash != hash {
+				return fmt.Errorf("idempotency key %w", ErrKeyReused)
+			}
+			replayed = true
+			return json.Unmarshal([]byte(record.Response), &result)
+		}
+
+		result, err = fn(tx)
+		if err != nil {
+			return err
+		}
+
+		response, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		return tx.Idempotency().Complete(key, string(response))
+	})
+	if err != nil {
+		var zero T
+		return zero, false, err
+	}
+
+	return result, replayed, nil
+}
+
+// requestHash fingerprints a decoded request, so retries that format the
+// same JSON differently still match.
+func requestHash(request interface{}) (string, error) {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
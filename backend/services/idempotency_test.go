@@ -0,0 +1,142 @@
+package services
+
+import (
+	"ecotracker-backend/models"
+	"ecotracker-backend/repository"
+	"errors"
+	"testing"
+	"time"
+)
+
+type testRequest struct {
+	Item     string `json:"item"`
+	Quantity int    `json:"quantity"`
+}
+
+type testResponse struct {
+	Run  int    `json:"run"`
+	Item string `json:"item"`
+}
+
+func TestIdempotent(t *testing.T) {
+	key := models.IdempotencyKey{PrincipalKind: "shop", PrincipalID: 1, Scope: "POST /test", Key: "k1"}
+	request := testRequest{Item: "bread", Quantity: 2}
+
+	tests := []struct {
+		name         string
+		ttl          time.Duration
+		second       models.IdempotencyKey
+		request      testRequest
+		wantErr      error
+		wantReplayed bool
+		wantRuns     int
+	}{
+		{name: "replay", ttl: time.Hour, second: key, request: request, wantReplayed: true, wantRuns: 1},
+		{name: "different request", ttl: time.Hour, second: key, request: testRequest{Item: "bread", Quantity: 3}, wantErr: ErrKeyReused, wantRuns: 1},
+		{name: "another principal", ttl: time.Hour, second: models.IdempotencyKey{PrincipalKind: "shop", PrincipalID: 2, Scope: "POST /test", Key: "k1"}, request: request, wantRuns: 2},
+		{name: "another scope", ttl: time.Hour, second: models.IdempotencyKey{PrincipalKind: "shop", PrincipalID: 1, Scope: "POST /other", Key: "k1"}, request: request, wantRuns: 2},
+		{name: "another key", ttl: time.Hour, second: models.IdempotencyKey{PrincipalKind: "shop", PrincipalID: 1, Scope: "POST /test", Key: "k2"}, request: request, wantRuns: 2},
+		{name: "expired key", ttl: -time.Second, second: key, request: testRequest{Item: "milk"}, wantRuns: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newTestStore(t)
+			runs := 0
+			handle := func(req testRequest) func(tx repository.Store) (testResponse, error) {
+				return func(tx repository.Store) (testResponse, error) {
+					runs++
+					return testResponse{Run: runs, Item: req.Item}, nil
+				}
+			}
+
+			first, replayed, err := idempotent(store, key, tt.ttl, request, handle(request))
+			if err != nil || replayed {
+				t.Fatalf("first call = %+v, %v, %v; want a fresh result", first, replayed, err)
+			}
+
+			second, replayed, err := idempotent(store, tt.second, tt.ttl, tt.request, handle(tt.request))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("second call: err = %v, want %v", err, tt.wantErr)
+			}
+			if replayed != tt.wantReplayed {
+				t.Errorf("second call replayed = %v, want %v", replayed, tt.wantReplayed)
+			}
+			if runs != tt.wantRuns {
+				t.Errorf("handler ran %d times, want %d", runs, tt.wantRuns)
+			}
+			if tt.wantReplayed && second != first {
+				t.Errorf("replayed response = %+v, want the stored %+v", second, first)
+			}
+		})
+	}
+}
+
+// A request that fails leaves its key unclaimed, so a retry runs it again.
+func TestIdempotentFailureReleasesKey(t *testing.T) {
+	store := newTestStore(t)
+	key := models.IdempotencyKey{PrincipalKind: "shop", PrincipalID: 1, Scope: "POST /test", Key: "k1"}
+	request := testRequest{Item: "bread"}
+	errFailed := errors.New("failed")
+
+	_, _, err := idempotent(store, key, time.Hour, request, func(tx repository.Store) (testResponse, error) {
+		return testResponse{}, errFailed
+	})
+	if !errors.Is(err, errFailed) {
+		t.Fatalf("err = %v, want the handler's error", err)
+	}
+
+	got, replayed, err := idempotent(store, key, time.Hour, request, func(tx repository.Store) (testResponse, error) {
+		return testResponse{Run: 2, Item: request.Item}, nil
+	})
+	if err != nil || replayed || got.Run != 2 {
+		t.Errorf("retry = %+v, %v, %v; want it run again", got, replayed, err)
+	}
+}
+
+// A retried receipt is created, and earns points, once.
+func TestCreateReceiptOnce(t *testing.T) {
+	store := newTestStore(t)
+	customer := newTestCustomer(t, store, "c@example.com")
+	shop := newTestShop(t, store, "s@example.com")
+	food := newTestItem(t, store, shop.ID, "Food")
+	receipts := NewReceiptService(store, NewPointsRulesService(store, models.DefaultPointsRules()), time.Hour)
+
+	key := models.IdempotencyKey{PrincipalKind: "shop", PrincipalID: shop.ID, Scope: "POST /api/receipts", Key: "retry-me"}
+	request := models.ReceiptCreate{
+		UserID: customer.ID,
+		ShopID: shop.ID,
+		Items:  []models.ReceiptLine{{ItemID: food.ID, Quantity: 3}},
+	}
+
+	first, replayed, err := receipts.CreateReceiptOnce(key, request)
+	if err != nil || replayed {
+		t.Fatalf("first request = %v, %v; want a new receipt", replayed, err)
+	}
+	second, replayed, err := receipts.CreateReceiptOnce(key, request)
+	if err != nil || !replayed {
+		t.Fatalf("retry = %v, %v; want the stored receipt", replayed, err)
+	}
+	// The stored response is JSON, which carries each amount's currency
+	// once, on the receipt
+	if second.ID != first.ID || second.TotalAmount.Amount != first.TotalAmount.Amount ||
+		second.Currency != first.Currency || second.PointsEarned != first.PointsEarned {
+		t.Errorf("retry returned %+v, want %+v", second, first)
+	}
+
+	request.Items[0].Quantity = 4
+	if _, _, err := receipts.CreateReceiptOnce(key, request); !errors.Is(err, ErrKeyReused) {
+		t.Errorf("reused key: err = %v, want ErrKeyReused", err)
+	}
+
+	list, err := store.Receipts().ListByUser(customer.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := store.Users().GetByID(customer.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || user.Points != first.PointsEarned {
+		t.Errorf("%d receipts and %d points, want 1 receipt and %d points", len(list), user.Points, first.PointsEarned)
+	}
+}
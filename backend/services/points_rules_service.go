@@ -0,0 +1,182 @@
+package services
+
+import (
+	"database/sql"
+	"ecotracker-backend/models"
+	"ecotracker-backend/repository"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+)
+
+// pointsRulesKey is the setting holding rules saved through the admin API.
+const pointsRulesKey = "points_rules"
+
+// PointsRulesService decides how many points purchases earn. Rules saved
+// through the admin API take precedence over the fallback, which comes from
+// the rules file or models.DefaultPointsRules.
+type PointsRulesService struct {
+	store    repository.Store
+	fallback models.PointsRules
+}
+
+func NewPointsRulesService(store repository.Store, fallback models.PointsRules) *PointsRulesService {
+	return &PointsRulesService{store: store, fallback: fallback}
+}
+
+// GetRules returns the rules in effect.
+func (s *PointsRulesService) GetRules() (models.PointsRules, error) {
+	return s.current(s.store)
+}
+
+// SetRules replaces the rules in effect until they are reset.
+func (s *PointsRulesService) SetRules(rules models.PointsRules) error {
+	if err := CheckPointsRules(rules); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(rules)
+	if err != nil {
+		return err
+	}
+	return s.store.Settings().Put(pointsRulesKey, string(data))
+}
+
+// ResetRules discards rules saved through the admin API, going back to the
+// fallback.
+func (s *PointsRulesService) ResetRules() (models.PointsRules, error) {
+	if err := s.store.Settings().Delete(pointsRulesKey); err != nil {
+		return models.PointsRules{}, err
+	}
+	return s.fallback, nil
+}
+
+// Preview quotes a basket without recording anything.
+func (s *PointsRulesService) Preview(req models.PointsPreviewRequest) (*models.PointsQuote, error) {
+	rules := req.Rules
+	if rules == nil {
+		current, err := s.current(s.store)
+		if err != nil {
+			return nil, err
+		}
+		rules = &current
+	} else if err := CheckPointsRules(*rules); err != nil {
+		return nil, err
+	}
+
+	return quote(s.store, *rules, req.UserID, req.ShopID, req.Items, time.Now())
+}
+
+// IsCustomer reports whether the shop has issued a receipt to the user.
+func (s *PointsRulesService) IsCustomer(shopID, userID int) (bool, error) {
+	return s.store.Receipts().IsCustomer(shopID, userID)
+}
+
+// Quote computes the points a purchase made now earns under the rules in
+// effect, reading through store so it sees the caller's transaction.
+func (s *PointsRulesService) Quote(store repository.Store, userID, shopID int, items []models.ReceiptItem) (*models.PointsQuote, error) {
+	rules, err := s.current(store)
+	if err != nil {
+		return nil, err
+	}
+	return quote(store, rules, userID, shopID, items, time.Now())
+}
+
+func (s *PointsRulesService) current(store repository.Store) (models.PointsRules, error) {
+	data, err := store.Settings().Get(pointsRulesKey)
+	if errors.Is(err, sql.ErrNoRows) {
+		return s.fallback, nil
+	}
+	if err != nil {
+		return models.PointsRules{}, err
+	}
+
+	var rules models.PointsRules
+	if err := json.Unmarshal([]byte(data), &rules); err != nil {
+		return models.PointsRules{}, fmt.Errorf("stored points rules: %w", err)
+	}
+	return rules, nil
+}
+
+// CheckPointsRules reports problems the validate tags cannot express.
+func CheckPointsRules(rules models.PointsRules) error {
+	for i, rule := range rules.Rules {
+		if rule.StartsAt != nil && rule.EndsAt != nil && !rule.EndsAt.After(*rule.StartsAt) {
+			return NewValidationError(fmt.Sprintf("rules[%d].ends_at", i), "must be after starts_at")
+		}
+	}
+	return nil
+}
+
+// quote applies rules to a basket bought at shopID at time at. With a
+// userID the daily cap counts the points the user already earned since
+// midnight UTC.
+func quote(store repository.Store, rules models.PointsRules, userID, shopID int, items []models.ReceiptItem, at time.Time) (*models.PointsQuote, error) {
+	result := &models.PointsQuote{Lines: make([]models.PointsLine, 0, len(items))}
+
+	for _, item := range items {
+		line := models.PointsLine{
+			Name:          item.Name,
+			Category:      item.Category,
+			IsEcoFriendly: item.IsEcoFriendly || models.IsEcoCategory(item.Category),
+			Amount:        item.Price.Times(item.Quantity),
+			Multiplier:    1,
+			Rules:         []string{},
+		}
+
+		for _, rule := range rules.Rules {
+			if ruleMatches(rule, shopID, line, at) {
+				line.Multiplier *= rule.Multiplier
+				line.Rules = append(line.Rules, rule.Name)
+			}
+		}
+
+		// The epsilon keeps products such as 0.29 * 100 from rounding down a point
+		line.Points = int(math.Floor(line.Amount.Major()*rules.BasePoints*line.Multiplier + 1e-9))
+		result.Subtotal += line.Points
+		result.Lines = append(result.Lines, line)
+	}
+
+	result.Points = result.Subtotal
+	if rules.ReceiptCap > 0 && result.Points > rules.ReceiptCap {
+		result.Points = rules.ReceiptCap
+		result.CappedBy = models.CappedByReceipt
+	}
+
+	if rules.DailyCap > 0 && userID != 0 {
+		year, month, day := at.UTC().Date()
+		earned, err := store.Points().Earned(userID, time.Date(year, month, day, 0, 0, 0, 0, time.UTC).In(at.Location()))
+		if err != nil {
+			return nil, err
+		}
+
+		if remaining := max(rules.DailyCap-earned, 0); result.Points > remaining {
+			result.Points = remaining
+			result.CappedBy = models.CappedByDaily
+		}
+	}
+
+	return result, nil
+}
+
+func ruleMatches(rule models.PointsRule, shopID int, line models.PointsLine, at time.Time) bool {
+	if len(rule.Categories) > 0 && !slices.Contains(rule.Categories, line.Category) {
+		return false
+	}
+	if rule.EcoFriendly != nil && *rule.EcoFriendly != line.IsEcoFriendly {
+		return false
+	}
+	if len(rule.ShopIDs) > 0 && !slices.Contains(rule.ShopIDs, shopID) {
+		return false
+	}
+	if rule.StartsAt != nil && at.Before(*rule.StartsAt) {
+		return false
+	}
+	if rule.EndsAt != nil && !at.Before(*rule.EndsAt) {
+		return false
+	}
+	return true
+}
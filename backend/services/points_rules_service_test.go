@@ -0,0 +1,186 @@
+package services
+
+import (
+	"ecotracker-backend/models"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestQuote(t *testing.T) {
+	store := newTestStore(t)
+	customer := newTestCustomer(t, store, "c@example.com")
+	newcomer := newTestCustomer(t, store, "n@example.com")
+
+	at := time.Date(2025, 6, 10, 12, 0, 0, 0, time.UTC)
+	for _, entry := range []models.PointsEntry{
+		// Earned yesterday, and a bonus rather than a purchase: neither
+		// counts towards today's cap
+		{UserID: customer.ID, Kind: models.PointsEarn, Amount: 50, Reason: "yesterday", CreatedAt: at.Add(-13 * time.Hour)},
+		{UserID: customer.ID, Kind: models.PointsBonus, Amount: 100, Reason: "bonus", CreatedAt: at.Add(-time.Hour)},
+		{UserID: customer.ID, Kind: models.PointsEarn, Amount: 30, Reason: "this morning", CreatedAt: at.Add(-3 * time.Hour)},
+	} {
+		if err := store.Points().Append(&entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	eco, notEco := true, false
+	euros := func(amount int64) models.Money {
+		return models.Money{Amount: amount * 100, Currency: models.DefaultCurrency}
+	}
+	item := func(category string, price int64, quantity int) models.ReceiptItem {
+		return models.ReceiptItem{Name: category, Category: category, Price: euros(price), Quantity: quantity}
+	}
+	before, after := at.Add(-time.Hour), at.Add(time.Hour)
+
+	tests := []struct {
+		name     string
+		rules    models.PointsRules
+		userID   int
+		shopID   int
+		items    []models.ReceiptItem
+		lines    []int
+		points   int
+		cappedBy string
+	}{
+		{
+			name:   "base points per unit",
+			rules:  models.PointsRules{BasePoints: 2},
+			items:  []models.ReceiptItem{item("Food", 3, 2)},
+			lines:  []int{12},
+			points: 12,
+		},
+		{
+			name:   "fractional amounts round down",
+			rules:  models.PointsRules{BasePoints: 1},
+			items:  []models.ReceiptItem{{Name: "Gum", Category: "Food", Price: models.Money{Amount: 199, Currency: "EUR"}, Quantity: 1}},
+			lines:  []int{1},
+			points: 1,
+		},
+		{
+			name:   "default rules double eco categories",
+			rules:  models.DefaultPointsRules(),
+			items:  []models.ReceiptItem{item("Organic", 5, 1), item("Food", 5, 1)},
+			lines:  []int{10, 5},
+			points: 15,
+		},
+		{
+			name: "eco_friendly false matches unflagged items",
+			rules: models.PointsRules{BasePoints: 1, Rules: []models.PointsRule{
+				{Name: "plain", Multiplier: 3, EcoFriendly: &notEco},
+			}},
+			items:  []models.ReceiptItem{item("Organic", 5, 1), item("Food", 5, 1)},
+			lines:  []int{5, 15},
+			points: 20,
+		},
+		{
+			name: "category bonus",
+			rules: models.PointsRules{BasePoints: 1, Rules: []models.PointsRule{
+				{Name: "fruit week", Multiplier: 3, Categories: []string{"Fruits", "Vegetables"}},
+			}},
+			items:  []models.ReceiptItem{item("Fruits", 2, 1), item("Vegetables", 1, 2), item("Food", 2, 1)},
+			lines:  []int{6, 6, 2},
+			points: 14,
+		},
+		{
+			name: "multipliers of matching rules compound",
+			rules: models.PointsRules{BasePoints: 1, Rules: []models.PointsRule{
+				{Name: "eco", Multiplier: 2, EcoFriendly: &eco},
+				{Name: "organic", Multiplier: 1.5, Categories: []string{"Organic"}},
+			}},
+			items:  []models.ReceiptItem{item("Organic", 10, 1)},
+			lines:  []int{30},
+			points: 30,
+		},
+		{
+			name: "shop promotion",
+			rules: models.PointsRules{BasePoints: 1, Rules: []models.PointsRule{
+				{Name: "here", Multiplier: 2, ShopIDs: []int{1}},
+				{Name: "elsewhere", Multiplier: 5, ShopIDs: []int{2}},
+			}},
+			shopID: 1,
+			items:  []models.ReceiptItem{item("Food", 4, 1)},
+			lines:  []int{8},
+			points: 8,
+		},
+		{
+			name: "time windows",
+			rules: models.PointsRules{BasePoints: 1, Rules: []models.PointsRule{
+				{Name: "running", Multiplier: 2, StartsAt: &before, EndsAt: &after},
+				{Name: "starting now", Multiplier: 3, StartsAt: &at},
+				{Name: "not started", Multiplier: 5, StartsAt: &after},
+				{Name: "ending now", Multiplier: 7, EndsAt: &at},
+				{Name: "ended", Multiplier: 11, EndsAt: &before},
+			}},
+			items:  []models.ReceiptItem{item("Food", 1, 1)},
+			lines:  []int{6},
+			points: 6,
+		},
+		{
+			name:     "receipt cap",
+			rules:    models.PointsRules{BasePoints: 1, ReceiptCap: 25},
+			items:    []models.ReceiptItem{item("Food", 10, 3)},
+			lines:    []int{30},
+			points:   25,
+			cappedBy: models.CappedByReceipt,
+		},
+		{
+			name:     "daily cap counts today's earnings",
+			rules:    models.PointsRules{BasePoints: 1, DailyCap: 40},
+			userID:   customer.ID,
+			items:    []models.ReceiptItem{item("Food", 20, 1)},
+			lines:    []int{20},
+			points:   10,
+			cappedBy: models.CappedByDaily,
+		},
+		{
+			name:   "daily cap of a customer who earned nothing today",
+			rules:  models.PointsRules{BasePoints: 1, DailyCap: 40},
+			userID: newcomer.ID,
+			items:  []models.ReceiptItem{item("Food", 20, 1)},
+			lines:  []int{20},
+			points: 20,
+		},
+		{
+			name:   "daily cap without a customer",
+			rules:  models.PointsRules{BasePoints: 1, DailyCap: 10},
+			items:  []models.ReceiptItem{item("Food", 20, 1)},
+			lines:  []int{20},
+			points: 20,
+		},
+		{
+			name:     "daily cap below the receipt cap",
+			rules:    models.PointsRules{BasePoints: 1, ReceiptCap: 15, DailyCap: 40},
+			userID:   customer.ID,
+			items:    []models.ReceiptItem{item("Food", 20, 1)},
+			lines:    []int{20},
+			points:   10,
+			cappedBy: models.CappedByDaily,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quote, err := quote(store, tt.rules, tt.userID, tt.shopID, tt.items, at)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var lines []int
+			subtotal := 0
+			for _, line := range quote.Lines {
+				lines = append(lines, line.Points)
+				subtotal += line.Points
+			}
+			if !slices.Equal(lines, tt.lines) {
+				t.Errorf("line points = %v, want %v", lines, tt.lines)
+			}
+			if quote.Subtotal != subtotal {
+				t.Errorf("subtotal = %d, want %d", quote.Subtotal, subtotal)
+			}
+			if quote.Points != tt.points || quote.CappedBy != tt.cappedBy {
+				t.Errorf("points = %d capped by %q, want %d capped by %q", quote.Points, quote.CappedBy, tt.points, tt.cappedBy)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package services
+
+import (
+	"ecotracker-backend/models"
+	"ecotracker-backend/repository"
+)
+
+type PointsService struct {
+	store repository.Store
+}
+
+func NewPointsService(store repository.Store) *PointsService {
+	return &PointsService{store: store}
+}
+
+// GetHistory returns the user's points ledger, newest first.
+func (s *PointsService) GetHistory(userID int) ([]models.PointsEntry, error) {
+	return s.store.Points().History(userID)
+}
+
+// FindDrift returns the users whose stored balance no longer matches their
+// ledger, which means points were changed without a ledger entry.
+func (s *PointsService) FindDrift() ([]models.PointsDrift, error) {
+	return s.store.Points().Drift()
+}
+
+// Reconcile resets every drifted balance to its ledger total and returns the
+// users it corrected.
+func (s *PointsService) Reconcile() ([]models.PointsDrift, error) {
+	var drift []models.PointsDrift
+	err := s.store.WithTx(func(tx repository.Store) error {
+		var err error
+		drift, err = tx.Points().Drift()
+		if err != nil {
+			return err
+		}
+
+		for _, d := range drift {
+			if err := tx.Points().Reconcile(d.UserID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return drift, nil
+}
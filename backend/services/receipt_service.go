@@ -1,239 +1,415 @@
 ﻿package services
 
 import (
-"ecotracker-backend/database"
+"database/sql"
 "ecotracker-backend/models"
+"ecotracker-backend/repository"
 "errors"
+"fmt"
+"time"
 )
 
 type ReceiptService struct {
-db *database.Database
+store          repository.Store
+rules          *PointsRulesService
+idempotencyTTL time.Duration
 }
 
-func NewReceiptService(db *database.Database) *ReceiptService {
-return &ReceiptService{db: db}
+// NewReceiptService returns the receipt service. Idempotency keys of
+// receipt creation are remembered for idempotencyTTL.
+func NewReceiptService(store repository.Store, rules *PointsRulesService, idempotencyTTL time.Duration) *ReceiptService {
+return &ReceiptService{store: store, rules: rules, idempotencyTTL: idempotencyTTL}
 }
 
 func (s *ReceiptService) CreateReceipt(receiptCreate models.ReceiptCreate) (*models.Receipt, error) {
-// Calculate total amount and points
-totalAmount := 0.0
-pointsEarned := 0
+return s.createReceipt(s.store, receiptCreate)
+}
 
-for _, item := range receiptCreate.Items {
-totalAmount += item.Price * float64(item.Quantity)
-// Award points based on eco-friendly categories
-if item.Category == "Organic" || item.Category == "Eco-Friendly" {
-pointsEarned += int(item.Price) * item.Quantity * 2
-} else {
-pointsEarned += int(item.Price) * item.Quantity
+// CreateReceiptOnce is CreateReceipt for a request with an Idempotency-Key.
+// Repeating it with the same key and body returns the receipt created the
+// first time, and true, instead of creating another one.
+func (s *ReceiptService) CreateReceiptOnce(key models.IdempotencyKey, receiptCreate models.ReceiptCreate) (*models.Receipt, bool, error) {
+return idempotent(s.store, key, s.idempotencyTTL, receiptCreate, func(tx repository.Store) (*models.Receipt, error) {
+return s.createReceipt(tx, receiptCreate)
+})
 }
+
+func (s *ReceiptService) createReceipt(store repository.Store, receiptCreate models.ReceiptCreate) (*models.Receipt, error) {
+receipt := &models.Receipt{
+ShopID: receiptCreate.ShopID,
+UserID: receiptCreate.UserID,
 }
 
-// Insert receipt
-result, err := s.db.DB.Exec(`
-INSERT INTO receipts (user_id, shop_id, total_amount, points_earned, created_at) 
-VALUES (?, ?, ?, ?, datetime('now'))`,
-receiptCreate.UserID, receiptCreate.ShopID, totalAmount, pointsEarned)
+// Receipt, items and points are written together or not at all
+err := store.WithTx(func(tx repository.Store) error {
+items, err := receiptItems(tx, receiptCreate)
 if err != nil {
-return nil, err
+return err
 }
+receipt.Items = items
+receipt.Currency = items[0].Price.Currency
 
-receiptID, err := result.LastInsertId()
+// Calculate total amount, exactly, in the receipt's currency
+receipt.TotalAmount = models.Money{Currency: receipt.Currency}
+for _, item := range items {
+receipt.TotalAmount, err = receipt.TotalAmount.Plus(item.Price.Times(item.Quantity))
 if err != nil {
-return nil, err
+return err
+}
 }
 
-// Insert receipt items
-for _, item := range receiptCreate.Items {
-_, err := s.db.DB.Exec(`
-INSERT INTO receipt_items (receipt_id, name, price, quantity, category, is_eco_friendly) 
-VALUES (?, ?, ?, ?, ?, ?)`,
-receiptID, item.Name, item.Price, item.Quantity, item.Category, item.IsEcoFriendly)
+// Points come from the configured rules; the daily cap needs the
+// transaction to see the customer's earlier receipts. Overriding a
+// price never earns more than the catalogue price would.
+quoted := make([]models.ReceiptItem, len(items))
+for i, item := range items {
+quoted[i] = item
+if item.ListPrice.Amount < item.Price.Amount {
+quoted[i].Price = *item.ListPrice
+}
+}
+quote, err := s.rules.Quote(tx, receiptCreate.UserID, receiptCreate.ShopID, quoted)
 if err != nil {
-return nil, err
+return err
 }
+receipt.PointsEarned = quote.Points
+
+if err := tx.Receipts().Create(receipt); err != nil {
+return err
 }
 
-// Update customer's points
-_, err = s.db.DB.Exec(`
-UPDATE users SET points = points + ?, updated_at = datetime('now') 
-WHERE id = ?`,
-pointsEarned, receiptCreate.UserID)
+// Credit the customer's points
+err = tx.Points().Append(&models.PointsEntry{
+UserID:    receiptCreate.UserID,
+Kind:      models.PointsEarn,
+Amount:    receipt.PointsEarned,
+Reason:    "Purchase",
+ReceiptID: &receipt.ID,
+})
 if err != nil {
-return nil, err
+return err
 }
 
-receipt := &models.Receipt{
-ID:           int(receiptID),
-ShopID:       receiptCreate.ShopID,
-UserID:       receiptCreate.UserID,
-Items:        receiptCreate.Items,
-TotalAmount:  totalAmount,
-PointsEarned: pointsEarned,
+// Pay the bonus of any challenge this receipt completes
+completed, err := updateChallenges(tx, receiptCreate.UserID, receipt.ID)
+if err != nil {
+return err
+}
+receipt.CompletedChallenges = completed
+for _, completion := range completed {
+receipt.BonusPoints += completion.Points
+}
+return nil
+})
+if err != nil {
+return nil, err
 }
 
 return receipt, nil
 }
 
-func (s *ReceiptService) GetUserReceipts(userID int) ([]models.Receipt, error) {
-rows, err := s.db.DB.Query(`
-SELECT r.id, r.user_id, r.shop_id, r.total_amount, r.points_earned, r.created_at
-FROM receipts r WHERE r.user_id = ? ORDER BY r.created_at DESC`,
-userID)
+// GetUserReceipts returns the user's receipts, with their items when
+// withItems is true.
+func (s *ReceiptService) GetUserReceipts(userID int, withItems bool) ([]models.Receipt, error) {
+receipts, err := s.store.Receipts().ListByUser(userID)
 if err != nil {
 return nil, err
 }
-defer rows.Close()
+return s.withItems(receipts, withItems)
+}
 
-var receipts []models.Receipt
-for rows.Next() {
-var receipt models.Receipt
-err := rows.Scan(&receipt.ID, &receipt.UserID, &receipt.ShopID, 
-&receipt.TotalAmount, &receipt.PointsEarned, &receipt.CreatedAt)
+// GetShopReceipts returns the shop's receipts, with their items when
+// withItems is true.
+func (s *ReceiptService) GetShopReceipts(shopID int, withItems bool) ([]models.Receipt, error) {
+receipts, err := s.store.Receipts().ListByShop(shopID)
 if err != nil {
 return nil, err
 }
-receipts = append(receipts, receipt)
+return s.withItems(receipts, withItems)
 }
 
+func (s *ReceiptService) withItems(receipts []models.Receipt, load bool) ([]models.Receipt, error) {
+if !load {
+return receipts, nil
+}
+if err := s.store.Receipts().LoadItems(receipts); err != nil {
+return nil, err
+}
 return receipts, nil
 }
 
-func (s *ReceiptService) GetShopReceipts(shopID int) ([]models.Receipt, error) {
-rows, err := s.db.DB.Query(`
-SELECT r.id, r.user_id, r.shop_id, r.total_amount, r.points_earned, r.created_at
-FROM receipts r WHERE r.shop_id = ? ORDER BY r.created_at DESC`,
-shopID)
+// GetReceipt returns a receipt with its items and refunds.
+func (s *ReceiptService) GetReceipt(id int) (*models.Receipt, error) {
+receipt, err := getReceipt(s.store, id)
 if err != nil {
 return nil, err
 }
-defer rows.Close()
 
-var receipts []models.Receipt
-for rows.Next() {
-var receipt models.Receipt
-err := rows.Scan(&receipt.ID, &receipt.UserID, &receipt.ShopID, 
-&receipt.TotalAmount, &receipt.PointsEarned, &receipt.CreatedAt)
+receipt.Refunds, err = s.store.Receipts().ListRefunds(id)
 if err != nil {
 return nil, err
 }
-receipts = append(receipts, receipt)
+return receipt, nil
 }
 
-return receipts, nil
+func getReceipt(store repository.Store, id int) (*models.Receipt, error) {
+receipt, err := store.Receipts().GetByID(id)
+if errors.Is(err, sql.ErrNoRows) {
+return nil, fmt.Errorf("receipt %w", ErrNotFound)
+}
+if err != nil {
+return nil, err
 }
 
-func (s *ReceiptService) GetReceipt(id int) (*models.Receipt, error) {
-receipt := &models.Receipt{}
-err := s.db.DB.QueryRow(`
-SELECT id, user_id, shop_id, total_amount, points_earned, created_at
-FROM receipts WHERE id = ?`,
-id).Scan(&receipt.ID, &receipt.UserID, &receipt.ShopID, 
-&receipt.TotalAmount, &receipt.PointsEarned, &receipt.CreatedAt)
+receipts := []models.Receipt{*receipt}
+if err := store.Receipts().LoadItems(receipts); err != nil {
+return nil, err
+}
+
+return &receipts[0], nil
+}
+
+// RefundItems gives back part of a receipt. The points it earned are
+// reversed in proportion to the amount refunded so far, so refunding every
+// line reverses exactly what was earned, except that a reversal stops at
+// the customer's balance rather than making it negative. Challenge bonuses
+// are kept.
+func (s *ReceiptService) RefundItems(receiptID int, req models.RefundRequest) (*models.Receipt, error) {
+return s.refund(receiptID, req.Reason, func(receipt *models.Receipt) ([]models.RefundLine, error) {
+items := make(map[int]models.ReceiptItem, len(receipt.Items))
+for _, item := range receipt.Items {
+items[item.ID] = item
+}
+
+seen := make(map[int]bool, len(req.Items))
+for i, line := range req.Items {
+item, ok := items[line.ItemID]
+switch {
+case !ok:
+return nil, NewValidationError(fmt.Sprintf("items[%d].item_id", i), "is not on this receipt")
+case seen[line.ItemID]:
+return nil, NewValidationError(fmt.Sprintf("items[%d].item_id", i), "appears more than once")
+case line.Quantity > item.Quantity-item.RefundedQuantity:
+return nil, NewValidationError(fmt.Sprintf("items[%d].quantity", i),
+fmt.Sprintf("must be at most %d", item.Quantity-item.RefundedQuantity))
+}
+seen[line.ItemID] = true
+}
+return req.Items, nil
+})
+}
+
+// VoidReceipt refunds every line not refunded yet and reverses the rest of
+// the points the receipt earned, as far as the customer's balance allows.
+func (s *ReceiptService) VoidReceipt(receiptID int) (*models.Receipt, error) {
+return s.refund(receiptID, "Receipt voided", func(receipt *models.Receipt) ([]models.RefundLine, error) {
+var lines []models.RefundLine
+for _, item := range receipt.Items {
+if remaining := item.Quantity - item.RefundedQuantity; remaining > 0 {
+lines = append(lines, models.RefundLine{ItemID: item.ID, Quantity: remaining})
+}
+}
+return lines, nil
+})
+}
 
+// refund records the lines chosen by pick, reverses points and updates the
+// receipt status in one transaction, and returns the updated receipt.
+func (s *ReceiptService) refund(receiptID int, reason string, pick func(*models.Receipt) ([]models.RefundLine, error)) (*models.Receipt, error) {
+err := s.store.WithTx(func(tx repository.Store) error {
+receipt, err := getReceipt(tx, receiptID)
 if err != nil {
-return nil, errors.New("receipt not found")
+return err
+}
+if receipt.Status == models.ReceiptVoided {
+return fmt.Errorf("receipt %w", ErrVoided)
 }
 
-return receipt, nil
+lines, err := pick(receipt)
+if err != nil {
+return err
+}
+
+refund := &models.ReceiptRefund{
+ReceiptID: receipt.ID,
+Amount:    models.Money{Currency: receipt.Currency},
+Reason:    reason,
+Items:     lines,
+}
+
+prices := make(map[int]models.Money, len(receipt.Items))
+remaining := 0
+for _, item := range receipt.Items {
+prices[item.ID] = item.Price
+remaining += item.Quantity - item.RefundedQuantity
+}
+for _, line := range lines {
+refund.Amount, err = refund.Amount.Plus(prices[line.ItemID].Times(line.Quantity))
+if err != nil {
+return err
+}
+remaining -= line.Quantity
 }
 
-func (s *ReceiptService) GetUserChallenges(userID int) ([]models.Challenge, error) {
-// Get user's receipts to calculate progress
-receipts, _ := s.GetUserReceipts(userID)
-
-challenges := []models.Challenge{
-{
-ID:          1,
-Name:        "Eco-Friendly Shopping",
-Description: "Buy 5 organic products",
-Icon:        "",
-Target:      5,
-Progress:    0,
-Earned:      false,
-},
-{
-ID:          2,
-Name:        "Fruit & Veggie Lover",
-Description: "Purchase 10 fruits/vegetables",
-Icon:        "",
-Target:      10,
-Progress:    0,
-Earned:      false,
-},
-{
-ID:          3,
-Name:        "Sustainable Living",
-Description: "Buy 3 eco-friendly items",
-Icon:        "",
-Target:      3,
-Progress:    0,
-Earned:      false,
-},
-{
-ID:          4,
-Name:        "Local Business Support",
-Description: "Shop at 2 local stores",
-Icon:        "",
-Target:      2,
-Progress:    0,
-Earned:      false,
-},
-}
-
-// Calculate progress based on receipts
-organicCount := 0
-produceCount := 0
-ecoFriendlyCount := 0
-uniqueShops := make(map[int]bool)
-
-for _, receipt := range receipts {
-uniqueShops[receipt.ShopID] = true
-// Note: We would need to fetch receipt items to calculate category-based progress
-// For now, we'll use a simplified calculation
-}
-
-// Update challenge progress
-challenges[0].Progress = organicCount
-challenges[0].Earned = organicCount >= challenges[0].Target
-
-challenges[1].Progress = produceCount
-challenges[1].Earned = produceCount >= challenges[1].Target
-
-challenges[2].Progress = ecoFriendlyCount
-challenges[2].Earned = ecoFriendlyCount >= challenges[2].Target
-
-challenges[3].Progress = len(uniqueShops)
-challenges[3].Earned = len(uniqueShops) >= challenges[3].Target
-
-return challenges, nil
-}
-
-func (s *ReceiptService) DeleteReceipt(receiptID int) error {
-	// First, get the receipt to know how many points to subtract
-	var userID int
-	var pointsEarned int
-	err := s.db.DB.QueryRow(`
-		SELECT user_id, points_earned FROM receipts WHERE id = ?`,
-		receiptID).Scan(&userID, &pointsEarned)
-	if err != nil {
-		return errors.New("receipt not found")
-	}
-
-	// Delete receipt items first (foreign key constraint)
-	_, err = s.db.DB.Exec(`DELETE FROM receipt_items WHERE receipt_id = ?`, receiptID)
-	if err != nil {
-		return err
-	}
-
-	// Delete the receipt
-	_, err = s.db.DB.Exec(`DELETE FROM receipts WHERE id = ?`, receiptID)
-	if err != nil {
-		return err
-	}
-
-	// Note: We don't subtract points when deleting receipts to prevent negative points
-	// Points remain with the customer as a reward for their past eco-friendly purchases
-
-	return nil
+// Reverse the points earned on everything refunded so far, less what
+// earlier refunds already reversed, rounding to the nearest point. Once
+// nothing is left every point is reversed; legacy receipts with a zero
+// total have no proportion to reverse before that.
+status := models.ReceiptVoided
+target := int64(receipt.PointsEarned)
+if remaining > 0 {
+status = models.ReceiptPartiallyRefunded
+target = int64(receipt.PointsReversed)
+if total := receipt.TotalAmount.Amount; total > 0 {
+refunded := receipt.RefundedAmount.Amount + refund.Amount.Amount
+target = (int64(receipt.PointsEarned)*refunded*2 + total) / (total * 2)
+}
+}
+refund.PointsReversed = int(target) - receipt.PointsReversed
+
+// A reversal never takes the balance below zero: points already spent
+// stay spent. What is left unreversed is caught up by later refunds of
+// the receipt, as far as the balance then allows.
+customer, err := tx.Users().GetByID(receipt.UserID)
+if err != nil {
+return err
+}
+refund.PointsReversed = min(refund.PointsReversed, max(customer.Points, 0))
+
+err = tx.Receipts().AddRefund(refund, status)
+if errors.Is(err, repository.ErrOverRefund) {
+return fmt.Errorf("receipt %w", ErrConcurrentUpdate)
+}
+if err != nil {
+return err
+}
+
+if refund.PointsReversed <= 0 {
+return nil
+}
+reversed, err := tx.Points().Spend(&models.PointsEntry{
+UserID:    receipt.UserID,
+Kind:      models.PointsReversal,
+Amount:    -refund.PointsReversed,
+Reason:    fmt.Sprintf("%s on receipt #%d", refundReason(status), receipt.ID),
+ReceiptID: &receipt.ID,
+})
+if err != nil {
+return err
+}
+if !reversed {
+return fmt.Errorf("points balance %w", ErrConcurrentUpdate)
+}
+return nil
+})
+if err != nil {
+return nil, err
+}
+
+return s.GetReceipt(receiptID)
+}
+
+func refundReason(status string) string {
+if status == models.ReceiptVoided {
+return "Void"
+}
+return "Refund"
+}
+
+// receiptItems resolves the lines of a new receipt against the shop's
+// catalogue, after checking that the customer and the shop exist.
+func receiptItems(store repository.Store, receiptCreate models.ReceiptCreate) ([]models.ReceiptItem, error) {
+if _, err := store.Users().GetByID(receiptCreate.UserID); errors.Is(err, sql.ErrNoRows) {
+return nil, NewValidationError("user_id", "does not exist")
+} else if err != nil {
+return nil, err
+}
+if _, err := store.Shops().GetByID(receiptCreate.ShopID); errors.Is(err, sql.ErrNoRows) {
+return nil, NewValidationError("shop_id", "does not exist")
+} else if err != nil {
+return nil, err
+}
+
+// Lines name their item by ID or by barcode, not both
+ids := make([]int, 0, len(receiptCreate.Items))
+var barcodes []string
+for i, line := range receiptCreate.Items {
+field := fmt.Sprintf("items[%d]", i)
+switch {
+case line.ItemID != 0 && line.Barcode != "":
+return nil, NewValidationError(field+".barcode", "cannot be given with item_id")
+case line.ItemID != 0:
+ids = append(ids, line.ItemID)
+case line.Barcode != "":
+barcode, _ := models.CanonicalBarcode(line.Barcode)
+barcodes = append(barcodes, barcode)
+default:
+return nil, NewValidationError(field+".item_id", "or barcode is required")
+}
+}
+catalogue, err := store.Shops().ItemsByID(receiptCreate.ShopID, ids)
+if err != nil {
+return nil, err
+}
+byBarcode, err := store.Shops().ItemsByBarcode(receiptCreate.ShopID, barcodes)
+if err != nil {
+return nil, err
+}
+
+// The receipt is in the currency of its items
+currency := receiptCreate.Currency
+items := make([]models.ReceiptItem, 0, len(receiptCreate.Items))
+for i, line := range receiptCreate.Items {
+field := fmt.Sprintf("items[%d].item_id", i)
+entry, ok := catalogue[line.ItemID]
+if line.Barcode != "" {
+barcode, _ := models.CanonicalBarcode(line.Barcode)
+field = fmt.Sprintf("items[%d].barcode", i)
+entry, ok = byBarcode[barcode]
+}
+if !ok {
+return nil, NewValidationError(field, "is not in the shop's catalogue")
+}
+if entry.ArchivedAt != nil {
+return nil, NewValidationError(field, "is no longer sold")
+}
+if currency == "" {
+currency = entry.Currency
+}
+if entry.Currency != currency {
+return nil, NewValidationError(field, "is priced in "+entry.Currency+", not "+currency)
+}
+
+itemID, listPrice := entry.ID, entry.Price
+item := models.ReceiptItem{
+ItemID:        &itemID,
+Name:          entry.Name,
+Price:         listPrice,
+ListPrice:     &listPrice,
+Quantity:      line.Quantity,
+Category:      entry.Category,
+IsEcoFriendly: entry.IsEcoFriendly || models.IsEcoCategory(entry.Category),
+}
+if line.Price != nil {
+price, err := priceIn(*line.Price, currency, fmt.Sprintf("items[%d].price", i))
+if err != nil {
+return nil, err
+}
+item.Price = price
+item.PriceOverride = price.Amount != listPrice.Amount
+}
+items = append(items, item)
+}
+
+return items, nil
+}
+
+// priceIn gives a price read without a currency the given one, and rejects
+// a price in another currency.
+func priceIn(price models.Money, currency, field string) (models.Money, error) {
+if price.Currency != "" && price.Currency != currency {
+return models.Money{}, NewValidationError(field, "must be in "+currency)
+}
+price.Currency = currency
+return price, nil
 }
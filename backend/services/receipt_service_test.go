@@ -0,0 +1,153 @@
+package services
+
+import (
+	"ecotracker-backend/models"
+	"testing"
+	"time"
+)
+
+// Receipts from before prices were required can have a zero total; voiding
+// or refunding them must not divide by it.
+func TestRefundZeroTotalReceipt(t *testing.T) {
+	tests := []struct {
+		name       string
+		items      []models.ReceiptItem
+		refund     func(s *ReceiptService, receipt *models.Receipt) (*models.Receipt, error)
+		wantStatus string
+		wantPoints int
+	}{
+		{
+			name:  "void",
+			items: []models.ReceiptItem{{Name: "Free sample", Quantity: 2, Category: "General"}},
+			refund: func(s *ReceiptService, receipt *models.Receipt) (*models.Receipt, error) {
+				return s.VoidReceipt(receipt.ID)
+			},
+			wantStatus: models.ReceiptVoided,
+			wantPoints: 5,
+		},
+		{
+			name:  "void without items",
+			items: nil,
+			refund: func(s *ReceiptService, receipt *models.Receipt) (*models.Receipt, error) {
+				return s.VoidReceipt(receipt.ID)
+			},
+			wantStatus: models.ReceiptVoided,
+			wantPoints: 5,
+		},
+		{
+			name:  "partial refund",
+			items: []models.ReceiptItem{{Name: "Free sample", Quantity: 2, Category: "General"}},
+			refund: func(s *ReceiptService, receipt *models.Receipt) (*models.Receipt, error) {
+				return s.RefundItems(receipt.ID, models.RefundRequest{
+					Items: []models.RefundLine{{ItemID: receipt.Items[0].ID, Quantity: 1}},
+				})
+			},
+			wantStatus: models.ReceiptPartiallyRefunded,
+			wantPoints: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newTestStore(t)
+			customer := newTestCustomer(t, store, "c@example.com")
+			shop := newTestShop(t, store, "s@example.com")
+			service := NewReceiptService(store, NewPointsRulesService(store, models.DefaultPointsRules()), time.Hour)
+
+			receipt := &models.Receipt{
+				UserID:       customer.ID,
+				ShopID:       shop.ID,
+				Currency:     models.DefaultCurrency,
+				TotalAmount:  models.Money{Currency: models.DefaultCurrency},
+				PointsEarned: 5,
+				Items:        tt.items,
+			}
+			for i := range receipt.Items {
+				receipt.Items[i].Price = models.Money{Currency: models.DefaultCurrency}
+			}
+			if err := store.Receipts().Create(receipt); err != nil {
+				t.Fatal(err)
+			}
+			err := store.Points().Append(&models.PointsEntry{
+				UserID: customer.ID, Kind: models.PointsEarn, Amount: 5, ReceiptID: &receipt.ID,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			refunded, err := tt.refund(service, receipt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if refunded.Status != tt.wantStatus {
+				t.Errorf("status = %q, want %q", refunded.Status, tt.wantStatus)
+			}
+			if refunded.PointsReversed != tt.wantPoints {
+				t.Errorf("points reversed = %d, want %d", refunded.PointsReversed, tt.wantPoints)
+			}
+		})
+	}
+}
+
+// Reversing points never makes a balance negative; what a refund could not
+// reverse is reversed by a later refund once the customer has the points.
+func TestRefundReversalStopsAtBalance(t *testing.T) {
+	store := newTestStore(t)
+	customer := newTestCustomer(t, store, "c@example.com")
+	shop := newTestShop(t, store, "s@example.com")
+	service := NewReceiptService(store, NewPointsRulesService(store, models.DefaultPointsRules()), time.Hour)
+
+	price := models.Money{Amount: 500, Currency: models.DefaultCurrency}
+	receipt := &models.Receipt{
+		UserID:       customer.ID,
+		ShopID:       shop.ID,
+		Currency:     models.DefaultCurrency,
+		TotalAmount:  models.Money{Amount: 1000, Currency: models.DefaultCurrency},
+		PointsEarned: 10,
+		Items:        []models.ReceiptItem{{Name: "Soap", Price: price, Quantity: 2, Category: "General"}},
+	}
+	if err := store.Receipts().Create(receipt); err != nil {
+		t.Fatal(err)
+	}
+	entries := []*models.PointsEntry{
+		{UserID: customer.ID, Kind: models.PointsEarn, Amount: 10, ReceiptID: &receipt.ID},
+		{UserID: customer.ID, Kind: models.PointsRedeem, Amount: -8},
+	}
+	for _, entry := range entries {
+		if err := store.Points().Append(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	balance := func() int {
+		t.Helper()
+		user, err := store.Users().GetByID(customer.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return user.Points
+	}
+
+	// Half the receipt is due 5 points back, but only 2 are left
+	refunded, err := service.RefundItems(receipt.ID, models.RefundRequest{
+		Items: []models.RefundLine{{ItemID: receipt.Items[0].ID, Quantity: 1}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refunded.PointsReversed != 2 || balance() != 0 {
+		t.Errorf("after the refund: reversed %d with balance %d, want 2 and 0", refunded.PointsReversed, balance())
+	}
+
+	err = store.Points().Append(&models.PointsEntry{UserID: customer.ID, Kind: models.PointsAdjust, Amount: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	voided, err := service.VoidReceipt(receipt.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if voided.PointsReversed != 10 || balance() != 2 {
+		t.Errorf("after the void: reversed %d with balance %d, want 10 and 2", voided.PointsReversed, balance())
+	}
+}
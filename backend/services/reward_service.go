@@ -0,0 +1,229 @@
+package services
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"ecotracker-backend/models"
+	"ecotracker-backend/repository"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type RewardService struct {
+	store repository.Store
+}
+
+func NewRewardService(store repository.Store) *RewardService {
+	return &RewardService{store: store}
+}
+
+// ListRewards returns the rewards valid at shopID, or every reward when
+// shopID is 0. Inactive rewards are only listed when includeInactive is true.
+func (s *RewardService) ListRewards(shopID int, includeInactive bool) ([]models.Reward, error) {
+	return s.store.Rewards().List(shopID, includeInactive)
+}
+
+func (s *RewardService) GetReward(id int) (*models.Reward, error) {
+	reward, err := s.store.Rewards().GetByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("reward %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return reward, nil
+}
+
+func (s *RewardService) CreateReward(def models.RewardDefinition) (*models.Reward, error) {
+	reward := &models.Reward{}
+	if err := s.applyRewardDefinition(reward, def); err != nil {
+		return nil, err
+	}
+
+	if err := s.store.Rewards().Create(reward); err != nil {
+		return nil, err
+	}
+	return reward, nil
+}
+
+// UpdateReward replaces the definition of a reward. Codes already issued
+// for it stay valid.
+func (s *RewardService) UpdateReward(id int, def models.RewardDefinition) (*models.Reward, error) {
+	reward, err := s.GetReward(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.applyRewardDefinition(reward, def); err != nil {
+		return nil, err
+	}
+
+	if err := s.store.Rewards().Update(reward); err != nil {
+		return nil, err
+	}
+	return reward, nil
+}
+
+func (s *RewardService) applyRewardDefinition(reward *models.Reward, def models.RewardDefinition) error {
+	if def.ShopID != 0 {
+		if _, err := s.store.Shops().GetByID(def.ShopID); errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("shop %w", ErrNotFound)
+		} else if err != nil {
+			return err
+		}
+	}
+
+	reward.ShopID = def.ShopID
+	reward.Name = def.Name
+	reward.Description = def.Description
+	reward.PointsCost = def.PointsCost
+	reward.Stock = def.Stock
+	reward.Active = def.Active == nil || *def.Active
+	return nil
+}
+
+// Redeem spends the user's points on a reward and issues the one-time code
+// they show at checkout. Taking the reward out of stock, debiting the points
+// and issuing the code happen in one transaction, so a failure leaves none
+// of them behind.
+func (s *RewardService) Redeem(userID, rewardID int) (*models.Redemption, error) {
+	var redemption *models.Redemption
+	err := s.store.WithTx(func(tx repository.Store) error {
+		reward, err := tx.Rewards().GetByID(rewardID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("reward %w", ErrNotFound)
+		}
+		if err != nil {
+			return err
+		}
+
+		taken, err := tx.Rewards().TakeStock(rewardID)
+		if err != nil {
+			return err
+		}
+		if !taken {
+			return fmt.Errorf("reward %w", ErrUnavailable)
+		}
+
+		code, err := newRedemptionCode()
+		if err != nil {
+			return err
+		}
+
+		redemption = &models.Redemption{
+			RewardID:    reward.ID,
+			RewardName:  reward.Name,
+			ShopID:      reward.ShopID,
+			UserID:      userID,
+			Code:        code,
+			PointsSpent: reward.PointsCost,
+			Status:      models.RedemptionIssued,
+		}
+		if err := tx.Rewards().CreateRedemption(redemption); err != nil {
+			return err
+		}
+
+		spent, err := tx.Points().Spend(&models.PointsEntry{
+			UserID:       userID,
+			Kind:         models.PointsRedeem,
+			Amount:       -reward.PointsCost,
+			Reason:       "Redeemed " + reward.Name,
+			RedemptionID: &redemption.ID,
+		})
+		if err != nil {
+			return err
+		}
+		if !spent {
+			return ErrInsufficientPoints
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return redemption, nil
+}
+
+// GetUserRedemptions returns the user's redemptions, newest first.
+func (s *RewardService) GetUserRedemptions(userID int) ([]models.Redemption, error) {
+	return s.store.Rewards().ListRedemptions(userID)
+}
+
+// GetRedemption looks a code up as typed at checkout: case and dashes are
+// not significant.
+func (s *RewardService) GetRedemption(code string) (*models.Redemption, error) {
+	redemption, err := s.store.Rewards().GetRedemptionByCode(normalizeRedemptionCode(code))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("redemption code %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return redemption, nil
+}
+
+// VerifyRedemption returns the redemption for code if it can still be
+// consumed.
+func (s *RewardService) VerifyRedemption(code string) (*models.Redemption, error) {
+	redemption, err := s.GetRedemption(code)
+	if err != nil {
+		return nil, err
+	}
+	if redemption.Status != models.RedemptionIssued {
+		return nil, fmt.Errorf("redemption code %w", ErrUnavailable)
+	}
+
+	return redemption, nil
+}
+
+// ConsumeRedemption marks the redemption for code used at shopID (0 when an
+// admin consumes it). A code can be consumed once.
+func (s *RewardService) ConsumeRedemption(code string, shopID int) (*models.Redemption, error) {
+	redemption, err := s.GetRedemption(code)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	consumed, err := s.store.Rewards().ConsumeRedemption(redemption.ID, shopID, now)
+	if err != nil {
+		return nil, err
+	}
+	if !consumed {
+		return nil, fmt.Errorf("redemption code %w", ErrUnavailable)
+	}
+
+	redemption.Status = models.RedemptionConsumed
+	redemption.ConsumedAt = &now
+	redemption.ConsumedByShopID = shopID
+	return redemption, nil
+}
+
+// redemptionAlphabet leaves out characters that are easily misread, such as
+// 0/O and 1/I.
+const redemptionAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// newRedemptionCode returns a random code of the form XXXX-XXXX.
+func newRedemptionCode() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	for i := range b {
+		b[i] = redemptionAlphabet[int(b[i])%len(redemptionAlphabet)]
+	}
+	return string(b[:4]) + "-" + string(b[4:]), nil
+}
+
+func normalizeRedemptionCode(code string) string {
+	code = strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+	if len(code) == 8 {
+		code = code[:4] + "-" + code[4:]
+	}
+	return code
+}
@@ -0,0 +1,234 @@
+package services
+
+import (
+	"ecotracker-backend/models"
+	"ecotracker-backend/repository"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// newTestReward defines a reward at shopID; a negative stock means
+// unlimited.
+func newTestReward(t *testing.T, rewards *RewardService, shopID, cost, stock int) *models.Reward {
+	t.Helper()
+
+	def := models.RewardDefinition{ShopID: shopID, Name: "Tote bag", PointsCost: cost}
+	if stock >= 0 {
+		def.Stock = &stock
+	}
+	reward, err := rewards.CreateReward(def)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reward
+}
+
+func givePoints(t *testing.T, store repository.Store, userID, amount int) {
+	t.Helper()
+
+	entry := &models.PointsEntry{UserID: userID, Kind: models.PointsAdjust, Amount: amount, Reason: "test"}
+	if err := store.Points().Append(entry); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// balanceAndStock returns the customer's balance and the reward's stock.
+func balanceAndStock(t *testing.T, store repository.Store, userID, rewardID int) (int, int) {
+	t.Helper()
+
+	user, err := store.Users().GetByID(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reward, err := store.Rewards().GetByID(rewardID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reward.Stock == nil {
+		return user.Points, -1
+	}
+	return user.Points, *reward.Stock
+}
+
+func TestRedeem(t *testing.T) {
+	store := newTestStore(t)
+	customer := newTestCustomer(t, store, "c@example.com")
+	shop := newTestShop(t, store, "s@example.com")
+	rewards := NewRewardService(store)
+	givePoints(t, store, customer.ID, 100)
+	reward := newTestReward(t, rewards, shop.ID, 30, 2)
+
+	redemption, err := rewards.Redeem(customer.ID, reward.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if redemption.Status != models.RedemptionIssued || redemption.PointsSpent != 30 || redemption.ShopID != shop.ID {
+		t.Errorf("redemption = %+v, want an issued code for 30 points at shop %d", redemption, shop.ID)
+	}
+	if balance, stock := balanceAndStock(t, store, customer.ID, reward.ID); balance != 70 || stock != 1 {
+		t.Errorf("balance %d, stock %d; want 70 and 1", balance, stock)
+	}
+
+	history, err := store.Points().History(customer.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry := history[0]; entry.Kind != models.PointsRedeem || entry.Amount != -30 ||
+		entry.RedemptionID == nil || *entry.RedemptionID != redemption.ID {
+		t.Errorf("latest ledger entry = %+v, want -30 for redemption %d", entry, redemption.ID)
+	}
+}
+
+// A redemption that fails leaves the points, the stock and the
+// redemptions as they were.
+func TestRedeemFails(t *testing.T) {
+	inactive := false
+
+	tests := []struct {
+		name    string
+		points  int
+		stock   int
+		define  func(*models.RewardDefinition)
+		missing bool
+		want    error
+	}{
+		{name: "not enough points", points: 29, stock: 5, want: ErrInsufficientPoints},
+		{name: "out of stock", points: 100, stock: 0, want: ErrUnavailable},
+		{name: "inactive", points: 100, stock: 5, define: func(def *models.RewardDefinition) { def.Active = &inactive }, want: ErrUnavailable},
+		{name: "no such reward", points: 100, stock: 5, missing: true, want: ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newTestStore(t)
+			customer := newTestCustomer(t, store, "c@example.com")
+			shop := newTestShop(t, store, "s@example.com")
+			rewards := NewRewardService(store)
+			givePoints(t, store, customer.ID, tt.points)
+
+			def := models.RewardDefinition{ShopID: shop.ID, Name: "Tote bag", PointsCost: 30, Stock: &tt.stock}
+			if tt.define != nil {
+				tt.define(&def)
+			}
+			reward, err := rewards.CreateReward(def)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rewardID := reward.ID
+			if tt.missing {
+				rewardID += 100
+			}
+			if _, err := rewards.Redeem(customer.ID, rewardID); !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+
+			if balance, stock := balanceAndStock(t, store, customer.ID, reward.ID); balance != tt.points || stock != tt.stock {
+				t.Errorf("balance %d, stock %d; want %d and %d unchanged", balance, stock, tt.points, tt.stock)
+			}
+			if redemptions, err := rewards.GetUserRedemptions(customer.ID); err != nil || len(redemptions) != 0 {
+				t.Errorf("redemptions = %+v, %v; want none", redemptions, err)
+			}
+		})
+	}
+}
+
+// Concurrent redemptions never take more stock or points than there are,
+// and each one that succeeds takes both.
+func TestRedeemConcurrently(t *testing.T) {
+	const attempts = 8
+
+	tests := []struct {
+		name   string
+		points int
+		stock  int
+		want   int
+	}{
+		{"limited by stock", attempts * 10, 3, 3},
+		{"limited by points", 50, -1, 5},
+		{"unlimited", attempts * 10, -1, attempts},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newTestStore(t)
+			customer := newTestCustomer(t, store, "c@example.com")
+			shop := newTestShop(t, store, "s@example.com")
+			rewards := NewRewardService(store)
+			givePoints(t, store, customer.ID, tt.points)
+			reward := newTestReward(t, rewards, shop.ID, 10, tt.stock)
+
+			var wg sync.WaitGroup
+			errs := make(chan error, attempts)
+			for range attempts {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, err := rewards.Redeem(customer.ID, reward.ID)
+					errs <- err
+				}()
+			}
+			wg.Wait()
+			close(errs)
+
+			redeemed := 0
+			for err := range errs {
+				switch {
+				case err == nil:
+					redeemed++
+				case !errors.Is(err, ErrUnavailable) && !errors.Is(err, ErrInsufficientPoints):
+					t.Errorf("unexpected error: %v", err)
+				}
+			}
+			if redeemed != tt.want {
+				t.Errorf("%d redemptions succeeded, want %d", redeemed, tt.want)
+			}
+
+			wantStock := tt.stock
+			if wantStock >= 0 {
+				wantStock -= redeemed
+			}
+			if balance, stock := balanceAndStock(t, store, customer.ID, reward.ID); balance != tt.points-10*redeemed || stock != wantStock {
+				t.Errorf("balance %d, stock %d; want %d and %d", balance, stock, tt.points-10*redeemed, wantStock)
+			}
+			if redemptions, err := rewards.GetUserRedemptions(customer.ID); err != nil || len(redemptions) != redeemed {
+				t.Errorf("got %d redemptions, %v; want %d", len(redemptions), err, redeemed)
+			}
+		})
+	}
+}
+
+// A code is accepted however it is typed, and only once.
+func TestConsumeRedemption(t *testing.T) {
+	store := newTestStore(t)
+	customer := newTestCustomer(t, store, "c@example.com")
+	shop := newTestShop(t, store, "s@example.com")
+	rewards := NewRewardService(store)
+	givePoints(t, store, customer.ID, 100)
+	reward := newTestReward(t, rewards, shop.ID, 30, -1)
+
+	redemption, err := rewards.Redeem(customer.ID, reward.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// "ABCD-EFGH" typed as " abcd efgh "
+	typed := strings.ToLower(" " + redemption.Code[:4] + " " + redemption.Code[5:] + " ")
+
+	if _, err := rewards.VerifyRedemption(typed); err != nil {
+		t.Fatalf("verifying %q: %v", typed, err)
+	}
+	consumed, err := rewards.ConsumeRedemption(typed, shop.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if consumed.Status != models.RedemptionConsumed || consumed.ConsumedByShopID != shop.ID {
+		t.Errorf("redemption = %+v, want it consumed by shop %d", consumed, shop.ID)
+	}
+
+	if _, err := rewards.ConsumeRedemption(redemption.Code, shop.ID); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("consuming twice: err = %v, want ErrUnavailable", err)
+	}
+	if _, err := rewards.VerifyRedemption(redemption.Code); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("verifying a consumed code: err = %v, want ErrUnavailable", err)
+	}
+}
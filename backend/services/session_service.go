@@ -0,0 +1,168 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"ecotracker-backend/auth"
+	"ecotracker-backend/models"
+	"ecotracker-backend/repository"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+type SessionService struct {
+	store      repository.Store
+	signer     *auth.TokenSigner
+	refreshTTL time.Duration
+}
+
+func NewSessionService(store repository.Store, signer *auth.TokenSigner, refreshTTL time.Duration) *SessionService {
+	return &SessionService{store: store, signer: signer, refreshTTL: refreshTTL}
+}
+
+// Create opens a new session for the principal and returns its first token pair.
+func (s *SessionService) Create(principal auth.Principal) (*models.TokenPair, error) {
+	refreshToken, err := newRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	session := &models.Session{
+		PrincipalKind: principal.Kind,
+		PrincipalID:   principal.ID,
+		ExpiresAt:     time.Now().UTC().Add(s.refreshTTL),
+		CreatedAt:     time.Now().UTC(),
+	}
+	if err := s.store.Sessions().Create(session, hashRefreshToken(refreshToken)); err != nil {
+		return nil, err
+	}
+
+	return s.tokenPair(principal, session.ID, refreshToken)
+}
+
+// Refresh exchanges a refresh token for a new token pair. The refresh token
+// is rotated, so each one can only be used once.
+func (s *SessionService) Refresh(refreshToken string) (*models.TokenPair, error) {
+	session, err := s.findActive(refreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	newToken, err := newRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	rotated, err := s.store.Sessions().Rotate(session.ID, hashRefreshToken(refreshToken),
+		hashRefreshToken(newToken), time.Now().UTC().Add(s.refreshTTL))
+	if err != nil {
+		return nil, err
+	}
+
+	// A concurrent refresh already rotated this token
+	if !rotated {
+		return nil, ErrInvalidToken
+	}
+
+	principal, err := s.currentPrincipal(session)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.tokenPair(principal, session.ID, newToken)
+}
+
+// currentPrincipal reloads the session owner's role so that promotions and
+// demotions apply from the next refresh on.
+func (s *SessionService) currentPrincipal(session *models.Session) (auth.Principal, error) {
+	principal := auth.Principal{Kind: session.PrincipalKind, ID: session.PrincipalID}
+
+	var role string
+	if principal.IsUser() {
+		user, err := s.store.Users().GetByID(principal.ID)
+		if err != nil {
+			return principal, ErrInvalidToken
+		}
+		role = user.Role
+	}
+
+	principal.Role = auth.RoleFor(principal.Kind, role)
+	return principal, nil
+}
+
+// Logout revokes the session the refresh token belongs to; its access
+// tokens are rejected from then on.
+func (s *SessionService) Logout(refreshToken string) error {
+	session, err := s.findActive(refreshToken)
+	if err != nil {
+		return err
+	}
+
+	return s.store.Sessions().Revoke(session.ID, time.Now().UTC())
+}
+
+// Active reports whether the session is still open, so that the access
+// tokens of a session that was logged out stop working.
+func (s *SessionService) Active(sessionID int) (bool, error) {
+	session, err := s.store.Sessions().GetByID(sessionID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return isOpen(session), nil
+}
+
+func (s *SessionService) findActive(refreshToken string) (*models.Session, error) {
+	session, err := s.store.Sessions().FindByTokenHash(hashRefreshToken(refreshToken))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !isOpen(session) {
+		return nil, ErrInvalidToken
+	}
+
+	return session, nil
+}
+
+func isOpen(session *models.Session) bool {
+	return session.RevokedAt == nil && time.Now().Before(session.ExpiresAt)
+}
+
+func (s *SessionService) tokenPair(principal auth.Principal, sessionID int, refreshToken string) (*models.TokenPair, error) {
+	accessToken, _, err := s.signer.Sign(principal, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(s.signer.TTL().Seconds()),
+	}, nil
+}
+
+func newRefreshToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// Only a digest of the refresh token is stored, so a leaked database cannot
+// be used to mint sessions.
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"ecotracker-backend/auth"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestSessions(t *testing.T) (*SessionService, *auth.TokenSigner, auth.Principal) {
+	t.Helper()
+
+	store := newTestStore(t)
+	customer := newTestCustomer(t, store, "c@example.com")
+	signer := auth.NewTokenSigner([]byte("secret"), time.Hour)
+	sessions := NewSessionService(store, signer, 24*time.Hour)
+	signer.CheckSessions(sessions.Active)
+	return sessions, signer, auth.Principal{Kind: auth.KindUser, ID: customer.ID, Role: auth.RoleCustomer}
+}
+
+// A refresh token is exchanged for a new pair once; the old one is then
+// refused, and the session goes on with the new one.
+func TestRefreshRotatesToken(t *testing.T) {
+	sessions, signer, principal := newTestSessions(t)
+
+	first, err := sessions.Create(principal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := sessions.Refresh(first.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Fatal("refresh token was not rotated")
+	}
+
+	claims, err := signer.Verify(second.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Subject != principal.ID || claims.Kind != principal.Kind || claims.Role != principal.Role {
+		t.Errorf("claims = %+v, want those of %+v", claims, principal)
+	}
+
+	if _, err := sessions.Refresh(first.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("reusing the old refresh token: err = %v, want ErrInvalidToken", err)
+	}
+	if _, err := sessions.Refresh(second.RefreshToken); err != nil {
+		t.Errorf("refreshing with the new token: %v", err)
+	}
+}
+
+// Logging out refuses the session's refresh token and the access tokens
+// already issued for it, but leaves other sessions alone.
+func TestLogoutClosesSession(t *testing.T) {
+	sessions, signer, principal := newTestSessions(t)
+
+	pair, err := sessions.Create(principal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := sessions.Create(principal)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sessions.Logout(pair.RefreshToken); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := sessions.Refresh(pair.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("refresh after logout: err = %v, want ErrInvalidToken", err)
+	}
+	if err := sessions.Logout(pair.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("second logout: err = %v, want ErrInvalidToken", err)
+	}
+	if _, err := signer.Verify(pair.AccessToken); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Errorf("access token after logout: err = %v, want auth.ErrInvalidToken", err)
+	}
+	if _, err := signer.Verify(other.AccessToken); err != nil {
+		t.Errorf("access token of another session: %v", err)
+	}
+}
@@ -1,116 +1,166 @@
 ﻿package services
 
 import (
-"ecotracker-backend/database"
+"database/sql"
 "ecotracker-backend/models"
+"ecotracker-backend/repository"
 "errors"
-"strconv"
+"fmt"
 "time"
 )
 
 type ShopService struct {
-db *database.Database
+store  repository.Store
+hasher PasswordHasher
+dummy  dummyPassword
 }
 
-func NewShopService(db *database.Database) *ShopService {
-return &ShopService{db: db}
+func NewShopService(store repository.Store, hasher PasswordHasher) *ShopService {
+return &ShopService{store: store, hasher: hasher}
 }
 
 func (s *ShopService) Register(req models.ShopRegistration) (*models.Shop, error) {
-// Check if shop already exists
-var count int
-err := s.db.DB.QueryRow("SELECT COUNT(*) FROM shops WHERE email = ?", req.Email).Scan(&count)
-if err != nil {
-return nil, err
-}
-if count > 0 {
-return nil, errors.New("shop already exists")
+return s.register(s.store, req)
 }
 
-// Insert new shop
-result, err := s.db.DB.Exec(`
-INSERT INTO shops (email, password, name, address, phone, description, created_at, updated_at) 
-VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
-req.Email, req.Password, req.Name, req.Address, req.Phone, req.Description, time.Now(), time.Now())
+func (s *ShopService) register(store repository.Store, req models.ShopRegistration) (*models.Shop, error) {
+// Check if shop already exists
+exists, err := store.Shops().EmailExists(req.Email)
 if err != nil {
 return nil, err
 }
+if exists {
+return nil, fmt.Errorf("shop %w", ErrConflict)
+}
 
-id, err := result.LastInsertId()
+passwordHash, err := s.hasher.Hash(req.Password)
 if err != nil {
 return nil, err
 }
 
+// Insert new shop
 shop := &models.Shop{
-ID:          int(id),
 Email:       req.Email,
+Password:    passwordHash,
 Name:        req.Name,
 Address:     req.Address,
 Phone:       req.Phone,
 Description: req.Description,
-CreatedAt:   time.Now(),
-UpdatedAt:   time.Now(),
+}
+if err := store.Shops().Create(shop); err != nil {
+return nil, err
 }
 
 return shop, nil
 }
 
 func (s *ShopService) RegisterWithItems(req models.ShopRegistration, items []models.ShopItem) (*models.Shop, error) {
-// First register the shop
-shop, err := s.Register(req)
+var shop *models.Shop
+
+// The shop and its catalogue are created together or not at all
+err := s.store.WithTx(func(tx repository.Store) error {
+var err error
+shop, err = s.register(tx, req)
+if err != nil {
+return err
+}
+
+// Report every invalid item, not just the first
+catalogue := make([]models.CatalogueItem, len(items))
+for i, item := range items {
+catalogue[i] = models.CatalogueItem{ShopItem: item}
+}
+_, err = s.importItems(tx, shop.ID, catalogue, models.ItemImportOptions{})
+return err
+})
 if err != nil {
 return nil, err
 }
 
-// Then add items
-for _, item := range items {
-_, err := s.AddItem(shop.ID, item)
+return shop, nil
+}
+
+func (s *ShopService) Login(req models.ShopLogin) (*models.Shop, error) {
+shop, err := s.store.Shops().GetByEmail(req.Email)
+if errors.Is(err, sql.ErrNoRows) {
+s.dummy.reject(s.hasher, req.Password)
+return nil, ErrInvalidCredentials
+}
 if err != nil {
 return nil, err
 }
+
+ok, rehash, err := verifyPassword(s.hasher, shop.Password, req.Password)
+if err != nil || !ok {
+return nil, ErrInvalidCredentials
+}
+
+// Upgrade legacy plaintext or outdated hashes now that we know the password
+if rehash {
+if err := s.rehashPassword(shop, req.Password); err != nil {
+return nil, err
+}
 }
 
 return shop, nil
 }
 
-func (s *ShopService) Login(req models.ShopLogin) (*models.Shop, error) {
-shop := &models.Shop{}
-err := s.db.DB.QueryRow(`
-SELECT id, email, password, name, address, phone, description, created_at, updated_at 
-FROM shops WHERE email = ? AND password = ?`,
-req.Email, req.Password).Scan(
-&shop.ID, &shop.Email, &shop.Password, &shop.Name, &shop.Address, 
-&shop.Phone, &shop.Description, &shop.CreatedAt, &shop.UpdatedAt)
+func (s *ShopService) rehashPassword(shop *models.Shop, password string) error {
+	passwordHash, err := s.hasher.Hash(password)
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.Shops().SetPassword(shop.ID, shop.Password, passwordHash); err != nil {
+		return err
+	}
+
+	shop.Password = passwordHash
+	return nil
+}
 
+func (s *ShopService) GetShop(shopID int) (*models.Shop, error) {
+shop, err := s.store.Shops().GetByID(shopID)
+if errors.Is(err, sql.ErrNoRows) {
+return nil, fmt.Errorf("shop %w", ErrNotFound)
+}
 if err != nil {
-return nil, errors.New("invalid credentials")
+return nil, err
 }
 
 return shop, nil
 }
 
-func (s *ShopService) GetShop(id string) (*models.Shop, error) {
-shopID, err := strconv.Atoi(id)
-if err != nil {
-return nil, errors.New("invalid shop ID")
+func (s *ShopService) AddItem(shopID int, item models.ShopItem) (*models.ShopItem, error) {
+return s.addItem(s.store, shopID, item)
 }
 
-shop := &models.Shop{}
-err = s.db.DB.QueryRow(`
-SELECT id, email, password, name, address, phone, description, created_at, updated_at 
-FROM shops WHERE id = ?`,
-shopID).Scan(
-&shop.ID, &shop.Email, &shop.Password, &shop.Name, &shop.Address, 
-&shop.Phone, &shop.Description, &shop.CreatedAt, &shop.UpdatedAt)
+func (s *ShopService) addItem(store repository.Store, shopID int, item models.ShopItem) (*models.ShopItem, error) {
+if err := normalizeItem(&item); err != nil {
+return nil, err
+}
 
+// Insert new item with its first price
+item.ShopID = shopID
+err := store.WithTx(func(tx repository.Store) error {
+if err := checkCodes(tx, &item); err != nil {
+return err
+}
+if err := tx.Shops().AddItem(&item); err != nil {
+return err
+}
+return addItemPrice(tx, &item)
+})
 if err != nil {
-return nil, errors.New("shop not found")
+return nil, err
 }
 
-return shop, nil
+return &item, nil
 }
 
-func (s *ShopService) AddItem(shopID int, item models.ShopItem) (*models.ShopItem, error) {
+// normalizeItem fills in the defaults of a catalogue item and puts its
+// price in its currency.
+func normalizeItem(item *models.ShopItem) error {
 // Set defaults for missing fields
 if item.Description == "" {
 item.Description = item.Category + " product"
@@ -118,49 +168,376 @@ item.Description = item.Category + " product"
 
 // Determine eco-friendly status based on category if not set
 if !item.IsEcoFriendly {
-item.IsEcoFriendly = item.Category == "Organic" || item.Category == "Eco-Friendly" || 
-item.Category == "Fruits" || item.Category == "Vegetables"
+item.IsEcoFriendly = models.IsEcoCategory(item.Category)
+}
+if item.Currency == "" {
+item.Currency = models.DefaultCurrency
+}
+price, err := priceIn(item.Price, item.Currency, "price")
+if err != nil {
+return err
+}
+item.Price = price
+
+// Barcodes are kept in their EAN-13 form so UPC-A codes match too
+if item.Barcode != "" {
+barcode, ok := models.CanonicalBarcode(item.Barcode)
+if !ok {
+return NewValidationError("barcode", "must be an EAN-13 or UPC-A barcode")
+}
+item.Barcode = barcode
+}
+return nil
+}
+
+// checkCodes rejects a SKU or barcode that another item of the catalogue
+// has.
+func checkCodes(store repository.Store, item *models.ShopItem) error {
+if item.SKU != "" {
+other, err := store.Shops().ItemBySKU(item.ShopID, item.SKU)
+if err != nil && !errors.Is(err, sql.ErrNoRows) {
+return err
+}
+if err == nil && other.ID != item.ID {
+return fmt.Errorf("item with sku %q %w", item.SKU, ErrConflict)
+}
+}
+
+if item.Barcode != "" {
+other, err := store.Shops().ItemByBarcode(item.ShopID, item.Barcode)
+if err != nil && !errors.Is(err, sql.ErrNoRows) {
+return err
+}
+if err == nil && other.ID != item.ID {
+return fmt.Errorf("item with barcode %s %w", item.Barcode, ErrConflict)
+}
+}
+return nil
+}
+
+func addItemPrice(store repository.Store, item *models.ShopItem) error {
+return store.Shops().AddItemPrice(&models.ShopItemPrice{
+ItemID:        item.ID,
+Price:         item.Price,
+Currency:      item.Price.Currency,
+EffectiveFrom: item.UpdatedAt,
+})
+}
+
+// GetItems returns the shop's catalogue, with archived items when
+// includeArchived is true.
+func (s *ShopService) GetItems(shopID int, includeArchived bool) ([]models.ShopItem, error) {
+return s.store.Shops().ListItems(shopID, includeArchived)
 }
 
-// Insert new item
-result, err := s.db.DB.Exec(`
-INSERT INTO shop_items (shop_id, name, price, category, description, is_eco_friendly) 
-VALUES (?, ?, ?, ?, ?, ?)`,
-shopID, item.Name, item.Price, item.Category, item.Description, item.IsEcoFriendly)
+// GetItem returns an item of the shop's catalogue, archived or not.
+func (s *ShopService) GetItem(shopID, itemID int) (*models.ShopItem, error) {
+return getItem(s.store, shopID, itemID)
+}
+
+// GetItemByID returns a catalogue item of any shop, archived or not.
+func (s *ShopService) GetItemByID(itemID int) (*models.ShopItem, error) {
+item, err := s.store.Shops().GetItemByID(itemID)
+if errors.Is(err, sql.ErrNoRows) {
+return nil, fmt.Errorf("item %w", ErrNotFound)
+}
 if err != nil {
 return nil, err
 }
 
-id, err := result.LastInsertId()
+return item, nil
+}
+
+// GetItemByBarcode returns the shop's item with an EAN-13 or UPC-A barcode.
+// Archived items are not found.
+func (s *ShopService) GetItemByBarcode(shopID int, code string) (*models.ShopItem, error) {
+barcode, ok := models.CanonicalBarcode(code)
+if !ok {
+return nil, NewValidationError("code", "must be an EAN-13 or UPC-A barcode")
+}
+
+item, err := s.store.Shops().ItemByBarcode(shopID, barcode)
+if errors.Is(err, sql.ErrNoRows) {
+return nil, fmt.Errorf("item %w", ErrNotFound)
+}
 if err != nil {
 return nil, err
 }
 
-item.ID = int(id)
-item.ShopID = shopID
-return &item, nil
+return item, nil
 }
 
-func (s *ShopService) GetItems(shopID int) ([]models.ShopItem, error) {
-rows, err := s.db.DB.Query(`
-SELECT id, shop_id, name, price, category, description, is_eco_friendly 
-FROM shop_items WHERE shop_id = ?`,
-shopID)
+func getItem(store repository.Store, shopID, itemID int) (*models.ShopItem, error) {
+item, err := store.Shops().GetItem(shopID, itemID)
+if errors.Is(err, sql.ErrNoRows) {
+return nil, fmt.Errorf("item %w", ErrNotFound)
+}
 if err != nil {
 return nil, err
 }
-defer rows.Close()
 
-var items []models.ShopItem
-for rows.Next() {
-var item models.ShopItem
-err := rows.Scan(&item.ID, &item.ShopID, &item.Name, &item.Price, 
-&item.Category, &item.Description, &item.IsEcoFriendly)
+return item, nil
+}
+
+// UpdateItem replaces the fields of a catalogue item.
+func (s *ShopService) UpdateItem(shopID, itemID int, update models.ShopItem) (*models.ShopItem, error) {
+return s.changeItem(s.store, shopID, itemID, func(item *models.ShopItem) {
+replaceItem(item, update)
+})
+}
+
+// replaceItem gives item the fields a client sets in update.
+func replaceItem(item *models.ShopItem, update models.ShopItem) {
+item.SKU = update.SKU
+item.Barcode = update.Barcode
+item.Name = update.Name
+item.Price = update.Price
+item.Currency = update.Currency
+item.Category = update.Category
+item.Description = update.Description
+item.IsEcoFriendly = update.IsEcoFriendly
+}
+
+// PatchItem changes the fields of a catalogue item present in patch. A new
+// currency without a new price keeps the amount.
+func (s *ShopService) PatchItem(shopID, itemID int, patch models.ShopItemPatch) (*models.ShopItem, error) {
+return s.changeItem(s.store, shopID, itemID, func(item *models.ShopItem) {
+patchItem(item, patch)
+})
+}
+
+// patchItem gives item the fields present in patch.
+func patchItem(item *models.ShopItem, patch models.ShopItemPatch) {
+if patch.SKU != nil {
+item.SKU = *patch.SKU
+}
+if patch.Barcode != nil {
+item.Barcode = *patch.Barcode
+}
+if patch.Name != nil {
+item.Name = *patch.Name
+}
+if patch.Currency != nil {
+item.Currency = *patch.Currency
+item.Price.Currency = ""
+}
+if patch.Price != nil {
+item.Price = *patch.Price
+}
+if patch.Category != nil {
+item.Category = *patch.Category
+}
+if patch.Description != nil {
+item.Description = *patch.Description
+}
+if patch.IsEcoFriendly != nil {
+item.IsEcoFriendly = *patch.IsEcoFriendly
+}
+}
+
+// cataloguePatch is the patch that sets the fields a catalogue file gives
+// item, leaving the rest as they are.
+func cataloguePatch(item models.CatalogueItem) models.ShopItemPatch {
+var patch models.ShopItemPatch
+for _, field := range item.Fields {
+switch field {
+case "sku":
+patch.SKU = &item.SKU
+case "barcode":
+patch.Barcode = &item.Barcode
+case "name":
+patch.Name = &item.Name
+case "price":
+patch.Price = &item.Price
+case "currency":
+patch.Currency = &item.Currency
+case "category":
+patch.Category = &item.Category
+case "description":
+patch.Description = &item.Description
+case "is_eco_friendly":
+patch.IsEcoFriendly = &item.IsEcoFriendly
+}
+}
+return patch
+}
+
+// changeItem applies change to a catalogue item and saves it. A new price
+// goes into the item's price history; receipts keep the price they sold
+// at. Archived items cannot be changed.
+func (s *ShopService) changeItem(store repository.Store, shopID, itemID int, change func(item *models.ShopItem)) (*models.ShopItem, error) {
+var item *models.ShopItem
+err := store.WithTx(func(tx repository.Store) error {
+var err error
+item, err = getItem(tx, shopID, itemID)
+if err != nil {
+return err
+}
+if item.ArchivedAt != nil {
+return fmt.Errorf("item %w", ErrUnavailable)
+}
+
+previous := item.Price
+change(item)
+if err := normalizeItem(item); err != nil {
+return err
+}
+if err := checkCodes(tx, item); err != nil {
+return err
+}
+if err := tx.Shops().UpdateItem(item); err != nil {
+return err
+}
+if item.Price != previous {
+return addItemPrice(tx, item)
+}
+return nil
+})
 if err != nil {
 return nil, err
 }
-items = append(items, item)
+
+return item, nil
+}
+
+// ArchiveItem takes an item out of the shop's catalogue. It stays in the
+// database so the receipts that sold it still refer to it.
+func (s *ShopService) ArchiveItem(shopID, itemID int) (*models.ShopItem, error) {
+if _, err := s.GetItem(shopID, itemID); err != nil {
+return nil, err
+}
+
+if err := s.store.Shops().ArchiveItem(itemID, time.Now()); err != nil {
+return nil, err
+}
+return s.GetItem(shopID, itemID)
+}
+
+// GetItemPrices returns the prices a catalogue item has had, oldest first.
+func (s *ShopService) GetItemPrices(shopID, itemID int) ([]models.ShopItemPrice, error) {
+if _, err := s.GetItem(shopID, itemID); err != nil {
+return nil, err
 }
 
-return items, nil
+return s.store.Shops().ItemPrices(itemID)
+}
+
+// errDryRun rolls back the transaction of a dry run import.
+var errDryRun = errors.New("dry run")
+
+// ImportItems adds items to the shop's catalogue in one transaction. Every
+// item is checked and all problems are reported together, keyed by the
+// item's index, e.g. "items[3].price"; if there are any, nothing is saved.
+// An item that replaces one already in the catalogue changes only its
+// Fields.
+func (s *ShopService) ImportItems(shopID int, items []models.CatalogueItem, options models.ItemImportOptions) (*models.ItemImportResult, error) {
+var result *models.ItemImportResult
+err := s.store.WithTx(func(tx repository.Store) error {
+if _, err := tx.Shops().GetByID(shopID); errors.Is(err, sql.ErrNoRows) {
+return fmt.Errorf("shop %w", ErrNotFound)
+} else if err != nil {
+return err
+}
+
+var err error
+result, err = s.importItems(tx, shopID, items, options)
+if err != nil {
+return err
+}
+if options.DryRun {
+return errDryRun
+}
+return nil
+})
+if err != nil && !errors.Is(err, errDryRun) {
+return nil, err
+}
+
+result.DryRun = options.DryRun
+return result, nil
+}
+
+func (s *ShopService) importItems(store repository.Store, shopID int, items []models.CatalogueItem, options models.ItemImportOptions) (*models.ItemImportResult, error) {
+result := &models.ItemImportResult{Items: make([]models.ShopItem, 0, len(items))}
+problems := make(map[string]string)
+skus := make(map[string]int)
+barcodes := make(map[string]int)
+
+for i, item := range items {
+field := fmt.Sprintf("items[%d]", i)
+
+// Items with a SKU already in the catalogue replace it when upserting
+var existing *models.ShopItem
+if item.SKU != "" {
+if first, ok := skus[item.SKU]; ok {
+problems[field+".sku"] = fmt.Sprintf("repeats items[%d]", first)
+continue
+}
+skus[item.SKU] = i
+
+var err error
+existing, err = store.Shops().ItemBySKU(shopID, item.SKU)
+if errors.Is(err, sql.ErrNoRows) {
+existing = nil
+} else if err != nil {
+return nil, err
+} else if !options.Upsert {
+problems[field+".sku"] = "is already in the catalogue"
+continue
+}
+}
+
+// A barcode may only be on the item it replaces
+if barcode, ok := models.CanonicalBarcode(item.Barcode); ok {
+if first, ok := barcodes[barcode]; ok {
+problems[field+".barcode"] = fmt.Sprintf("repeats items[%d]", first)
+continue
+}
+barcodes[barcode] = i
+
+other, err := store.Shops().ItemByBarcode(shopID, barcode)
+if err != nil && !errors.Is(err, sql.ErrNoRows) {
+return nil, err
+}
+if err == nil && (existing == nil || other.ID != existing.ID) {
+problems[field+".barcode"] = "is already in the catalogue"
+continue
+}
+}
+
+var saved *models.ShopItem
+var err error
+if existing != nil {
+patch := cataloguePatch(item)
+saved, err = s.changeItem(store, shopID, existing.ID, func(old *models.ShopItem) {
+patchItem(old, patch)
+})
+} else {
+saved, err = s.addItem(store, shopID, item.ShopItem)
+}
+
+var validationErr *ValidationError
+switch {
+case errors.As(err, &validationErr):
+for name, problem := range validationErr.Fields {
+problems[field+"."+name] = problem
+}
+case err != nil:
+return nil, err
+case existing != nil:
+result.Updated++
+result.Items = append(result.Items, *saved)
+default:
+if options.DryRun {
+saved.ID = 0
+}
+result.Created++
+result.Items = append(result.Items, *saved)
+}
+}
+
+if len(problems) > 0 {
+return nil, &ValidationError{Fields: problems}
+}
+return result, nil
 }
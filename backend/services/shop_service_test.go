@@ -0,0 +1,64 @@
+package services
+
+import (
+	"ecotracker-backend/models"
+	"testing"
+)
+
+// An upsert changes only the fields the catalogue file gives; a file without
+// a barcode, description or currency column keeps the item's.
+func TestImportItemsUpsertKeepsMissingFields(t *testing.T) {
+	store := newTestStore(t)
+	shop := newTestShop(t, store, "shop@example.com")
+	service := NewShopService(store, NewBcryptHasher(4))
+
+	original := models.CatalogueItem{
+		ShopItem: models.ShopItem{
+			SKU:         "A1",
+			Barcode:     "0036000291452",
+			Name:        "Soap",
+			Price:       models.Money{Amount: 250, Currency: "USD"},
+			Currency:    "USD",
+			Category:    "General",
+			Description: "Refillable bar",
+		},
+		Fields: []string{"sku", "barcode", "name", "price", "currency", "category", "description"},
+	}
+	if _, err := service.ImportItems(shop.ID, []models.CatalogueItem{original}, models.ItemImportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	update := models.CatalogueItem{
+		ShopItem: models.ShopItem{
+			SKU:      "A1",
+			Name:     "Olive soap",
+			Price:    models.Money{Amount: 299},
+			Category: "General",
+		},
+		Fields: []string{"sku", "name", "price", "category"},
+	}
+	result, err := service.ImportItems(shop.ID, []models.CatalogueItem{update}, models.ItemImportOptions{Upsert: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Updated != 1 || result.Created != 0 {
+		t.Fatalf("created %d and updated %d items, want 0 and 1", result.Created, result.Updated)
+	}
+
+	item := result.Items[0]
+	if item.Name != "Olive soap" {
+		t.Errorf("name = %q, want %q", item.Name, "Olive soap")
+	}
+	if want := (models.Money{Amount: 299, Currency: "USD"}); item.Price != want {
+		t.Errorf("price = %+v, want %+v", item.Price, want)
+	}
+	if item.Currency != "USD" {
+		t.Errorf("currency = %q, want USD", item.Currency)
+	}
+	if item.Barcode != original.Barcode {
+		t.Errorf("barcode = %q, want %q", item.Barcode, original.Barcode)
+	}
+	if item.Description != original.Description {
+		t.Errorf("description = %q, want %q", item.Description, original.Description)
+	}
+}
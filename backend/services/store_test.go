@@ -0,0 +1,52 @@
+package services
+
+import (
+	"ecotracker-backend/database"
+	"ecotracker-backend/models"
+	"ecotracker-backend/repository"
+	"path/filepath"
+	"testing"
+)
+
+// newTestStore returns a store on a fresh, migrated SQLite database.
+func newTestStore(t *testing.T) repository.Store {
+	t.Helper()
+
+	db, err := database.NewDatabase(database.Config{
+		Driver:        database.DriverSQLite,
+		Path:          filepath.Join(t.TempDir(), "test.db"),
+		JournalMode:   "WAL",
+		BusyTimeoutMS: 5000,
+		ForeignKeys:   true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := db.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	return repository.New(db)
+}
+
+// newTestCustomer and newTestShop add accounts for a test to refer to.
+func newTestCustomer(t *testing.T, store repository.Store, email string) *models.User {
+	t.Helper()
+
+	user := &models.User{Email: email, Password: "x", Name: "Customer", Phone: "+441234567890"}
+	if err := store.Users().Create(user); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func newTestShop(t *testing.T, store repository.Store, email string) *models.Shop {
+	t.Helper()
+
+	shop := &models.Shop{Email: email, Password: "x", Name: "Shop", Address: "1 High St", Phone: "+441234567891"}
+	if err := store.Shops().Create(shop); err != nil {
+		t.Fatal(err)
+	}
+	return shop
+}
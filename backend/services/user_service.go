@@ -1,158 +1,180 @@
 ﻿package services
 
 import (
-"ecotracker-backend/database"
+"database/sql"
+"ecotracker-backend/auth"
 "ecotracker-backend/models"
+"ecotracker-backend/repository"
 "errors"
-"strconv"
+"fmt"
 "strings"
-"time"
 )
 
 type UserService struct {
-db *database.Database
+store  repository.Store
+hasher PasswordHasher
+dummy  dummyPassword
 }
 
-func NewUserService(db *database.Database) *UserService {
-return &UserService{db: db}
+func NewUserService(store repository.Store, hasher PasswordHasher) *UserService {
+return &UserService{store: store, hasher: hasher}
 }
 
 func (s *UserService) Register(req models.UserRegistration) (*models.User, error) {
 // Check if user already exists
-var count int
-err := s.db.DB.QueryRow("SELECT COUNT(*) FROM users WHERE email = ?", req.Email).Scan(&count)
+exists, err := s.store.Users().EmailExists(req.Email)
 if err != nil {
 return nil, err
 }
-if count > 0 {
-return nil, errors.New("user already exists")
+if exists {
+return nil, fmt.Errorf("user %w", ErrConflict)
 }
 
-// Insert new user
-result, err := s.db.DB.Exec(`
-INSERT INTO users (email, password, name, phone, points, created_at, updated_at) 
-VALUES (?, ?, ?, ?, 0, ?, ?)`,
-req.Email, req.Password, req.Name, req.Phone, time.Now(), time.Now())
-if err != nil {
-return nil, err
-}
-
-id, err := result.LastInsertId()
+passwordHash, err := s.hasher.Hash(req.Password)
 if err != nil {
 return nil, err
 }
 
-// Return the created user
+// Insert new user
 user := &models.User{
-ID:        int(id),
-Email:     req.Email,
-Password:  req.Password,
-Name:      req.Name,
-Phone:     req.Phone,
-Points:    0,
-CreatedAt: time.Now(),
-UpdatedAt: time.Now(),
+Email:    req.Email,
+Password: passwordHash,
+Name:     req.Name,
+Phone:    req.Phone,
+Points:   0,
+Role:     auth.RoleCustomer,
+}
+if err := s.store.Users().Create(user); err != nil {
+return nil, err
 }
 
 return user, nil
 }
 
 func (s *UserService) Login(req models.UserLogin) (*models.User, error) {
-user := &models.User{}
-err := s.db.DB.QueryRow(`
-SELECT id, email, password, name, phone, points, created_at, updated_at 
-FROM users WHERE email = ? AND password = ?`,
-req.Email, req.Password).Scan(
-&user.ID, &user.Email, &user.Password, &user.Name, &user.Phone, 
-&user.Points, &user.CreatedAt, &user.UpdatedAt)
-
+user, err := s.store.Users().GetByEmail(req.Email)
+if errors.Is(err, sql.ErrNoRows) {
+s.dummy.reject(s.hasher, req.Password)
+return nil, ErrInvalidCredentials
+}
 if err != nil {
-return nil, errors.New("invalid credentials")
+return nil, err
 }
 
-return user, nil
+ok, rehash, err := verifyPassword(s.hasher, user.Password, req.Password)
+if err != nil || !ok {
+return nil, ErrInvalidCredentials
 }
 
-func (s *UserService) GetUser(id string) (*models.User, error) {
-userID, err := strconv.Atoi(id)
-if err != nil {
-return nil, errors.New("invalid user ID")
+// Upgrade legacy plaintext or outdated hashes now that we know the password
+if rehash {
+if err := s.rehashPassword(user, req.Password); err != nil {
+return nil, err
 }
-
-user := &models.User{}
-err = s.db.DB.QueryRow(`
-SELECT id, email, password, name, phone, points, created_at, updated_at 
-FROM users WHERE id = ?`,
-userID).Scan(
-&user.ID, &user.Email, &user.Password, &user.Name, &user.Phone, 
-&user.Points, &user.CreatedAt, &user.UpdatedAt)
-
-if err != nil {
-return nil, errors.New("user not found")
 }
 
 return user, nil
 }
 
-func (s *UserService) UpdateUser(id string, updates map[string]interface{}) (*models.User, error) {
-userID, err := strconv.Atoi(id)
+func (s *UserService) rehashPassword(user *models.User, password string) error {
+	passwordHash, err := s.hasher.Hash(password)
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.Users().SetPassword(user.ID, user.Password, passwordHash); err != nil {
+		return err
+	}
+
+	user.Password = passwordHash
+	return nil
+}
+
+func (s *UserService) GetUser(userID int) (*models.User, error) {
+user, err := s.store.Users().GetByID(userID)
+if errors.Is(err, sql.ErrNoRows) {
+return nil, fmt.Errorf("user %w", ErrNotFound)
+}
 if err != nil {
-return nil, errors.New("invalid user ID")
+return nil, err
 }
 
-// Build update query dynamically
-setParts := []string{}
-args := []interface{}{}
+return user, nil
+}
 
-if name, ok := updates["name"].(string); ok {
-setParts = append(setParts, "name = ?")
-args = append(args, name)
+func (s *UserService) UpdateUser(userID int, updates map[string]interface{}) (*models.User, error) {
+var name, phone *string
+if v, ok := updates["name"].(string); ok {
+name = &v
 }
-if phone, ok := updates["phone"].(string); ok {
-setParts = append(setParts, "phone = ?")
-args = append(args, phone)
+if v, ok := updates["phone"].(string); ok {
+phone = &v
 }
 
-if len(setParts) == 0 {
-return nil, errors.New("no valid updates provided")
+if name == nil && phone == nil {
+return nil, NewValidationError("updates", "must include name or phone")
 }
 
-setParts = append(setParts, "updated_at = ?")
-args = append(args, time.Now())
-args = append(args, userID)
-
-query := "UPDATE users SET " + strings.Join(setParts, ", ") + " WHERE id = ?"
-_, err = s.db.DB.Exec(query, args...)
-if err != nil {
+if err := s.store.Users().Update(userID, name, phone); err != nil {
 return nil, err
 }
 
 // Return updated user
-return s.GetUser(id)
+return s.GetUser(userID)
 }
 
 func (s *UserService) ValidateCustomer(identifier string) (*models.User, error) {
-	var user models.User
-	
-	// Try to find user by email first, then by phone
-	err := s.db.DB.QueryRow(`
-		SELECT id, email, name, phone, points, created_at, updated_at 
-		FROM users WHERE email = ? OR phone = ?`,
-		identifier, identifier).Scan(
-		&user.ID, &user.Email, &user.Name, &user.Phone, 
-		&user.Points, &user.CreatedAt, &user.UpdatedAt)
-	
+	// Find the user by email or phone
+	user, err := s.store.Users().FindByEmailOrPhone(identifier)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("customer %w", ErrNotFound)
+	}
 	if err != nil {
-		return nil, errors.New("customer not found")
+		return nil, err
 	}
-	
-	return &user, nil
+
+	return user, nil
 }
 
-func (s *UserService) UpdateUserPoints(userID int, points int) error {
-	_, err := s.db.DB.Exec(`
-		UPDATE users SET points = ?, updated_at = datetime('now') 
-		WHERE id = ?`,
-		points, userID)
-	return err
+// UpdateUserPoints sets a user's balance by appending an adjustment of the
+// difference to their points ledger.
+func (s *UserService) UpdateUserPoints(userID int, points int, reason string) error {
+	if reason == "" {
+		reason = "Balance set by an administrator"
+	}
+
+	return s.store.WithTx(func(tx repository.Store) error {
+		user, err := tx.Users().GetByID(userID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("user %w", ErrNotFound)
+		}
+		if err != nil {
+			return err
+		}
+
+		if points == user.Points {
+			return nil
+		}
+
+		return tx.Points().Append(&models.PointsEntry{
+			UserID: userID,
+			Kind:   models.PointsAdjust,
+			Amount: points - user.Points,
+			Reason: reason,
+		})
+	})
+}
+
+// PromoteAdmins grants the admin role to the users with the given emails.
+func (s *UserService) PromoteAdmins(emails []string) error {
+	for _, email := range emails {
+		email = strings.TrimSpace(email)
+		if email == "" {
+			continue
+		}
+		if err := s.store.Users().SetRoleByEmail(email, auth.RoleAdmin); err != nil {
+			return err
+		}
+	}
+	return nil
 }
@@ -0,0 +1,70 @@
+package services
+
+import (
+	"ecotracker-backend/models"
+	"ecotracker-backend/repository"
+	"errors"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// countingHasher counts the hashes it makes and verifies.
+type countingHasher struct {
+	PasswordHasher
+	hashes, verifies int
+}
+
+func (h *countingHasher) Hash(password string) (string, error) {
+	h.hashes++
+	return h.PasswordHasher.Hash(password)
+}
+
+func (h *countingHasher) Verify(encoded, password string) (bool, error) {
+	h.verifies++
+	return h.PasswordHasher.Verify(encoded, password)
+}
+
+// A login for an unknown email verifies a password hash like one for a
+// registered email does, so response times do not tell them apart.
+func TestLoginUnknownEmailVerifiesHash(t *testing.T) {
+	// login returns a login for an unknown email at a new service
+	tests := []struct {
+		name  string
+		login func(store repository.Store, hasher PasswordHasher) func() error
+	}{
+		{"user", func(store repository.Store, hasher PasswordHasher) func() error {
+			users := NewUserService(store, hasher)
+			return func() error {
+				_, err := users.Login(models.UserLogin{Email: "nobody@example.com", Password: "secret1"})
+				return err
+			}
+		}},
+		{"shop", func(store repository.Store, hasher PasswordHasher) func() error {
+			shops := NewShopService(store, hasher)
+			return func() error {
+				_, err := shops.Login(models.ShopLogin{Email: "nobody@example.com", Password: "secret1"})
+				return err
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hasher := &countingHasher{PasswordHasher: NewBcryptHasher(bcrypt.MinCost)}
+			login := tt.login(newTestStore(t), hasher)
+
+			for attempt := 1; attempt <= 2; attempt++ {
+				if err := login(); !errors.Is(err, ErrInvalidCredentials) {
+					t.Fatalf("err = %v, want ErrInvalidCredentials", err)
+				}
+				if hasher.verifies != attempt {
+					t.Errorf("after %d logins, %d hashes verified; want one per login", attempt, hasher.verifies)
+				}
+			}
+			// The dummy hash is made once, by the first login that needs it
+			if hasher.hashes != 1 {
+				t.Errorf("%d hashes made, want 1", hasher.hashes)
+			}
+		})
+	}
+}
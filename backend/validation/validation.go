@@ -0,0 +1,137 @@
+// Package validation enforces the `validate` struct tags on request models
+// before handlers pass them to the services.
+package validation
+
+import (
+	"ecotracker-backend/models"
+	"ecotracker-backend/services"
+	"errors"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+var phonePattern = regexp.MustCompile(`^\+?[0-9][0-9 \-]{5,18}[0-9]$`)
+
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+
+	// Report fields by their JSON names, the way clients send them
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+
+	v.RegisterValidation("phone", func(fl validator.FieldLevel) bool {
+		return phonePattern.MatchString(fl.Field().String())
+	})
+	v.RegisterValidation("category", func(fl validator.FieldLevel) bool {
+		return models.IsCategory(fl.Field().String())
+	})
+
+	v.RegisterValidation("currency", func(fl validator.FieldLevel) bool {
+		return models.IsCurrency(fl.Field().String())
+	})
+	v.RegisterValidation("barcode", func(fl validator.FieldLevel) bool {
+		_, ok := models.CanonicalBarcode(fl.Field().String())
+		return ok
+	})
+
+	// Amounts are checked in minor units, so gt=0 means at least one cent
+	v.RegisterCustomTypeFunc(func(field reflect.Value) interface{} {
+		return field.Interface().(models.Money).Amount
+	}, models.Money{})
+
+	return v
+}
+
+// Struct validates a request model and returns a *services.ValidationError
+// with one entry per invalid field, or nil.
+func Struct(s interface{}) error {
+	err := validate.Struct(s)
+	if err == nil {
+		return nil
+	}
+
+	var fieldErrs validator.ValidationErrors
+	if !errors.As(err, &fieldErrs) {
+		return err
+	}
+
+	root := reflect.Indirect(reflect.ValueOf(s)).Type().Name()
+
+	fields := make(map[string]string, len(fieldErrs))
+	for _, fieldErr := range fieldErrs {
+		fields[fieldPath(root, fieldErr)] = message(fieldErr)
+	}
+	return &services.ValidationError{Fields: fields}
+}
+
+// fieldPath drops the root struct name and embedded struct names from the
+// namespace, so "ReceiptCreate.items[0].price" becomes "items[0].price".
+// Embedded structs have no JSON name and show up under their type name.
+func fieldPath(root string, fieldErr validator.FieldError) string {
+	parts := strings.Split(fieldErr.Namespace(), ".")
+	if root != "" && len(parts) > 1 && parts[0] == root {
+		parts = parts[1:]
+	}
+
+	path := parts[:0]
+	for _, part := range parts {
+		if part != "" && !isTypeName(part) {
+			path = append(path, part)
+		}
+	}
+	return strings.Join(path, ".")
+}
+
+// JSON field names in this API are lower case; type names are exported.
+func isTypeName(part string) bool {
+	return part[0] >= 'A' && part[0] <= 'Z'
+}
+
+func message(fieldErr validator.FieldError) string {
+	param := fieldErr.Param()
+
+	switch fieldErr.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "phone":
+		return "must be a valid phone number"
+	case "category":
+		return "must be one of " + strings.Join(models.Categories, ", ")
+	case "barcode", "barcode|len=0":
+		return "must be an EAN-13 or UPC-A barcode"
+	case "currency":
+		return "must be one of " + strings.Join(models.Currencies, ", ")
+	case "min":
+		switch fieldErr.Kind() {
+		case reflect.String:
+			return "must be at least " + param + " characters"
+		case reflect.Slice:
+			return "must contain at least " + param + " entries"
+		}
+		return "must be at least " + param
+	case "max":
+		if fieldErr.Kind() == reflect.String {
+			return "must be at most " + param + " characters"
+		}
+		return "must be at most " + param
+	case "gt":
+		return "must be greater than " + param
+	case "gte":
+		return "must be at least " + param
+	case "oneof":
+		return "must be one of " + param
+	}
+	return "is invalid"
+}
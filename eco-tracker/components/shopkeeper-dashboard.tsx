@@ -7,7 +7,7 @@ import { Label } from "@/components/ui/label"
 import { Badge } from "@/components/ui/badge"
 import { useState, useEffect } from "react"
 import Image from "next/image"
-import { ShopItem, User, ApiService, Receipt } from "../lib/api"
+import { ShopItem, User, ApiService, Receipt, ChallengeCompletion } from "../lib/api"
 
 interface ShopkeeperDashboardProps {
   onLogout: () => void
@@ -29,6 +29,7 @@ export function ShopkeeperDashboard({ onLogout, shopItems = [], user }: Shopkeep
   const [totalPointsAwarded, setTotalPointsAwarded] = useState(0)
   const [validatedCustomer, setValidatedCustomer] = useState<any>(null)
   const [validationTimeout, setValidationTimeout] = useState<NodeJS.Timeout | null>(null)
+  const [barcode, setBarcode] = useState("")
 
   const shopName = user?.name || "Your Shop"
 
@@ -110,6 +111,24 @@ export function ShopkeeperDashboard({ onLogout, shopItems = [], user }: Shopkeep
     }))
   }
 
+  // Barcode scanners type the code and press Enter
+  const handleBarcodeScan = async (e: React.FormEvent) => {
+    e.preventDefault()
+    const code = barcode.trim()
+    if (!user?.id || !code) return
+
+    try {
+      setError("")
+      const item = await ApiService.getShopItemByBarcode(user.id, code)
+      handleItemQuantityChange(item.id, (selectedItems[item.id] || 0) + 1)
+    } catch (error) {
+      console.error('Error looking up barcode:', error)
+      setError(`No item with barcode ${code}`)
+    } finally {
+      setBarcode("")
+    }
+  }
+
   const calculateTotal = () => {
     return Object.entries(selectedItems).reduce((total, [itemId, quantity]) => {
       const item = currentShopItems.find(i => i.id === parseInt(itemId))
@@ -167,12 +186,10 @@ export function ShopkeeperDashboard({ onLogout, shopItems = [], user }: Shopkeep
 
     try {
       // Create receipt in database
+      // The backend takes name, price and category from the catalogue
       const receiptItems = purchasedItems.map(item => ({
-        name: item.name,
-        price: item.price,
-        quantity: item.quantity,
-        category: item.category,
-        is_eco_friendly: item.is_eco_friendly || false
+        item_id: item.id,
+        quantity: item.quantity
       }))
 
       const receiptData = {
@@ -196,7 +213,9 @@ export function ShopkeeperDashboard({ onLogout, shopItems = [], user }: Shopkeep
         total,
         pointsEarned,
         timestamp: new Date().toLocaleString(),
-        receiptId: savedReceipt.id
+        receiptId: savedReceipt.id,
+        completedChallenges: savedReceipt.completed_challenges || [],
+        bonusPoints: savedReceipt.bonus_points || 0
       })
 
       setShowReceipt(true)
@@ -411,6 +430,18 @@ export function ShopkeeperDashboard({ onLogout, shopItems = [], user }: Shopkeep
                 </form>
               )}
 
+              {/* Barcode scan */}
+              <form onSubmit={handleBarcodeScan} style={{ marginBottom: '16px' }}>
+                <input
+                  type="text"
+                  inputMode="numeric"
+                  placeholder="Scan or type a barcode"
+                  className="input"
+                  value={barcode}
+                  onChange={(e) => setBarcode(e.target.value)}
+                />
+              </form>
+
               {/* Items List */}
               {!currentShopItems || currentShopItems.length === 0 ? (
                 <div className="empty-state">
@@ -670,6 +701,11 @@ export function ShopkeeperDashboard({ onLogout, shopItems = [], user }: Shopkeep
                 <div style={{ color: '#16a34a', fontWeight: '600' }}>
                    Eco Points Earned: {receiptData.pointsEarned}
                 </div>
+                {receiptData.completedChallenges.map((completion: ChallengeCompletion) => (
+                  <div key={completion.challenge_id} style={{ fontSize: '14px', color: '#15803d', marginTop: '4px' }}>
+                    Challenge completed: {completion.name} (+{completion.points} bonus points)
+                  </div>
+                ))}
                 <div style={{ fontSize: '14px', color: '#6b7280', marginTop: '4px' }}>
                   Thank you for choosing eco-friendly products!
                 </div>
@@ -7,6 +7,7 @@ export interface User {
   name: string;
   phone: string;
   points: number;
+  role?: 'customer' | 'admin';
   created_at: string;
   updated_at: string;
 }
@@ -34,20 +35,137 @@ export interface ShopRegistration {
 
 export interface ShopItem {
   id: number;
+  sku?: string;
+  // EAN-13 barcode; UPC-A codes are stored with a leading zero
+  barcode?: string;
   name: string;
+  // Amounts are exact to the cent; currency is an ISO 4217 code
   price: number;
+  currency?: string;
   category: string;
   description?: string;
   is_eco_friendly?: boolean;
+  // Archived items can no longer be sold but stay on old receipts
+  archived_at?: string;
+  created_at?: string;
+  updated_at?: string;
+}
+
+export interface ShopItemImportResult {
+  dry_run: boolean;
+  created: number;
+  updated: number;
+  items: ShopItem[];
+}
+
+export interface ShopItemPrice {
+  id: number;
+  item_id: number;
+  price: number;
+  currency: string;
+  effective_from: string;
+}
+
+export interface ReceiptItem {
+  id: number;
+  receipt_id: number;
+  name: string;
+  price: number;
+  quantity: number;
+  category: string;
+  is_eco_friendly: boolean;
+  refunded_quantity?: number;
+  item_id?: number;
+  list_price?: number;
+  price_override?: boolean;
+}
+
+export interface ReceiptRefund {
+  id: number;
+  receipt_id: number;
+  amount: number;
+  points_reversed: number;
+  reason: string;
+  items: { item_id: number; quantity: number }[];
+  created_at: string;
 }
 
 export interface Receipt {
   id: number;
   user_id: number;
   shop_id: number;
+  items?: ReceiptItem[];
   total_amount: number;
+  currency: string;
   points_earned: number;
   created_at: string;
+  status: 'active' | 'partially_refunded' | 'voided';
+  refunded_amount: number;
+  points_reversed: number;
+  refunds?: ReceiptRefund[];
+  completed_challenges?: ChallengeCompletion[];
+  bonus_points?: number;
+}
+
+export interface ChallengeCompletion {
+  challenge_id: number;
+  name: string;
+  points: number;
+}
+
+export interface PointsEntry {
+  id: number;
+  user_id: number;
+  kind: 'earn' | 'bonus' | 'redeem' | 'adjust' | 'reversal' | 'expire';
+  amount: number;
+  reason: string;
+  receipt_id?: number;
+  challenge_id?: number;
+  redemption_id?: number;
+  created_at: string;
+}
+
+export interface PointsLine {
+  name: string;
+  category: string;
+  is_eco_friendly: boolean;
+  amount: number;
+  multiplier: number;
+  rules: string[];
+  points: number;
+}
+
+export interface PointsQuote {
+  lines: PointsLine[];
+  subtotal: number;
+  points: number;
+  capped_by?: 'receipt' | 'daily';
+}
+
+export interface Reward {
+  id: number;
+  shop_id?: number;
+  name: string;
+  description: string;
+  points_cost: number;
+  stock: number | null;
+  active: boolean;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface Redemption {
+  id: number;
+  reward_id: number;
+  reward_name: string;
+  shop_id?: number;
+  user_id: number;
+  code: string;
+  points_spent: number;
+  status: 'issued' | 'consumed';
+  created_at: string;
+  consumed_at?: string;
+  consumed_by_shop_id?: number;
 }
 
 export interface Challenge {
@@ -58,6 +176,73 @@ export interface Challenge {
   target: number;
   progress: number;
   earned: boolean;
+  points?: number;
+  completed_at?: string;
+}
+
+// Error envelope returned by the backend for every failed request
+export interface ApiErrorBody {
+  code: string;
+  message: string;
+  fields?: Record<string, string>;
+}
+
+export class ApiError extends Error {
+  status: number;
+  code: string;
+  fields: Record<string, string>;
+
+  constructor(status: number, body: ApiErrorBody) {
+    super(body.message);
+    this.name = 'ApiError';
+    this.status = status;
+    this.code = body.code;
+    this.fields = body.fields || {};
+  }
+
+  static async fromResponse(response: Response): Promise<ApiError> {
+    const text = await response.text();
+    try {
+      return new ApiError(response.status, JSON.parse(text));
+    } catch {
+      return new ApiError(response.status, { code: 'unknown', message: text || response.statusText });
+    }
+  }
+}
+
+export interface TokenPair {
+  access_token: string;
+  refresh_token: string;
+  token_type: string;
+  expires_in: number;
+}
+
+const TOKEN_STORAGE_KEY = 'ecotracker_tokens';
+
+// Keep the token pair returned by register/login so later calls can authenticate
+function storeTokens(data: Partial<TokenPair>) {
+  if (typeof window === 'undefined' || !data.access_token || !data.refresh_token) {
+    return;
+  }
+  localStorage.setItem(TOKEN_STORAGE_KEY, JSON.stringify({
+    access_token: data.access_token,
+    refresh_token: data.refresh_token,
+    token_type: data.token_type,
+    expires_in: data.expires_in,
+  }));
+}
+
+function loadTokens(): TokenPair | null {
+  if (typeof window === 'undefined') {
+    return null;
+  }
+  const raw = localStorage.getItem(TOKEN_STORAGE_KEY);
+  return raw ? JSON.parse(raw) : null;
+}
+
+function authHeaders(): Record<string, string> {
+  const tokens = loadTokens();
+  return tokens ? { Authorization: `Bearer ${tokens.access_token}` } : {};
 }
 
 export class ApiService {
@@ -67,16 +252,18 @@ export class ApiService {
       method: 'POST',
       headers: {
         'Content-Type': 'application/json',
+        ...authHeaders(),
       },
       body: JSON.stringify(userData),
     });
 
     if (!response.ok) {
-      const error = await response.text();
-      throw new Error(error);
+      throw await ApiError.fromResponse(response);
     }
 
-    return response.json();
+    const data = await response.json();
+    storeTokens(data);
+    return data;
   }
 
   // Shop registration with items
@@ -85,6 +272,7 @@ export class ApiService {
       method: 'POST',
       headers: {
         'Content-Type': 'application/json',
+        ...authHeaders(),
       },
       body: JSON.stringify({
         ...shopData,
@@ -93,11 +281,12 @@ export class ApiService {
     });
 
     if (!response.ok) {
-      const error = await response.text();
-      throw new Error(error);
+      throw await ApiError.fromResponse(response);
     }
 
-    return response.json();
+    const data = await response.json();
+    storeTokens(data);
+    return data;
   }
 
   // Add item to shop
@@ -117,13 +306,13 @@ export class ApiService {
       method: 'POST',
       headers: {
         'Content-Type': 'application/json',
+        ...authHeaders(),
       },
       body: JSON.stringify(itemData),
     });
 
     if (!response.ok) {
-      const error = await response.text();
-      throw new Error(error);
+      throw await ApiError.fromResponse(response);
     }
 
     return response.json();
@@ -131,11 +320,112 @@ export class ApiService {
 
   // Get shop items
   static async getShopItems(shopId: number): Promise<ShopItem[]> {
-    const response = await fetch(`${API_BASE_URL}/shops/${shopId}/items`);
+    const response = await fetch(`${API_BASE_URL}/shops/${shopId}/items`, {
+      headers: authHeaders(),
+    });
+
+    if (!response.ok) {
+      throw await ApiError.fromResponse(response);
+    }
+
+    return response.json();
+  }
+
+  // Look up a shop item by its EAN-13 or UPC-A barcode
+  static async getShopItemByBarcode(shopId: number, code: string): Promise<ShopItem> {
+    const response = await fetch(`${API_BASE_URL}/shops/${shopId}/items/by-barcode/${encodeURIComponent(code)}`, {
+      headers: authHeaders(),
+    });
+
+    if (!response.ok) {
+      throw await ApiError.fromResponse(response);
+    }
+
+    return response.json();
+  }
+
+  // Change some fields of a shop item; a new price is kept in its history
+  static async updateShopItem(shopId: number, itemId: number, changes: Partial<ShopItem>): Promise<ShopItem> {
+    const response = await fetch(`${API_BASE_URL}/shops/${shopId}/items/${itemId}`, {
+      method: 'PATCH',
+      headers: {
+        'Content-Type': 'application/json',
+        ...authHeaders(),
+      },
+      body: JSON.stringify(changes),
+    });
+
+    if (!response.ok) {
+      throw await ApiError.fromResponse(response);
+    }
+
+    return response.json();
+  }
+
+  // Archive a shop item; receipts that sold it keep referring to it
+  static async archiveShopItem(shopId: number, itemId: number): Promise<ShopItem> {
+    const response = await fetch(`${API_BASE_URL}/shops/${shopId}/items/${itemId}`, {
+      method: 'DELETE',
+      headers: authHeaders(),
+    });
+
+    if (!response.ok) {
+      throw await ApiError.fromResponse(response);
+    }
+
+    return response.json();
+  }
+
+  // Import many items from a CSV or JSON file. Nothing is saved if any row is
+  // invalid; the error lists every problem by row, e.g. "items[3].price".
+  static async importShopItems(
+    shopId: number,
+    file: Blob,
+    format: 'csv' | 'json',
+    options: { upsert?: boolean; dryRun?: boolean } = {},
+  ): Promise<ShopItemImportResult> {
+    const params = new URLSearchParams({
+      format,
+      upsert: String(options.upsert ?? false),
+      dry_run: String(options.dryRun ?? false),
+    });
+    const response = await fetch(`${API_BASE_URL}/shops/${shopId}/items/import?${params}`, {
+      method: 'POST',
+      headers: {
+        'Content-Type': format === 'csv' ? 'text/csv' : 'application/json',
+        ...authHeaders(),
+      },
+      body: file,
+    });
+
+    if (!response.ok) {
+      throw await ApiError.fromResponse(response);
+    }
+
+    return response.json();
+  }
+
+  // Download the shop's catalogue in the format importShopItems reads
+  static async exportShopItems(shopId: number, format: 'csv' | 'json'): Promise<Blob> {
+    const response = await fetch(`${API_BASE_URL}/shops/${shopId}/items/export?format=${format}`, {
+      headers: authHeaders(),
+    });
 
     if (!response.ok) {
-      const error = await response.text();
-      throw new Error(error);
+      throw await ApiError.fromResponse(response);
+    }
+
+    return response.blob();
+  }
+
+  // Get the prices a shop item has had, oldest first
+  static async getShopItemPrices(itemId: number): Promise<ShopItemPrice[]> {
+    const response = await fetch(`${API_BASE_URL}/items/${itemId}/prices`, {
+      headers: authHeaders(),
+    });
+
+    if (!response.ok) {
+      throw await ApiError.fromResponse(response);
     }
 
     return response.json();
@@ -147,25 +437,28 @@ export class ApiService {
       method: 'POST',
       headers: {
         'Content-Type': 'application/json',
+        ...authHeaders(),
       },
       body: JSON.stringify(loginData),
     });
 
     if (!response.ok) {
-      const error = await response.text();
-      throw new Error(error);
+      throw await ApiError.fromResponse(response);
     }
 
-    return response.json();
+    const data = await response.json();
+    storeTokens(data);
+    return data;
   }
 
   // Get user by ID
   static async getUser(id: number): Promise<User> {
-    const response = await fetch(`${API_BASE_URL}/users/${id}`);
+    const response = await fetch(`${API_BASE_URL}/users/${id}`, {
+      headers: authHeaders(),
+    });
 
     if (!response.ok) {
-      const error = await response.text();
-      throw new Error(error);
+      throw await ApiError.fromResponse(response);
     }
 
     return response.json();
@@ -179,6 +472,7 @@ export class ApiService {
         method: 'POST',
         headers: {
           'Content-Type': 'application/json',
+          ...authHeaders(),
         },
         body: JSON.stringify({ identifier }),
       });
@@ -202,13 +496,15 @@ export class ApiService {
   // Get user receipts
   static async getUserReceipts(userId: number): Promise<Receipt[]> {
     console.log('API: Fetching receipts for user:', userId)
-    const response = await fetch(`${API_BASE_URL}/users/${userId}/receipts`);
+    const response = await fetch(`${API_BASE_URL}/users/${userId}/receipts`, {
+      headers: authHeaders(),
+    });
 
     console.log('API: getUserReceipts response status:', response.status)
     if (!response.ok) {
-      const error = await response.text();
+      const error = await ApiError.fromResponse(response);
       console.error('API: getUserReceipts error:', error)
-      throw new Error(error);
+      throw error;
     }
 
     const receipts = await response.json();
@@ -216,13 +512,113 @@ export class ApiService {
     return receipts;
   }
 
+  // Get a user's points history, newest first
+  static async getPointsHistory(userId: number): Promise<PointsEntry[]> {
+    const response = await fetch(`${API_BASE_URL}/users/${userId}/points/history`, {
+      headers: authHeaders(),
+    });
+
+    if (!response.ok) {
+      throw await ApiError.fromResponse(response);
+    }
+
+    return response.json();
+  }
+
+  // Dry run of the points rules for a basket; nothing is recorded
+  static async previewPoints(
+    shopId: number,
+    items: Omit<ReceiptItem, 'id' | 'receipt_id'>[],
+    userId?: number,
+  ): Promise<PointsQuote> {
+    const response = await fetch(`${API_BASE_URL}/points/preview`, {
+      method: 'POST',
+      headers: {
+        'Content-Type': 'application/json',
+        ...authHeaders(),
+      },
+      body: JSON.stringify({ shop_id: shopId, user_id: userId, items }),
+    });
+
+    if (!response.ok) {
+      throw await ApiError.fromResponse(response);
+    }
+
+    return response.json();
+  }
+
+  static async getRewards(shopId?: number): Promise<Reward[]> {
+    const query = shopId ? `?shop_id=${shopId}` : '';
+    const response = await fetch(`${API_BASE_URL}/rewards${query}`, {
+      headers: authHeaders(),
+    });
+
+    if (!response.ok) {
+      throw await ApiError.fromResponse(response);
+    }
+
+    return response.json();
+  }
+
+  static async redeemReward(rewardId: number): Promise<Redemption> {
+    const response = await fetch(`${API_BASE_URL}/rewards/${rewardId}/redeem`, {
+      method: 'POST',
+      headers: authHeaders(),
+    });
+
+    if (!response.ok) {
+      throw await ApiError.fromResponse(response);
+    }
+
+    return response.json();
+  }
+
+  static async getRedemptions(userId: number): Promise<Redemption[]> {
+    const response = await fetch(`${API_BASE_URL}/users/${userId}/redemptions`, {
+      headers: authHeaders(),
+    });
+
+    if (!response.ok) {
+      throw await ApiError.fromResponse(response);
+    }
+
+    return response.json();
+  }
+
+  // Check a redemption code at checkout; consume uses it up
+  static async verifyRedemption(code: string): Promise<Redemption> {
+    return ApiService.postRedemptionCode('verify', code);
+  }
+
+  static async consumeRedemption(code: string): Promise<Redemption> {
+    return ApiService.postRedemptionCode('consume', code);
+  }
+
+  private static async postRedemptionCode(action: 'verify' | 'consume', code: string): Promise<Redemption> {
+    const response = await fetch(`${API_BASE_URL}/redemptions/${action}`, {
+      method: 'POST',
+      headers: {
+        'Content-Type': 'application/json',
+        ...authHeaders(),
+      },
+      body: JSON.stringify({ code }),
+    });
+
+    if (!response.ok) {
+      throw await ApiError.fromResponse(response);
+    }
+
+    return response.json();
+  }
+
   // Get user challenges
   static async getUserChallenges(userId: number): Promise<Challenge[]> {
-    const response = await fetch(`${API_BASE_URL}/users/${userId}/challenges`);
+    const response = await fetch(`${API_BASE_URL}/users/${userId}/challenges`, {
+      headers: authHeaders(),
+    });
 
     if (!response.ok) {
-      const error = await response.text();
-      throw new Error(error);
+      throw await ApiError.fromResponse(response);
     }
 
     return response.json();
@@ -230,43 +626,94 @@ export class ApiService {
 
   // Get shop receipts
   static async getShopReceipts(shopId: number): Promise<Receipt[]> {
-    const response = await fetch(`${API_BASE_URL}/shops/${shopId}/receipts`);
+    const response = await fetch(`${API_BASE_URL}/shops/${shopId}/receipts`, {
+      headers: authHeaders(),
+    });
 
     if (!response.ok) {
-      const error = await response.text();
-      throw new Error(error);
+      throw await ApiError.fromResponse(response);
     }
 
     return response.json();
   }
 
   // Create receipt
-  static async createReceipt(receiptData: any): Promise<Receipt> {
-    const response = await fetch(`${API_BASE_URL}/receipts`, {
+  // Network failures are retried with the same Idempotency-Key, so a retry
+  // returns the receipt the server already created instead of a duplicate
+  static async createReceipt(receiptData: any, idempotencyKey: string = crypto.randomUUID()): Promise<Receipt> {
+    const send = () => fetch(`${API_BASE_URL}/receipts`, {
       method: 'POST',
       headers: {
         'Content-Type': 'application/json',
+        'Idempotency-Key': idempotencyKey,
+        ...authHeaders(),
       },
       body: JSON.stringify(receiptData),
     });
 
+    let response: Response | undefined;
+    for (let attempt = 1; !response; attempt++) {
+      try {
+        response = await send();
+      } catch (error) {
+        if (attempt >= 3) {
+          throw error;
+        }
+      }
+    }
+
+    if (!response.ok) {
+      throw await ApiError.fromResponse(response);
+    }
+
+    return response.json();
+  }
+
+  // Get a single receipt with its items
+  static async getReceipt(receiptId: number): Promise<Receipt> {
+    const response = await fetch(`${API_BASE_URL}/receipts/${receiptId}`, {
+      headers: authHeaders(),
+    });
+
     if (!response.ok) {
-      const error = await response.text();
-      throw new Error(error);
+      throw await ApiError.fromResponse(response);
     }
 
     return response.json();
   }
 
   // Delete receipt
-  static async deleteReceipt(receiptId: number): Promise<{ message: string }> {
+  // Receipts are voided rather than deleted: they stay for audit and their
+  // points are reversed
+  static async deleteReceipt(receiptId: number): Promise<Receipt> {
     const response = await fetch(`${API_BASE_URL}/receipts/${receiptId}`, {
       method: 'DELETE',
+      headers: authHeaders(),
+    });
+
+    if (!response.ok) {
+      throw await ApiError.fromResponse(response);
+    }
+
+    return response.json();
+  }
+
+  static async refundReceiptItems(
+    receiptId: number,
+    items: { item_id: number; quantity: number }[],
+    reason?: string,
+  ): Promise<Receipt> {
+    const response = await fetch(`${API_BASE_URL}/receipts/${receiptId}/refunds`, {
+      method: 'POST',
+      headers: {
+        'Content-Type': 'application/json',
+        ...authHeaders(),
+      },
+      body: JSON.stringify({ reason, items }),
     });
 
     if (!response.ok) {
-      const error = await response.text();
-      throw new Error(error);
+      throw await ApiError.fromResponse(response);
     }
 
     return response.json();
@@ -274,11 +721,12 @@ export class ApiService {
 
   // Get all receipts (admin function)
   static async getAllReceipts(): Promise<Receipt[]> {
-    const response = await fetch(`${API_BASE_URL}/admin/receipts`);
+    const response = await fetch(`${API_BASE_URL}/admin/receipts`, {
+      headers: authHeaders(),
+    });
 
     if (!response.ok) {
-      const error = await response.text();
-      throw new Error(error);
+      throw await ApiError.fromResponse(response);
     }
 
     return response.json();
@@ -290,18 +738,54 @@ export class ApiService {
       method: 'PUT',
       headers: {
         'Content-Type': 'application/json',
+        ...authHeaders(),
       },
       body: JSON.stringify({ points }),
     });
 
     if (!response.ok) {
-      const error = await response.text();
-      throw new Error(error);
+      throw await ApiError.fromResponse(response);
     }
 
     return response.json();
   }
 
+  // Exchange the stored refresh token for a new token pair
+  static async refreshSession(): Promise<TokenPair> {
+    const tokens = loadTokens();
+    const response = await fetch(`${API_BASE_URL}/auth/refresh`, {
+      method: 'POST',
+      headers: {
+        'Content-Type': 'application/json',
+      },
+      body: JSON.stringify({ refresh_token: tokens?.refresh_token }),
+    });
+
+    if (!response.ok) {
+      throw await ApiError.fromResponse(response);
+    }
+
+    const data = await response.json();
+    storeTokens(data);
+    return data;
+  }
+
+  // Logout and forget the stored tokens
+  static async logout(): Promise<void> {
+    const tokens = loadTokens();
+    if (tokens) {
+      await fetch(`${API_BASE_URL}/auth/logout`, {
+        method: 'POST',
+        headers: {
+          'Content-Type': 'application/json',
+          ...authHeaders(),
+        },
+        body: JSON.stringify({ refresh_token: tokens.refresh_token }),
+      });
+    }
+    localStorage.removeItem(TOKEN_STORAGE_KEY);
+  }
+
   // Health check
   static async healthCheck(): Promise<{ status: string; database: string }> {
     const response = await fetch('http://localhost:8000/health');